@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"log"
 
+	"chatapp/back/database"
 	"chatapp/back/internal/chat"
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/router"
+	"chatapp/back/internal/search"
 	"chatapp/back/internal/service"
 	"chatapp/back/utils"
 	//"chatapp/back/internal/middleware" // 替换成你项目中间件的真实路径
@@ -30,7 +32,7 @@ import (
 // main 负责把“可运行的聊天系统”组装起来。
 // 对学习这个项目的人来说，这个入口文件最值得关注的是启动顺序：
 // 1. 先读取配置并建立数据库/Redis 连接；
-// 2. 再初始化 JWT 和 Kafka 消息管道；
+// 2. 再初始化 JWT 和消息总线（Kafka 或进程内 channel）；
 // 3. 启动后台清理任务；
 // 4. 最后启动 Gin HTTP 服务，对外提供 REST 和 WebSocket 接口。
 func main() {
@@ -48,6 +50,16 @@ func main() {
 
 	config.InitDB()
 
+	// 数据迁移：旧版 JSON 群成员列表 → group_member 表（已迁移的群会自动跳过）
+	if _, err := database.MigrateGroupMembers(config.GetDB()); err != nil {
+		log.Fatalf("群成员迁移失败: %v", err)
+	}
+
+	// 消息全文检索：持久化消费者写库后建立索引，必须在启动消费者之前初始化
+	if err := search.Init(config.GetConfig().SearchConfig); err != nil {
+		log.Fatalf("消息检索初始化失败: %v", err)
+	}
+
 	// 2b) 自动创建/同步管理员账号（占位符配置下跳过，VPS 部署后生效）
 	adminCfg := config.GetConfig().AdminConfig
 	service.SeedAdminUser(config.GetDB(), adminCfg.Username, adminCfg.Password)
@@ -74,25 +86,27 @@ func main() {
 		cfg.RedisConfig.Db,
 	)
 
-	// 4) 启动 Kafka 生产者和三个消费者。
-	//    broker 和 topic 从配置文件读取，避免硬编码。
-	kafkaCfg := cfg.KafkaConfig
-	kafkaBrokers := []string{kafkaCfg.HostPort}
-	kafkaTopic := kafkaCfg.ChatTopic
+	// 4) 初始化消息总线，再启动三个消费者。
+	//    messageMode=kafka 时连接 broker（连不上直接退出）；
+	//    messageMode=channel 时使用进程内总线，开发/测试无需 Kafka。
+	if err := chat.InitMessageBus(cfg.KafkaConfig); err != nil {
+		log.Fatalf("消息总线初始化失败: %v", err)
+	}
 
-	err := chat.InitKafkaProducer(kafkaBrokers, kafkaTopic)
+	// 跨节点路由：多实例部署时，让推送能到达连在其它节点上的用户。
+	chat.InitCluster(cfg.ClusterConfig)
 
-	chat.StartDispatcherConsumer(kafkaBrokers, "chat-dispatcher-debug-1", kafkaTopic)
-
-	if err != nil {
-		log.Fatalf("Kafka init failed: %v", err)
-	}
+	// Dispatcher Consumer：把消息实时推给在线用户。
+	chat.StartDispatcherConsumer("chat-dispatcher-debug-1")
 
 	// Persist Consumer：把消息真正写入 MySQL，保证历史消息可追溯。
-	chat.StartPersistConsumer(kafkaBrokers, "chat-persist-debug-1", kafkaTopic)
+	chat.StartPersistConsumer("chat-persist-debug-1")
 
 	// Cache Consumer：把部分会话/消息状态同步到缓存层，提高读取效率。
-	chat.StartCacheConsumer(kafkaBrokers, "chat-cache-debug-1", kafkaTopic)
+	chat.StartCacheConsumer("chat-cache-debug-1")
+
+	// 定时消息调度器：到期的定时消息走和实时发送相同的 Publish 链路。
+	chat.StartScheduler()
 
 	// 5) 旧版内存 Hub 的 Run 循环目前没有显式启动，
 	//    因为当前主链路已经改成“Client -> Kafka -> Consumers”。
@@ -102,6 +116,15 @@ func main() {
 	// 6) 启动后台文件清理任务，定期处理上传目录中的过期文件。
 	chat.StartFileCleanup(config.GetConfig().StaticFilePath)
 
+	// 阅后即焚：定期删除到期的消息及其引用的文件
+	chat.StartMessageReaper(config.GetConfig().StaticFilePath)
+
+	// 聊天记录导出：定期删除过期的导出文件
+	service.StartExportJanitor()
+
+	// 离线邮件摘要：用户长时间离线时，把未收到的私聊消息和 @ 提醒合并发邮件（notifyConfig.emailDigest 开启时）
+	chat.StartOfflineDigest()
+
 	// 7) 最后启动 HTTP 服务。
 	//    InitRouter 会注册 REST 接口、静态资源、WebSocket 登录入口等全部路由。
 	r := router.InitRouter() // 内部用 utils.GetJWT() 取全局 jwt
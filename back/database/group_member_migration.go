@@ -0,0 +1,82 @@
+// ============================================================
+// 文件：back/database/group_member_migration.go
+// 作用：把 group_info.members（JSON 数组）里的旧数据迁移到 group_member 表。
+//
+// 迁移规则：
+//   · 只处理"还没有任何 group_member 记录"的未解散群，所以每次启动都可以安全重跑
+//   · 群主 → role=2，其余成员 → role=0；入群时间取群的创建时间（旧数据里没有更准确的值）
+//   · JSON 里没有群主时补上群主（早期数据偶有缺失）
+//   · 迁移后按实际行数重算 member_cnt
+//
+// members 列本身保留不删：AutoMigrate 不会 DROP COLUMN，
+// 迁移完成后业务代码不再读写它，确认无误后可以手动删除。
+// ============================================================
+package database
+
+import (
+	"encoding/json"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"chatapp/back/internal/model"
+)
+
+// MigrateGroupMembers 执行 JSON 成员列表 → group_member 表的迁移，返回迁移的群数量。
+func MigrateGroupMembers(db *gorm.DB) (int, error) {
+	var groups []model.GroupInfo
+	err := db.Where("status <> 2").
+		Where("NOT EXISTS (SELECT 1 FROM group_member gm WHERE gm.group_id = group_info.uuid)").
+		Find(&groups).Error
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, g := range groups {
+		var members []string
+		if len(g.Members) > 0 {
+			if err := json.Unmarshal(g.Members, &members); err != nil {
+				log.Printf("⚠️ 群 %s 成员 JSON 解析失败，只迁移群主: %v", g.Uuid, err)
+			}
+		}
+
+		rows := []model.GroupMember{{
+			GroupId:  g.Uuid,
+			UserId:   g.OwnerId,
+			Role:     model.GroupRoleOwner,
+			JoinedAt: g.CreatedAt,
+		}}
+		for _, uid := range members {
+			if uid == "" || uid == g.OwnerId {
+				continue
+			}
+			rows = append(rows, model.GroupMember{
+				GroupId:  g.Uuid,
+				UserId:   uid,
+				Role:     model.GroupRoleMember,
+				JoinedAt: g.CreatedAt,
+			})
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// JSON 里可能有重复成员，交给唯一索引去重
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+				return err
+			}
+			var cnt int64
+			tx.Model(&model.GroupMember{}).Where("group_id = ?", g.Uuid).Count(&cnt)
+			return tx.Model(&model.GroupInfo{}).Where("uuid = ?", g.Uuid).Update("member_cnt", cnt).Error
+		})
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("✅ 群成员迁移完成：%d 个群", migrated)
+	}
+	return migrated, nil
+}
@@ -4,6 +4,7 @@
 //
 // 本项目主要使用 GORM 的 AutoMigrate（在 config.go 里调用），
 // 这个文件可能包含手动迁移逻辑（如添加索引、修改列类型等 AutoMigrate 不能自动处理的操作）。
+// 数据迁移（如 group_member_migration.go）放在同一个包里，由 main 在 InitDB 之后调用。
 // ============================================================
 package database
 
@@ -37,6 +38,25 @@ func InitDB() {
 		&model.ContactApply{},
 		&model.Session{},
 		&model.Message{},
+		&model.UserSequence{},
+		&model.MessageSeq{},
+		&model.GroupMember{},
+		&model.MessageEdit{},
+		&model.MessageReaction{},
+		&model.MessageMention{},
+		&model.MessageSearch{},
+		&model.ExportJob{},
+		&model.MessageUserState{},
+		&model.ConversationClear{},
+		&model.ScheduledMessage{},
+		&model.DisappearingTimer{},
+		&model.PinnedMessage{},
+		&model.UserStatus{},
+		&model.ReadCursor{},
+		&model.SessionPref{},
+		&model.SessionDraft{},
+		&model.OfflineNotice{},
+		&model.NotifySetting{},
 	)
 
 	if err != nil {
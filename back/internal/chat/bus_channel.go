@@ -0,0 +1,130 @@
+// ============================================================
+// 文件：back/internal/chat/bus_channel.go
+// 作用：MessageBus 的进程内实现，用 Go channel 模拟 Kafka 的消费者组。
+//
+// 适用场景：
+//   本地开发、集成测试、单机演示 —— 不需要启动 Kafka，一个二进制就能跑通
+//   "WebSocket → 总线 → 分发/持久化/缓存" 的完整链路。
+//
+// 实现方式：
+//   每个消费者组对应一个带缓冲的 channel 和一个消费 goroutine。
+//   Publish 把消息复制投递到"所有"消费者组的 channel 里（扇出），
+//   每个组内只有一个 goroutine 顺序处理，所以天然保证顺序。
+//
+// 和 Kafka 的差异（开发时需要心里有数）：
+//   · 消息只在内存中，进程退出即丢失，没有回放能力（StartOldest 不生效）
+//   · 订阅之前发布的消息，这个组收不到
+//   · handler 返回 error 只会记录日志，不会重投递
+//   · 缓冲区满时 Publish 会阻塞，相当于对发送方做了背压；阻塞期间不持有锁，
+//     Close 可以正常执行，正在阻塞的 Publish 随之返回 errBusClosed
+//
+// 关闭：
+//   Close 只关闭 done，不关闭各组的 channel（否则和并发的 Publish 竞争会 panic）。
+//   消费 goroutine 看到 done 后把缓冲里剩下的消息处理完再退出。
+// ============================================================
+
+package chat
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// channelBusBuffer 是每个消费者组的缓冲长度。
+const channelBusBuffer = 1024
+
+var errBusClosed = errors.New("message bus 已关闭")
+
+type channelBus struct {
+	mu     sync.RWMutex
+	groups map[string]chan []byte
+	buffer int
+	closed bool
+	done   chan struct{}
+}
+
+func newChannelBus(buffer int) *channelBus {
+	return &channelBus{
+		groups: make(map[string]chan []byte),
+		buffer: buffer,
+		done:   make(chan struct{}),
+	}
+}
+
+func (b *channelBus) Publish(_ string, value []byte) error {
+	// 持锁只复制组列表，投递时不持锁：缓冲区满时阻塞也不会卡住 Subscribe / Close
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return errBusClosed
+	}
+	chans := make([]chan []byte, 0, len(b.groups))
+	for _, ch := range b.groups {
+		chans = append(chans, ch)
+	}
+	b.mu.RUnlock()
+
+	for _, ch := range chans {
+		select {
+		case ch <- value:
+		case <-b.done:
+			return errBusClosed
+		}
+	}
+	return nil
+}
+
+func (b *channelBus) Subscribe(group string, _ StartOffset, handler MessageHandler) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	if _, ok := b.groups[group]; ok {
+		// 同组重复订阅：组内只需要一个消费者，直接忽略
+		b.mu.Unlock()
+		slog.Warn("bus_channel_duplicate_group", "group", group)
+		return
+	}
+	ch := make(chan []byte, b.buffer)
+	b.groups[group] = ch
+	b.mu.Unlock()
+
+	slog.Info("bus_channel_consumer_ready", "group", group)
+
+	handle := func(value []byte) {
+		if err := handler(value); err != nil {
+			slog.Error("bus_channel_handle_failed", "group", group, "err", err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case value := <-ch:
+				handle(value)
+			case <-b.done:
+				// 处理完缓冲里已经收下的消息再退出
+				for {
+					select {
+					case value := <-ch:
+						handle(value)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (b *channelBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	return nil
+}
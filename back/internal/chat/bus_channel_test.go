@@ -0,0 +1,177 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector 记录一个消费者组收到的消息
+type collector struct {
+	mu   sync.Mutex
+	got  []string
+	wait chan struct{}
+	want int
+}
+
+func newCollector(want int) *collector {
+	return &collector{wait: make(chan struct{}), want: want}
+}
+
+func (c *collector) handle(value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.got = append(c.got, string(value))
+	if len(c.got) == c.want {
+		close(c.wait)
+	}
+	return nil
+}
+
+func (c *collector) waitAll(t *testing.T) []string {
+	t.Helper()
+	select {
+	case <-c.wait:
+	case <-time.After(5 * time.Second):
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		t.Fatalf("只收到 %d/%d 条消息", len(c.got), c.want)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.got...)
+}
+
+func TestChannelBusFanOutInOrder(t *testing.T) {
+	bus := newChannelBus(4)
+	defer bus.Close()
+
+	const n = 50
+	a, b := newCollector(n), newCollector(n)
+	bus.Subscribe("dispatcher", StartNewest, a.handle)
+	bus.Subscribe("persist", StartOldest, b.handle)
+	// 同组重复订阅被忽略，不会分走消息
+	bus.Subscribe("dispatcher", StartNewest, func([]byte) error {
+		t.Error("重复订阅的 handler 不应被调用")
+		return nil
+	})
+
+	for i := 0; i < n; i++ {
+		if err := bus.Publish("k", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	for name, c := range map[string]*collector{"dispatcher": a, "persist": b} {
+		got := c.waitAll(t)
+		for i, v := range got {
+			if v != fmt.Sprint(i) {
+				t.Fatalf("%s 第 %d 条 = %q，顺序错乱", name, i, v)
+			}
+		}
+	}
+}
+
+func TestChannelBusHandlerErrorContinues(t *testing.T) {
+	bus := newChannelBus(4)
+	defer bus.Close()
+
+	c := newCollector(2)
+	bus.Subscribe("cache", StartNewest, func(v []byte) error {
+		c.handle(v)
+		return errors.New("处理失败")
+	})
+	bus.Publish("k", []byte("a"))
+	bus.Publish("k", []byte("b"))
+	if got := c.waitAll(t); len(got) != 2 {
+		t.Fatalf("handler 出错后应继续处理后面的消息，got %v", got)
+	}
+}
+
+func TestChannelBusPublishAfterClose(t *testing.T) {
+	bus := newChannelBus(4)
+	bus.Subscribe("dispatcher", StartNewest, func([]byte) error { return nil })
+	if err := bus.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatalf("重复 Close: %v", err)
+	}
+	if err := bus.Publish("k", []byte("x")); !errors.Is(err, errBusClosed) {
+		t.Fatalf("关闭后 Publish = %v，期望 errBusClosed", err)
+	}
+	// 关闭后订阅什么也不做
+	bus.Subscribe("late", StartNewest, func([]byte) error {
+		t.Error("关闭后订阅的 handler 不应被调用")
+		return nil
+	})
+}
+
+// 缓冲区满、Publish 阻塞时，Close 不能被卡住，阻塞的 Publish 返回 errBusClosed
+func TestChannelBusCloseWhilePublishBlocked(t *testing.T) {
+	bus := newChannelBus(1)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	bus.Subscribe("persist", StartOldest, func([]byte) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+	defer close(release)
+
+	bus.Publish("k", []byte("1")) // 被消费者取走，handler 卡住
+	<-started
+	bus.Publish("k", []byte("2")) // 占满缓冲
+
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish("k", []byte("3")) }()
+	select {
+	case err := <-published:
+		t.Fatalf("缓冲区满时 Publish 应阻塞，却返回了 %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- bus.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish 阻塞时 Close 被卡住")
+	}
+	select {
+	case err := <-published:
+		if !errors.Is(err, errBusClosed) {
+			t.Fatalf("阻塞的 Publish = %v，期望 errBusClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close 之后阻塞的 Publish 没有返回")
+	}
+}
+
+// Close 之前已经进入缓冲的消息仍然会被处理
+func TestChannelBusCloseDrainsBuffer(t *testing.T) {
+	bus := newChannelBus(8)
+	release := make(chan struct{})
+	c := newCollector(4)
+	bus.Subscribe("persist", StartOldest, func(v []byte) error {
+		<-release
+		return c.handle(v)
+	})
+	for _, v := range []string{"a", "b", "c", "d"} {
+		if err := bus.Publish("k", []byte(v)); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	bus.Close()
+	close(release)
+	if got := c.waitAll(t); len(got) != 4 {
+		t.Fatalf("got %v", got)
+	}
+}
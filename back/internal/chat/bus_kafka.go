@@ -0,0 +1,118 @@
+// ============================================================
+// 文件：back/internal/chat/bus_kafka.go
+// 作用：MessageBus 的 Kafka 实现（基于 sarama），生产环境默认使用。
+//
+// 生产者配置（与原 InitKafkaProducer 一致）：
+//   Return.Successes = true    → 同步发送，等待 broker 确认后才返回
+//   RequiredAcks = WaitForAll  → 所有副本写入后才确认，防止 leader 宕机丢消息
+//   Retry.Max = 3              → 网络抖动时最多重试 3 次
+//
+// 消费者：
+//   每个 Subscribe 启动一个后台 goroutine，内部是"创建 ConsumerGroup → Consume → 出错重连"
+//   的循环（原三个 Start*Consumer 里重复的那段代码，现在只写一遍）。
+//   handler 返回 nil 才 MarkMessage，保持"至少一次投递"的语义。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+type kafkaBus struct {
+	brokers  []string
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func newKafkaBus(brokers []string, topic string) (*kafkaBus, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Retry.Max = 3
+
+	p, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("kafka_producer_ready", "topic", topic)
+
+	return &kafkaBus{
+		brokers:  brokers,
+		topic:    topic,
+		producer: p,
+	}, nil
+}
+
+func (b *kafkaBus) Publish(key string, value []byte) error {
+	_, _, err := b.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: b.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	})
+	return err
+}
+
+func (b *kafkaBus) Subscribe(group string, start StartOffset, handler MessageHandler) {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_1_0_0
+	if start == StartOldest {
+		cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	} else {
+		cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	}
+
+	h := &kafkaGroupHandler{group: group, handler: handler}
+
+	go func() {
+		for {
+			client, err := sarama.NewConsumerGroup(b.brokers, group, cfg)
+			if err != nil {
+				slog.Error("kafka_consumer_create_failed", "group", group, "err", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+
+			slog.Info("kafka_consumer_ready", "group", group, "topic", b.topic)
+
+			err = client.Consume(context.Background(), []string{b.topic}, h)
+			client.Close()
+			if err != nil {
+				slog.Error("kafka_consume_error", "group", group, "err", err)
+				time.Sleep(3 * time.Second)
+			}
+		}
+	}()
+}
+
+func (b *kafkaBus) Close() error {
+	return b.producer.Close()
+}
+
+// kafkaGroupHandler 把 sarama 的 ConsumerGroupHandler 适配成 MessageHandler。
+type kafkaGroupHandler struct {
+	group   string
+	handler MessageHandler
+}
+
+func (h *kafkaGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (h *kafkaGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (h *kafkaGroupHandler) ConsumeClaim(
+	sess sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+) error {
+	for msg := range claim.Messages() {
+		if err := h.handler(msg.Value); err != nil {
+			// ❗不 Mark，让 Kafka 重试
+			slog.Error("kafka_handle_failed", "group", h.group, "offset", msg.Offset, "err", err)
+			continue
+		}
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
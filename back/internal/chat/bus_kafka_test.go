@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+func TestKafkaBusPublish(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	bus := &kafkaBus{topic: "chat", producer: producer}
+	defer bus.Close()
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "chat" {
+			return errors.New("topic = " + msg.Topic)
+		}
+		key, _ := msg.Key.Encode()
+		value, _ := msg.Value.Encode()
+		if string(key) != "U1" || string(value) != `{"content":"hi"}` {
+			return errors.New("key/value 不对：" + string(key) + " " + string(value))
+		}
+		return nil
+	})
+	if err := bus.Publish("U1", []byte(`{"content":"hi"}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	brokerErr := errors.New("broker 不可用")
+	producer.ExpectSendMessageAndFail(brokerErr)
+	if err := bus.Publish("U1", []byte("x")); !errors.Is(err, brokerErr) {
+		t.Fatalf("Publish = %v，期望返回 broker 的错误", err)
+	}
+}
+
+// fakeSession 只记录 MarkMessage，其余方法不会被 ConsumeClaim 调用
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
+
+// 处理成功才提交 offset，失败的消息留给 Kafka 重投递
+func TestKafkaGroupHandlerMarksOnlySuccess(t *testing.T) {
+	claim := &fakeClaim{msgs: make(chan *sarama.ConsumerMessage, 3)}
+	claim.msgs <- &sarama.ConsumerMessage{Offset: 10, Value: []byte("ok")}
+	claim.msgs <- &sarama.ConsumerMessage{Offset: 11, Value: []byte("bad")}
+	claim.msgs <- &sarama.ConsumerMessage{Offset: 12, Value: []byte("ok")}
+	close(claim.msgs)
+
+	var handled []string
+	h := &kafkaGroupHandler{group: "persist", handler: func(v []byte) error {
+		handled = append(handled, string(v))
+		if string(v) == "bad" {
+			return errors.New("写库失败")
+		}
+		return nil
+	}}
+	sess := &fakeSession{}
+	if err := h.ConsumeClaim(sess, claim); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	if len(handled) != 3 {
+		t.Fatalf("handled = %v，出错后应继续处理后面的消息", handled)
+	}
+	if len(sess.marked) != 2 || sess.marked[0] != 10 || sess.marked[1] != 12 {
+		t.Fatalf("marked = %v，期望 [10 12]", sess.marked)
+	}
+}
@@ -15,7 +15,7 @@
 //     ZSET 的 Member = sessionId，Score = 消息时间戳（Unix 毫秒）
 //     自动按 Score 排序，Score 越大（越新）排名越靠后，用 ZREVRANGE 可以取"最近的前N个"
 //     每次收到新消息，用 ZADD 更新这个会话的 Score，会话列表自动重排
-//     ZREMRANGEBYRANK 删除最早的条目，最多保留 20 个会话
+//     ZREMRANGEBYRANK 删除最早的条目，最多保留 maxSessionCount 个会话
 //
 // sessionId 的构造逻辑（buildSessionId 函数，在 session_id.go 里）：
 //   群聊：sessionId = "G:" + groupUuid
@@ -34,7 +34,7 @@ import (
 )
 
 const (
-	maxSessionCount = 20
+	maxSessionCount = 200
 	maxMessageCount = 100
 )
 
@@ -55,9 +55,12 @@ func cacheMessage(km *KafkaMessage) error {
 		return err
 	}
 
-	// 2️⃣ 更新会话列表（ZSET）
-	updateSessionList(ctx, rdb, km.SendId, sessionId, km.CreatedAt)
-	updateSessionList(ctx, rdb, km.ReceiveId, sessionId, km.CreatedAt)
+	// 2️⃣ 更新发送者和全部接收者的会话列表（ZSET）
+	receivers := messageReceivers(km)
+	updateSessionLists(ctx, rdb, append([]string{km.SendId}, receivers...), sessionId, km.CreatedAt)
+
+	// 3️⃣ 接收者的未读计数（HASH，见 unread.go）
+	incrUnreadCounters(ctx, rdb, km, receivers)
 
 	return nil
 }
@@ -32,7 +32,9 @@ package chat
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -43,7 +45,13 @@ import (
 type Client struct {
 	Conn     *websocket.Conn
 	Uuid     string
+	ConnId   string      // 连接唯一ID，AddClient 时生成，用于跨节点在线登记
 	SendBack chan []byte // Server → 客户端
+
+	// 补发状态（见 sync.go）：补发期间实时消息暂存在 pending 里
+	syncMu  sync.Mutex
+	syncing bool
+	pending [][]byte
 }
 
 // ChatMessageRequest 对应前端通过 WebSocket 发来的 JSON。
@@ -53,19 +61,30 @@ type ChatMessageRequest struct {
 	Type      int8   `json:"type"`
 	Content   string `json:"content"`
 	ReceiveId string `json:"receiveId"`
-	SendId    string `json:"sendId"`
-	Action    string `json:"action"` // join_group / call_invite / call_answer / call_candidate / call_end
+	SendId    string `json:"sendId"` // 兼容旧前端，服务端忽略：发送者始终是连接的登录用户
+	Action    string `json:"action"` // join_group / sync / typing_start / typing_stop / set_status / draft / call_*
 	GroupId   string `json:"groupId"`
 	LocalId   string `json:"localId"`  // 乐观更新用
 	Url       string `json:"url"`
 	FileName  string `json:"fileName"`
 	FileType  string `json:"fileType"`
 	FileSize  string `json:"fileSize"`
+	ReplyTo   string `json:"replyTo"` // 回复的消息ID
+
+	// @ 提醒：被 @ 的成员 uuid，"all" 表示 @所有人
+	Mentions []string `json:"mentions"`
+
+	// 在线状态（set_status）：online / away / busy / invisible，以及自定义文字
+	Status     string `json:"status"`
+	StatusText string `json:"statusText"`
 
 	// 通话相关
 	CallType string `json:"callType"`
 	CallId   string `json:"callId"`
 	Accept   *bool  `json:"accept"`
+
+	// 增量补发：客户端已见过的最大 seq
+	Since int64 `json:"since"`
 }
 
 const (
@@ -77,8 +96,12 @@ const (
 // Read 持续读取前端发来的消息。
 // 它的职责不是直接执行业务，而是做协议层分流：
 // - join_group：更新内存订阅；
+// - sync：补发 since 之后的消息；
+// - typing_start / typing_stop：转发"正在输入"（见 typing.go）；
+// - set_status：修改在线状态（见 user_status.go）；
+// - draft：保存会话草稿并同步到自己的其它设备（见 draft.go）；
 // - call_*：转发音视频信令；
-// - 默认：组装成 ChatEnvelope，交给 Kafka 主链路。
+// - 默认：组装成 ChatEnvelope，交给 Kafka 主链路，并回 ack / nack。
 func (c *Client) Read() {
 	defer func() {
 		ChatServer.RemoveClient(c)
@@ -118,6 +141,32 @@ func (c *Client) Read() {
 			ChatServer.AddUserToGroup(c.Uuid, req.GroupId)
 			continue
 
+		case "sync":
+			c.StartSync(req.Since)
+			continue
+
+		case "typing_start":
+			startTyping(c.Uuid, req.ReceiveId)
+			continue
+
+		case "typing_stop":
+			stopTyping(c.Uuid, req.ReceiveId)
+			continue
+
+		case "set_status":
+			if _, err := ChatServer.SetUserStatus(c.Uuid, req.Status, req.StatusText); err != nil {
+				raw, _ := json.Marshal(map[string]interface{}{"action": "set_status_failed", "error": err.Error()})
+				c.push(raw)
+			}
+			continue
+
+		case "draft":
+			if err := saveDraft(c, req.ReceiveId, req.Content, req.ReplyTo); err != nil {
+				raw, _ := json.Marshal(map[string]interface{}{"action": "draft_failed", "receiveId": req.ReceiveId, "error": err.Error()})
+				c.push(raw)
+			}
+			continue
+
 		case "call_invite", "call_answer", "call_candidate", "call_end":
 			ChatServer.ForwardCallSignal(c.Uuid, req)
 			continue
@@ -130,16 +179,25 @@ func (c *Client) Read() {
 				FileName:  req.FileName,
 				FileType:  req.FileType,
 				FileSize:  req.FileSize,
-				SendId:    nz(req.SendId, c.Uuid),
+				SendId:    c.Uuid, // 发送者只认连接的登录身份，不信任前端传的 sendId
 				ReceiveId: req.ReceiveId,
 				LocalId:   req.LocalId,
+				ReplyTo:   req.ReplyTo,
+				Mentions:  req.Mentions,
 			}
 
 			slog.Info("msg_send", "send_id", env.SendId, "recv_id", env.ReceiveId, "type", env.Type)
 
-			// ✅ 1. 发 Kafka（新主链路）
-			if ChatKafkaProducer != nil {
-				ChatKafkaProducer.Publish(env)
+			// ✅ 1. 发 Kafka（新主链路），并把结果按 localId 回给这条连接
+			if env.ReceiveId == "" {
+				c.sendAck(env.LocalId, "", 0, errors.New("接收方不能为空"))
+				continue
+			}
+			msgId, createdAt, err := ChatKafkaProducer.Publish(env)
+			c.sendAck(env.LocalId, msgId, createdAt, err)
+			if err == nil {
+				stopTyping(c.Uuid, env.ReceiveId)
+				clearDraft(c, env.ReceiveId)
 			}
 
 			// ⚠️ 2. 暂时保留旧内存链路（下一阶段删除）
@@ -0,0 +1,371 @@
+// ============================================================
+// 文件：back/internal/chat/cluster.go
+// 作用：多实例部署时的"跨节点路由"：在线登记表 + 节点间消息转发。
+//
+// 问题背景：
+//   Server.Clients 和 groupMembers 都是进程内的 map。
+//   部署多个后端实例（多个 Pod 挂在负载均衡后面）时，用户 A 连在节点 1，
+//   用户 B 连在节点 2，节点 1 调 DeliverToUser(B) 在本地找不到连接，消息就悄悄丢了。
+//
+// 解决方案（两部分，都基于 Redis）：
+//
+//   1. 在线登记表（presence registry）
+//      Redis KEY  = "chat:presence:{userId}"（HASH）
+//      field = 连接ID（Client.ConnId），value = 该连接所在的节点ID
+//      连接建立时 HSET，断开时 HDEL；节点心跳定期续期 TTL。
+//      另有 "chat:node:alive:{nodeId}" 作为节点存活标记，节点宕机后标记过期，
+//      它留下的登记项会在查询时被识别为失效并清理。
+//
+//   2. 节点间转发通道（Redis Pub/Sub）
+//      每个节点订阅自己的频道 "chat:node:{nodeId}"，以及公共频道 "chat:node:broadcast"。
+//      · 私发：查登记表找出目标用户所在的其它节点，往这些节点的频道 PUBLISH 一帧，
+//              对方节点收到后在本地 deliverLocal。
+//      · 批量私发：同一时刻要给很多用户各推一帧（如群消息的 msg_seq），一次 pipeline 查完登记表，
+//              每个节点只 PUBLISH 一帧，里面带上该节点上全部用户各自的内容（deliverToUsers）。
+//      · 群发：群订阅关系（join_group）只存在于各节点本地，所以群消息直接发到
+//              公共频道，每个节点把它推给自己本地的订阅者。
+//      · 踢下线：RemoveAllClients 也通过公共频道通知其它节点关闭该用户的连接。
+//
+// 单机部署时把 clusterConfig.enabled 设为 false，以上逻辑全部短路，行为与原来完全一致。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	presenceKeyPrefix   = "chat:presence:"
+	nodeAliveKeyPrefix  = "chat:node:alive:"
+	nodeChannelPrefix   = "chat:node:"
+	broadcastChannel    = "chat:node:broadcast"
+	presenceTTL         = 2 * time.Minute
+	nodeAliveTTL        = 45 * time.Second
+	nodeHeartbeatPeriod = 15 * time.Second
+)
+
+// 节点间转发帧的类型
+const (
+	nodeFrameUser  = "user"  // 推给某个用户的全部本地连接
+	nodeFrameUsers = "users" // 给多个用户各推一帧（Batch：userId → 内容）
+	nodeFrameGroup = "group" // 推给某个群的全部本地订阅者
+	nodeFrameKick  = "kick"  // 关闭某个用户的全部本地连接
+)
+
+// nodeFrame 是节点之间通过 Redis Pub/Sub 传递的信封。
+// Raw 原样保存要推给前端的 JSON，不做二次解析。
+type nodeFrame struct {
+	Kind    string          `json:"kind"`
+	Origin  string          `json:"origin"`
+	UserId  string          `json:"userId,omitempty"`
+	GroupId string          `json:"groupId,omitempty"`
+	Raw     json.RawMessage `json:"raw,omitempty"`
+	// nodeFrameUsers 使用：userId → 推给这个用户的 JSON
+	Batch map[string]json.RawMessage `json:"batch,omitempty"`
+	// 聊天消息携带回执信息，投递成功的节点负责触发"已送达"（见 receipt.go）
+	Receipt *deliveryReceipt `json:"receipt,omitempty"`
+}
+
+var (
+	clusterEnabled bool
+	nodeId         string
+)
+
+// NodeId 返回当前节点ID（未开启集群时为空）。
+func NodeId() string {
+	return nodeId
+}
+
+// InitCluster 开启跨节点路由：登记节点、启动心跳、订阅节点频道。
+// 未开启时什么也不做。
+func InitCluster(cfg config.ClusterConfig) {
+	if !cfg.Enabled {
+		slog.Info("cluster_disabled")
+		return
+	}
+
+	nodeId = cfg.NodeId
+	if nodeId == "" {
+		host, _ := os.Hostname()
+		nodeId = nz(host, "node") + "-" + strings.ToLower(newIDWithPrefix("")[:6])
+	}
+	clusterEnabled = true
+
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	rdb.Set(ctx, nodeAliveKeyPrefix+nodeId, time.Now().Unix(), nodeAliveTTL)
+
+	go clusterHeartbeat()
+	go clusterSubscribe()
+
+	slog.Info("cluster_enabled", "node_id", nodeId)
+}
+
+// clusterHeartbeat 定期续期节点存活标记，以及本节点在线用户的登记表 TTL。
+func clusterHeartbeat() {
+	rdb := config.GetRedis()
+	ticker := time.NewTicker(nodeHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		rdb.Set(ctx, nodeAliveKeyPrefix+nodeId, time.Now().Unix(), nodeAliveTTL)
+
+		ChatServer.Mutex.Lock()
+		userIds := keysOfClients(ChatServer.Clients)
+		ChatServer.Mutex.Unlock()
+
+		pipe := rdb.Pipeline()
+		for _, uid := range userIds {
+			pipe.Expire(ctx, presenceKeyPrefix+uid, presenceTTL)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && len(userIds) > 0 {
+			slog.Warn("cluster_heartbeat_failed", "node_id", nodeId, "err", err)
+		}
+	}
+}
+
+// clusterSubscribe 订阅本节点频道和公共广播频道，把收到的帧交给本地投递。
+// go-redis 的 PubSub 会自动重连，这里不需要额外的重试循环。
+func clusterSubscribe() {
+	rdb := config.GetRedis()
+	sub := rdb.Subscribe(context.Background(), nodeChannelPrefix+nodeId, broadcastChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		var f nodeFrame
+		if err := json.Unmarshal([]byte(msg.Payload), &f); err != nil {
+			slog.Warn("cluster_frame_decode_failed", "err", err)
+			continue
+		}
+		if f.Origin == nodeId {
+			continue // 自己发出的广播，本地已经投递过
+		}
+
+		switch f.Kind {
+		case nodeFrameUser:
+			if ChatServer.deliverLocal(f.UserId, f.Raw) > 0 && f.Receipt != nil {
+				markDelivered(f.Receipt)
+			}
+		case nodeFrameUsers:
+			for uid, raw := range f.Batch {
+				ChatServer.deliverLocal(uid, raw)
+			}
+		case nodeFrameGroup:
+			deliverToLocalGroup(f.GroupId, f.Raw, f.Receipt)
+		case nodeFrameKick:
+			ChatServer.removeLocalClients(f.UserId)
+		}
+	}
+}
+
+func publishFrame(channel string, f nodeFrame) {
+	f.Origin = nodeId
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	if err := config.GetRedis().Publish(context.Background(), channel, raw).Err(); err != nil {
+		slog.Warn("cluster_publish_failed", "channel", channel, "kind", f.Kind, "err", err)
+	}
+}
+
+// ============== 在线登记表 ==============
+
+// registerPresence 登记"某个连接在本节点"。
+func registerPresence(userId, connId string) {
+	if !clusterEnabled {
+		return
+	}
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := presenceKeyPrefix + userId
+	pipe := rdb.Pipeline()
+	pipe.HSet(ctx, key, connId, nodeId)
+	pipe.Expire(ctx, key, presenceTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("presence_register_failed", "user_id", userId, "err", err)
+	}
+}
+
+// unregisterPresence 注销一个或多个连接。
+func unregisterPresence(userId string, connIds ...string) {
+	if !clusterEnabled || len(connIds) == 0 {
+		return
+	}
+	config.GetRedis().HDel(context.Background(), presenceKeyPrefix+userId, connIds...)
+}
+
+// remoteNodesOf 返回目标用户当前有连接的"其它"存活节点。
+// 顺带清理已宕机节点留下的登记项。
+func remoteNodesOf(userId string) []string {
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := presenceKeyPrefix + userId
+
+	entries, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+
+	alive := make(map[string]bool)
+	nodes := make([]string, 0, 2)
+	var stale []string
+	for connId, n := range entries {
+		if n == nodeId {
+			continue
+		}
+		ok, checked := alive[n]
+		if !checked {
+			cnt, _ := rdb.Exists(ctx, nodeAliveKeyPrefix+n).Result()
+			ok = cnt > 0
+			alive[n] = ok
+			if ok {
+				nodes = append(nodes, n)
+			}
+		}
+		if !ok {
+			stale = append(stale, connId)
+		}
+	}
+	if len(stale) > 0 {
+		rdb.HDel(ctx, key, stale...)
+	}
+	return nodes
+}
+
+// remoteOnline 从 userIds 里挑出在其它存活节点上有连接的用户（保持 userIds 的顺序）。
+func remoteOnline(userIds []string) []string {
+	byNode := remoteNodesAmong(userIds)
+	if len(byNode) == 0 {
+		return nil
+	}
+	found := make(map[string]bool)
+	for _, uids := range byNode {
+		for _, uid := range uids {
+			found[uid] = true
+		}
+	}
+	online := make([]string, 0, len(found))
+	for _, uid := range userIds {
+		if found[uid] {
+			online = append(online, uid)
+		}
+	}
+	return online
+}
+
+// remoteNodesAmong 是 remoteNodesOf 的批量版本：返回 "其它存活节点 → 在这个节点上有连接的用户"。
+// 用一次 pipeline 查完登记表，不清理失效项。
+func remoteNodesAmong(userIds []string) map[string][]string {
+	if !clusterEnabled || len(userIds) == 0 {
+		return nil
+	}
+	rdb := config.GetRedis()
+	ctx := context.Background()
+
+	pipe := rdb.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(userIds))
+	for i, uid := range userIds {
+		cmds[i] = pipe.HVals(ctx, presenceKeyPrefix+uid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("presence_batch_lookup_failed", "count", len(userIds), "err", err)
+		return nil
+	}
+
+	alive := make(map[string]bool)
+	byNode := make(map[string][]string)
+	for i, cmd := range cmds {
+		seen := make(map[string]bool)
+		for _, n := range cmd.Val() {
+			if n == nodeId || seen[n] {
+				continue
+			}
+			seen[n] = true
+			ok, checked := alive[n]
+			if !checked {
+				cnt, _ := rdb.Exists(ctx, nodeAliveKeyPrefix+n).Result()
+				ok = cnt > 0
+				alive[n] = ok
+			}
+			if ok {
+				byNode[n] = append(byNode[n], userIds[i])
+			}
+		}
+	}
+	return byNode
+}
+
+// forwardToRemoteNodes 把消息转发给目标用户在其它节点上的连接。
+func forwardToRemoteNodes(userId string, raw []byte, rc *deliveryReceipt) {
+	if !clusterEnabled {
+		return
+	}
+	for _, n := range remoteNodesOf(userId) {
+		publishFrame(nodeChannelPrefix+n, nodeFrame{Kind: nodeFrameUser, UserId: userId, Raw: raw, Receipt: rc})
+	}
+}
+
+// deliverToUsers 给多个用户各推一帧：本节点直接投递，
+// 其它节点按节点合并，每个节点只发一次（nodeFrameUsers）。
+func (s *Server) deliverToUsers(frames map[string][]byte) {
+	userIds := make([]string, 0, len(frames))
+	for uid, raw := range frames {
+		s.deliverLocal(uid, raw)
+		userIds = append(userIds, uid)
+	}
+	for n, uids := range remoteNodesAmong(userIds) {
+		batch := make(map[string]json.RawMessage, len(uids))
+		for _, uid := range uids {
+			batch[uid] = frames[uid]
+		}
+		publishFrame(nodeChannelPrefix+n, nodeFrame{Kind: nodeFrameUsers, Batch: batch})
+	}
+}
+
+// broadcastGroupToNodes 让其它节点把群消息推给它们本地的订阅者。
+func broadcastGroupToNodes(groupId string, raw []byte, rc *deliveryReceipt) {
+	if !clusterEnabled {
+		return
+	}
+	publishFrame(broadcastChannel, nodeFrame{Kind: nodeFrameGroup, GroupId: groupId, Raw: raw, Receipt: rc})
+}
+
+// broadcastKickToNodes 通知其它节点关闭某用户的全部连接。
+func broadcastKickToNodes(userId string) {
+	if !clusterEnabled {
+		return
+	}
+	publishFrame(broadcastChannel, nodeFrame{Kind: nodeFrameKick, UserId: userId})
+}
+
+// IsUserOnline 判断用户是否在任意节点上有连接。
+func (s *Server) IsUserOnline(userId string) bool {
+	s.Mutex.Lock()
+	n := len(s.Clients[userId])
+	s.Mutex.Unlock()
+	if n > 0 {
+		return true
+	}
+	if !clusterEnabled {
+		return false
+	}
+	return len(remoteNodesOf(userId)) > 0
+}
+
+// newConnId 为一条 WebSocket 连接生成唯一ID，格式 "{nodeId}/C..."，方便排查问题。
+func newConnId() string {
+	return fmt.Sprintf("%s/%s", nz(nodeId, "local"), newIDWithPrefix("C"))
+}
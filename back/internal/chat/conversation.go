@@ -0,0 +1,76 @@
+// ============================================================
+// 文件：back/internal/chat/conversation.go
+// 作用：按"会话"维度操作已发出的消息：推送控制事件、修改 Redis 里的缓存副本。
+//
+// 编辑、表情回应等功能都需要"消息发出之后再改它"：
+//   · DeliverToConversation：把事件推给会话的所有参与者
+//       私聊 → 发送方 + 接收方的全部连接（含其它节点）
+//       群聊 → 群的全部在线订阅者（走 dispatchToGroup，含其它节点）
+//   · UpdateCachedMessage：修改 "chat:session:msgs:{sessionId}" 列表里的那条消息
+//       用 WATCH 包住"LRANGE 找下标 → LSET 改写"，
+//       期间如果有新消息 LPUSH 进来导致下标整体后移，事务失败并重试，不会改错位置。
+//       消息不在缓存里（已经被 LTRIM 挤出去）时什么也不做。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"chatapp/back/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const cacheUpdateRetries = 3
+
+// DeliverToConversation 把控制消息推给 (sendId, receiveId) 所在会话的全部参与者。
+func DeliverToConversation(sendId, receiveId string, raw []byte) {
+	if isGroup(receiveId) {
+		dispatchToGroup(receiveId, raw, nil)
+		return
+	}
+	ChatServer.DeliverToUser(receiveId, raw)
+	if sendId != receiveId {
+		ChatServer.DeliverToUser(sendId, raw)
+	}
+}
+
+// UpdateCachedMessage 在最近消息缓存中找到 msgId，用 mutate 修改后原位写回。
+func UpdateCachedMessage(sendId, receiveId, msgId string, mutate func(km *KafkaMessage)) error {
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := fmt.Sprintf("chat:session:msgs:%s", buildSessionId(&KafkaMessage{SendId: sendId, ReceiveId: receiveId}))
+
+	update := func(tx *redis.Tx) error {
+		values, err := tx.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			var km KafkaMessage
+			if json.Unmarshal([]byte(v), &km) != nil || km.MsgId != msgId {
+				continue
+			}
+			mutate(&km)
+			raw, _ := json.Marshal(km)
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.LSet(ctx, key, int64(i), raw)
+				return nil
+			})
+			return err
+		}
+		return nil
+	}
+
+	for i := 0; i < cacheUpdateRetries; i++ {
+		err := rdb.Watch(ctx, update, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
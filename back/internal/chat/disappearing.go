@@ -0,0 +1,290 @@
+// ============================================================
+// 文件：back/internal/chat/disappearing.go
+// 作用："阅后即焚"（消息自动删除）：发送时计算过期时间、计时变更提示、后台清理到期消息。
+//
+// 计时保存在 disappearing_timer 表（见 model/disappearing_timer.go），按会话生效。
+// Publish 时用 expireAtFor 查出当前计时，写进 KafkaMessage.ExpireAt，
+// 之后随消息一起进入 message.expire_at、Redis 缓存和推给前端的 OutgoingMessage。
+//
+// 计时变更提示：
+//   PublishTimerNotice 发一条 type=99 的系统消息，和普通消息走同一条总线，
+//   所以会持久化、进缓存、进历史记录，离线的参与者上线补发时也能看到。
+//   系统消息本身不设过期时间。
+//
+// 后台清理（StartMessageReaper）：
+//   每分钟找出 expire_at 已到的消息，分批：
+//     1. 事务内删除 message 行，以及 seq / @ / 表情回应 / 编辑历史 / 仅自己删除 / 置顶 / 离线通知 这些附属记录
+//     2. 从 "chat:session:msgs:{sessionId}" 列表里移除缓存副本（LREM 按原值删除，不怕下标移动）
+//     3. 删除文件消息引用的 StaticFilePath 下的文件；仍被引用时保留：
+//        其它消息的 url、合并转发快照 message.record 里的 url、还没发出的定时消息 scheduled_message.url
+//     4. 从全文检索里移除，并按会话推送 msg_expired 事件，在线客户端据此把消息从界面上去掉
+//   多实例部署时用 Redis SETNX 保证同一时间只有一个实例在清理；删除本身是幂等的。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+	"chatapp/back/internal/search"
+
+	"gorm.io/gorm"
+)
+
+// msgTypeSystem 是系统消息的类型（与 PushGroupEvent 推送的系统事件相同）
+const msgTypeSystem int8 = 99
+
+const (
+	reaperInterval  = time.Minute
+	reaperBatchSize = 500
+	reaperLockKey   = "chat:reaper:lock"
+)
+
+// expireAtFor 按会话当前的计时算出 km 的过期时间（Unix 秒），未开启时返回 0。
+func expireAtFor(db *gorm.DB, km *KafkaMessage) int64 {
+	if km.Type == msgTypeSystem {
+		return 0
+	}
+	var ttls []int64
+	if err := db.Model(&model.DisappearingTimer{}).
+		Where("conversation_key = ?", buildSessionId(km)).
+		Limit(1).
+		Pluck("ttl_seconds", &ttls).Error; err != nil || len(ttls) == 0 || ttls[0] <= 0 {
+		return 0
+	}
+	return km.CreatedAt + ttls[0]
+}
+
+// PublishTimerNotice 在会话里发一条"消息自动删除计时已修改"的系统消息。
+// 修改计时的权限由调用方校验，这里不再做发送权限检查（被禁言的管理员也能修改计时）。
+func (kp *KafkaProducer) PublishTimerNotice(userId, targetId string, ttl int64) error {
+	if kp == nil {
+		return errPublishFailed
+	}
+
+	senderName, senderAvatar, _ := loadUserBasic(config.GetDB(), userId)
+	name := nz(senderName, "用户")
+	content := fmt.Sprintf("%s 关闭了消息自动删除", name)
+	if ttl > 0 {
+		content = fmt.Sprintf("%s 将消息自动删除时间设置为%s", name, ttlLabel(ttl))
+	}
+
+	km := KafkaMessage{
+		MsgId:      newIDWithPrefix("M"),
+		Type:       msgTypeSystem,
+		SendId:     userId,
+		SendName:   name,
+		SendAvatar: senderAvatar,
+		ReceiveId:  targetId,
+		Content:    content,
+		CreatedAt:  time.Now().Unix(),
+	}
+	raw, err := json.Marshal(km)
+	if err != nil {
+		return errPublishFailed
+	}
+	if err := kp.bus.Publish(targetId, raw); err != nil {
+		slog.Error("timer_notice_publish_failed", "send_id", userId, "recv_id", targetId, "err", err)
+		return errPublishFailed
+	}
+	return nil
+}
+
+// ttlLabel 把计时秒数转成提示文案里的时长
+func ttlLabel(ttl int64) string {
+	if ttl%86400 == 0 {
+		return fmt.Sprintf("%d天", ttl/86400)
+	}
+	if ttl%3600 == 0 {
+		return fmt.Sprintf("%d小时", ttl/3600)
+	}
+	return fmt.Sprintf("%d分钟", ttl/60)
+}
+
+// StartMessageReaper 启动到期消息清理任务。staticFilePath 是上传文件所在目录。
+func StartMessageReaper(staticFilePath string) {
+	go func() {
+		ticker := time.NewTicker(reaperInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reapExpiredMessages(staticFilePath)
+		}
+	}()
+	slog.Info("message_reaper_started", "interval", reaperInterval.String())
+}
+
+func reapExpiredMessages(staticFilePath string) {
+	ok, err := config.GetRedis().SetNX(context.Background(), reaperLockKey, schedulerOwner(), reaperInterval/2).Result()
+	if err != nil || !ok {
+		return
+	}
+
+	db := config.GetDB()
+	for {
+		var expired []model.Message
+		if err := db.Select("id", "uuid", "type", "url", "send_id", "receive_id").
+			Where("expire_at IS NOT NULL AND expire_at <= ?", time.Now()).
+			Order("expire_at ASC").
+			Limit(reaperBatchSize).
+			Find(&expired).Error; err != nil {
+			slog.Error("reaper_query_failed", "err", err)
+			return
+		}
+		if len(expired) == 0 {
+			return
+		}
+
+		if err := deleteExpiredMessages(db, expired); err != nil {
+			slog.Error("reaper_delete_failed", "count", len(expired), "err", err)
+			return
+		}
+		cleanupExpired(db, expired, staticFilePath)
+		slog.Info("messages_expired", "count", len(expired))
+
+		if len(expired) < reaperBatchSize {
+			return
+		}
+	}
+}
+
+// deleteExpiredMessages 在一个事务里删除消息及其附属记录
+func deleteExpiredMessages(db *gorm.DB, msgs []model.Message) error {
+	ids := make([]int64, 0, len(msgs))
+	uuids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.Id)
+		uuids = append(uuids, m.Uuid)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", ids).Delete(&model.Message{}).Error; err != nil {
+			return err
+		}
+		for _, m := range []interface{}{
+			&model.MessageSeq{},
+			&model.MessageMention{},
+			&model.MessageReaction{},
+			&model.MessageEdit{},
+			&model.MessageUserState{},
+			&model.PinnedMessage{},
+		} {
+			if err := tx.Where("msg_uuid IN ?", uuids).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		// 离线通知里存着消息摘要，不删的话到期的内容还会出现在邮件里
+		return tx.Where("msg_id IN ?", uuids).Delete(&model.OfflineNotice{}).Error
+	})
+}
+
+// cleanupExpired 处理数据库之外的部分：缓存、文件、检索索引、在线推送
+func cleanupExpired(db *gorm.DB, msgs []model.Message, staticFilePath string) {
+	type conversation struct {
+		sendId, receiveId string
+		msgIds            []string
+	}
+	bySession := make(map[string]*conversation)
+	uuids := make([]string, 0, len(msgs))
+
+	for _, m := range msgs {
+		uuids = append(uuids, m.Uuid)
+		key := buildSessionId(&KafkaMessage{SendId: m.SendId, ReceiveId: m.ReceiveId})
+		conv, ok := bySession[key]
+		if !ok {
+			conv = &conversation{sendId: m.SendId, receiveId: m.ReceiveId}
+			bySession[key] = conv
+		}
+		conv.msgIds = append(conv.msgIds, m.Uuid)
+
+		if m.Type == 1 {
+			removeExpiredFile(db, m.Url, staticFilePath)
+		}
+	}
+	search.Remove(uuids...)
+
+	for sessionId, conv := range bySession {
+		if err := removeCachedMessages(sessionId, conv.msgIds); err != nil {
+			slog.Warn("reaper_cache_remove_failed", "session_id", sessionId, "err", err)
+		}
+		raw, _ := json.Marshal(map[string]interface{}{
+			"action":    "msg_expired",
+			"sendId":    conv.sendId,
+			"receiveId": conv.receiveId,
+			"msgIds":    conv.msgIds,
+		})
+		DeliverToConversation(conv.sendId, conv.receiveId, raw)
+	}
+}
+
+// fileStillReferenced 判断上传文件是否还被引用：消息 url、合并转发快照、定时消息。
+// 查询出错时按"仍被引用"处理，宁可留下文件也不误删。
+func fileStillReferenced(db *gorm.DB, url string) bool {
+	var cnt int64
+	if err := db.Model(&model.Message{}).Where("url = ?", url).Count(&cnt).Error; err != nil || cnt > 0 {
+		return true
+	}
+	// record 是 JSON 快照，嵌套的聊天记录也在里面，按子串匹配即可（url 里的 %、_ 要转义）
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(`"`+url+`"`) + "%"
+	if err := db.Model(&model.Message{}).Where("record LIKE ?", pattern).Count(&cnt).Error; err != nil || cnt > 0 {
+		return true
+	}
+	if err := db.Model(&model.ScheduledMessage{}).
+		Where("url = ? AND status IN ?", url, []int8{model.ScheduledStatusPending, model.ScheduledStatusSending}).
+		Count(&cnt).Error; err != nil || cnt > 0 {
+		return true
+	}
+	return false
+}
+
+// removeCachedMessages 从最近消息缓存里删除指定的消息
+func removeCachedMessages(sessionId string, msgIds []string) error {
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := fmt.Sprintf("chat:session:msgs:%s", sessionId)
+
+	values, err := rdb.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool, len(msgIds))
+	for _, id := range msgIds {
+		want[id] = true
+	}
+
+	pipe := rdb.Pipeline()
+	n := 0
+	for _, v := range values {
+		var km KafkaMessage
+		if json.Unmarshal([]byte(v), &km) != nil || !want[km.MsgId] {
+			continue
+		}
+		pipe.LRem(ctx, key, 1, v)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// removeExpiredFile 删除过期文件消息引用的上传文件；还被引用时保留
+func removeExpiredFile(db *gorm.DB, url, staticFilePath string) {
+	if !strings.HasPrefix(url, "/static/files/") || fileStillReferenced(db, url) {
+		return
+	}
+	path := filepath.Join(staticFilePath, filepath.Base(url))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("reaper_file_remove_failed", "path", path, "err", err)
+	}
+}
@@ -6,11 +6,13 @@
 //
 // dispatchKafkaMessage 的两步判断：
 //   第一步：isGroup(km.ReceiveId)
-//     检查 groupMembers 内存表里是否有这个 receiveId 作为群ID。
+//     检查 groupMembers 内存表里是否有这个 receiveId 作为群ID（查不到再查库兜底）。
 //     如果有，说明这是群聊消息，走群聊分发逻辑。
+//     群消息带 @ 时，另外给被 @ 的人单独推 mention 事件（见 mention.go）。
 //   第二步（私聊）：
 //     ChatServer.DeliverToUser(km.ReceiveId, raw)  → 推给接收方
 //     ChatServer.DeliverToUser(km.SendId, raw)     → 推给发送方（消息回显，让发送方看到"发送成功"）
+//   接收方不在线时记一条离线通知，用于离线邮件摘要（见 offline_notice.go）；@ 提醒同理。
 //
 // 为什么发送方也需要收到自己的消息？
 //   前端用"乐观更新"：发消息后立刻在界面上显示一个"发送中"气泡，
@@ -24,6 +26,11 @@ package chat
 import (
 	"encoding/json"
 	"log"
+	"sync"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
 )
 
 // dispatchKafkaMessage 将 KafkaMessage 转换为前端所需格式并推送
@@ -45,6 +52,12 @@ func dispatchKafkaMessage(km *KafkaMessage) {
 		SendAvatar: km.SendAvatar,
 		ReceiveId:  km.ReceiveId,
 		CreatedAt:  km.CreatedAt,
+		ReplyTo:    km.ReplyTo,
+		RootId:     km.RootId,
+		Quote:      km.Quote,
+		Mentions:   km.Mentions,
+		ExpireAt:   km.ExpireAt,
+		Record:     km.Record,
 	}
 
 	raw, err := json.Marshal(out)
@@ -53,32 +66,126 @@ func dispatchKafkaMessage(km *KafkaMessage) {
 		return
 	}
 
+	rc := &deliveryReceipt{MsgId: km.MsgId, SendId: km.SendId, ReceiveId: km.ReceiveId}
+
 	// 群聊
 	if isGroup(km.ReceiveId) {
-		dispatchToGroup(km.ReceiveId, raw)
+		dispatchToGroup(km.ReceiveId, raw, rc)
+		pushMentions(config.GetDB(), km)
 		return
 	}
 
 	// 点对点：推给接收方和发送方（回显）
-	ChatServer.DeliverToUser(km.ReceiveId, raw)
+	// 接收方任一本地连接收下即算送达；其它节点上的连接由对方节点回执
+	if km.ReceiveId != km.SendId {
+		if ChatServer.deliverLocal(km.ReceiveId, raw) > 0 {
+			markDelivered(rc)
+		}
+		forwardToRemoteNodes(km.ReceiveId, raw, rc)
+		recordOfflineNotices(config.GetDB(), km, model.OfflineNoticeDirect, km.SendId, []string{km.ReceiveId})
+	}
 	ChatServer.DeliverToUser(km.SendId, raw)
 }
 
+// isGroup 判断 id 是否是群ID。
+// 先查本地订阅表；本节点没人 join 过这个群时（多节点部署很常见）再查数据库兜底，
+// 查到是群时缓存在 knownGroups 里；不是群的结果在 notGroups 里短暂缓存，
+// 私聊消息在 Publish / 分发链路上多次调用 isGroup，不会每次都查库。
 func isGroup(id string) bool {
+	if id == "" {
+		return false
+	}
 	groupMemsMu.RLock()
 	_, ok := groupMembers[id]
 	groupMemsMu.RUnlock()
+	if ok {
+		return true
+	}
+	if _, ok := knownGroups.Load(id); ok {
+		return true
+	}
+	if notGroups.has(id) {
+		return false
+	}
+	var cnt int64
+	if err := config.GetDB().Model(&model.GroupInfo{}).Where("uuid = ?", id).Count(&cnt).Error; err != nil {
+		return false
+	}
+	if cnt > 0 {
+		knownGroups.Store(id, struct{}{})
+	} else {
+		notGroups.add(id)
+	}
+	return cnt > 0
+}
+
+// knownGroups 缓存已确认是群的ID。群ID不会被复用成用户ID，缓存不需要失效，条目数最多等于群的总数。
+var knownGroups sync.Map
+
+const (
+	notGroupTTL     = time.Minute
+	notGroupMaxSize = 10000
+)
+
+// notGroups 短暂缓存"不是群"的结果。key 来自任意客户端输入，所以带过期时间和条数上限：
+// 满了先清掉过期的，仍然满就整个清空。群ID是新生成的，不会出现"先查不是群、之后又变成群"。
+var notGroups = &negativeCache{items: make(map[string]time.Time)}
+
+type negativeCache struct {
+	mu    sync.Mutex
+	items map[string]time.Time // id → 过期时间
+}
+
+func (c *negativeCache) has(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exp, ok := c.items[id]
+	if ok && time.Now().After(exp) {
+		delete(c.items, id)
+		return false
+	}
 	return ok
 }
 
-func dispatchToGroup(groupId string, raw []byte) {
+func (c *negativeCache) add(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.items) >= notGroupMaxSize {
+		for k, exp := range c.items {
+			if now.After(exp) {
+				delete(c.items, k)
+			}
+		}
+		if len(c.items) >= notGroupMaxSize {
+			c.items = make(map[string]time.Time)
+		}
+	}
+	c.items[id] = now.Add(notGroupTTL)
+}
+
+// dispatchToGroup 推给群的全部在线订阅者：本节点直接投递，其它节点通过广播频道投递。
+// rc 不为空时，任一非发送方成员收到即回执"已送达"；系统事件传 nil。
+func dispatchToGroup(groupId string, raw []byte, rc *deliveryReceipt) {
+	deliverToLocalGroup(groupId, raw, rc)
+	broadcastGroupToNodes(groupId, raw, rc)
+}
+
+// deliverToLocalGroup 只推给本节点上订阅了该群的用户。
+func deliverToLocalGroup(groupId string, raw []byte, rc *deliveryReceipt) {
 	groupMemsMu.RLock()
 	subs := make(map[string]bool, len(groupMembers[groupId]))
 	for k, v := range groupMembers[groupId] {
 		subs[k] = v
 	}
 	groupMemsMu.RUnlock()
+	delivered := false
 	for uid := range subs {
-		ChatServer.DeliverToUser(uid, raw)
+		if ChatServer.deliverLocal(uid, raw) > 0 && rc != nil && uid != rc.SendId {
+			delivered = true
+		}
+	}
+	if delivered {
+		markDelivered(rc)
 	}
 }
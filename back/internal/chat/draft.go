@@ -0,0 +1,122 @@
+// ============================================================
+// 文件：back/internal/chat/draft.go
+// 作用：会话草稿的保存、清除，以及同步到自己的其它设备（draft_updated 事件）。
+//
+// 前端约定：
+//   输入框内容变化后防抖（停顿 1~2 秒）再发
+//     {"action":"draft","receiveId":对方或群ID,"content":"写了一半的内容","replyTo":正在回复的消息ID}
+//   content 和 replyTo 都为空表示删除草稿（清空输入框）。
+//   发消息前要取消还没发出的防抖保存，否则旧内容会在消息发出之后又写回来。
+//
+// 服务端：
+//   · 草稿保存在 session_draft 表（见 model/session_draft.go），会话列表里带上（见 service/session_list_service.go）
+//   · 保存或删除后推 {"action":"draft_updated","targetId":...,"content":...,"replyTo":...,"updatedAt":...}
+//     给同一用户的其它连接：本节点跳过发起的这条连接，其它节点上的连接全部推送
+//   · 在某个会话里发出消息（ack 成功）后自动删除这个会话的草稿，同样推 draft_updated（content 为空）
+// ============================================================
+
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+const draftMaxRunes = 5000
+
+// saveDraft 处理 draft：保存草稿，内容为空时删除，然后同步给自己的其它连接。
+func saveDraft(c *Client, receiveId, content, replyTo string) error {
+	if receiveId == "" || receiveId == c.Uuid || len(replyTo) > 20 {
+		return errors.New("参数错误")
+	}
+	if utf8.RuneCountInString(content) > draftMaxRunes {
+		return errors.New("草稿最多5000个字符")
+	}
+
+	if strings.TrimSpace(content) == "" && replyTo == "" {
+		clearDraft(c, receiveId)
+		return nil
+	}
+	if !canKeepDraft(c.Uuid, receiveId) {
+		return errors.New("会话不存在")
+	}
+
+	d := model.SessionDraft{
+		UserId:    c.Uuid,
+		TargetId:  receiveId,
+		Content:   content,
+		ReplyTo:   replyTo,
+		UpdatedAt: time.Now(),
+	}
+	if err := config.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "reply_to", "updated_at"}),
+	}).Create(&d).Error; err != nil {
+		slog.Error("draft_save_failed", "user_id", c.Uuid, "target_id", receiveId, "err", err)
+		return errors.New("保存草稿失败")
+	}
+
+	pushDraft(c, receiveId, d.Content, d.ReplyTo, d.UpdatedAt.Unix())
+	return nil
+}
+
+// clearDraft 删除草稿（发出消息或清空输入框时），真的删掉了才推送
+func clearDraft(c *Client, receiveId string) {
+	res := config.GetDB().
+		Where("user_id = ? AND target_id = ?", c.Uuid, receiveId).
+		Delete(&model.SessionDraft{})
+	if res.Error != nil {
+		slog.Warn("draft_clear_failed", "user_id", c.Uuid, "target_id", receiveId, "err", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		pushDraft(c, receiveId, "", "", time.Now().Unix())
+	}
+}
+
+// canKeepDraft 群聊要求是群成员；私聊只要求对方存在
+func canKeepDraft(userId, receiveId string) bool {
+	db := config.GetDB()
+	var cnt int64
+	if isGroup(receiveId) {
+		db.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", receiveId, userId).
+			Count(&cnt)
+	} else {
+		db.Model(&model.UserInfo{}).Where("uuid = ?", receiveId).Count(&cnt)
+	}
+	return cnt > 0
+}
+
+// pushDraft 把草稿变化推给 c 所属用户的其它连接
+func pushDraft(c *Client, receiveId, content, replyTo string, updatedAt int64) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":    "draft_updated",
+		"targetId":  receiveId,
+		"content":   content,
+		"replyTo":   replyTo,
+		"updatedAt": updatedAt,
+	})
+	ChatServer.deliverToOtherConns(c, raw)
+}
+
+// deliverToOtherConns 推给同一用户除 c 以外的全部连接（含其它节点上的）
+func (s *Server) deliverToOtherConns(c *Client, raw []byte) {
+	s.Mutex.Lock()
+	for _, other := range s.Clients[c.Uuid] {
+		if other != c {
+			other.push(raw)
+		}
+	}
+	s.Mutex.Unlock()
+	forwardToRemoteNodes(c.Uuid, raw, nil)
+}
@@ -9,6 +9,7 @@
 //   2. 把这个会话的最新时间戳写入双方各自的"会话列表 ZSET"
 //      (chat:session:list:{userId}，score = 消息时间戳）
 //      ZSET 会自动按时间戳排序，最近有消息的会话总在前面
+//   3. 给接收者的未读计数加一（chat:unread:{userId}，见 unread.go）
 //
 // 为什么要缓存，直接查数据库不行吗？
 //   数据库（MySQL）的查询延迟通常是几到几十毫秒，
@@ -25,62 +26,23 @@
 package chat
 
 import (
-	"context"
 	"encoding/json"
 	"log/slog"
-	"time"
-
-	"github.com/IBM/sarama"
 )
 
-type CacheConsumer struct{}
-
-func StartCacheConsumer(brokers []string, group, topic string) {
-	cfg := sarama.NewConfig()
-	cfg.Version = sarama.V2_1_0_0
-	cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
-
-	go func() {
-		for {
-			client, err := sarama.NewConsumerGroup(brokers, group, cfg)
-			if err != nil {
-				slog.Error("kafka_consumer_create_failed", "group", group, "err", err)
-				time.Sleep(time.Second)
-				continue
-			}
-
-			err = client.Consume(context.Background(), []string{topic}, &CacheConsumer{})
-			client.Close()
-			if err != nil {
-				slog.Error("kafka_consume_error", "group", group, "err", err)
-				time.Sleep(3 * time.Second)
-			}
-		}
-	}()
-}
-
-func (c *CacheConsumer) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (c *CacheConsumer) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
-
-func (c *CacheConsumer) ConsumeClaim(
-	sess sarama.ConsumerGroupSession,
-	claim sarama.ConsumerGroupClaim,
-) error {
-
-	for msg := range claim.Messages() {
+// StartCacheConsumer 以 group 身份订阅消息总线，把新消息同步到 Redis。
+func StartCacheConsumer(group string) {
+	ChatBus.Subscribe(group, StartNewest, func(value []byte) error {
 		var km KafkaMessage
-		if err := json.Unmarshal(msg.Value, &km); err != nil {
+		if err := json.Unmarshal(value, &km); err != nil {
 			slog.Error("kafka_decode_failed", "consumer", "cache", "err", err)
-			continue
+			return err
 		}
 
 		if err := cacheMessage(&km); err != nil {
 			slog.Error("kafka_cache_failed", "msg_id", km.MsgId, "err", err)
-			continue
+			return err
 		}
-
-		sess.MarkMessage(msg, "")
-	}
-
-	return nil
+		return nil
+	})
 }
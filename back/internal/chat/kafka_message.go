@@ -28,6 +28,11 @@
 
 package chat
 
+import (
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+)
+
 // KafkaMessage — Kafka 中的统一消息结构（含完整元数据，供 dispatcher/persist 使用）
 type KafkaMessage struct {
 	MsgId      string            `json:"msgId"`
@@ -44,4 +49,13 @@ type KafkaMessage struct {
 	FileSize   string            `json:"fileSize,omitempty"`
 	Meta       map[string]string `json:"meta,omitempty"`
 	CreatedAt  int64             `json:"createdAt"`
+	EditedAt   int64             `json:"editedAt,omitempty"` // 缓存副本被编辑后才会有
+	ReplyTo    string            `json:"replyTo,omitempty"`  // 回复的消息ID
+	RootId     string            `json:"rootId,omitempty"`   // 所在话题的根消息ID
+
+	Quote    *resp.MessageQuote `json:"quote,omitempty"`    // 发送时生成的引用快照
+	Mentions []string           `json:"mentions,omitempty"` // 整理后的 @ 列表（见 mention.go）
+	ExpireAt int64              `json:"expireAt,omitempty"` // 阅后即焚的过期时间（见 disappearing.go）
+
+	Record *model.ForwardRecord `json:"record,omitempty"` // 合并转发的聊天记录（type=3）
 }
@@ -1,85 +1,81 @@
 // ============================================================
 // 文件：back/internal/chat/kafka_producer.go
-// 作用：封装 Kafka 生产者，负责把用户发出的消息"写入"消息队列。
+// 作用：消息生产者，负责把用户发出的消息"写入"消息总线（MessageBus）。
 //
-// 什么是 Kafka 生产者？
-//   在 Kafka 里，生产者（Producer）负责把消息发布到某个"主题"（Topic）。
-//   就像一个邮局的投递窗口：你把信（消息）交给窗口，窗口负责放进对应的邮箱（Topic）。
-//
-// KafkaProducer 结构体：
-//   producer - sarama 库的 SyncProducer，sarama 是 Kafka 的 Go 客户端库
-//   topic    - 消息要发布到哪个主题（频道名）
-//
-// InitKafkaProducer 的三项配置解读：
-//   Return.Successes = true    → 每次 SendMessage 都等待 Kafka 确认收到，才返回成功。
-//                                这叫"同步发送"，可靠但比异步稍慢。
-//   RequiredAcks = WaitForAll  → 要求所有副本（备份节点）都写入后才确认。
-//                                最高可靠性级别，防止 leader 宕机丢消息。
-//   Retry.Max = 3              → 发送失败最多重试 3 次，应对网络抖动。
+// 名字里的 Kafka 是历史原因：最早这里直接封装 sarama 的 SyncProducer。
+// 现在它只依赖 MessageBus 接口，底层是 Kafka 还是进程内 channel 由
+// kafkaConfig.messageMode 决定（见 message_bus.go），Publish 的逻辑完全一样。
 //
 // Publish 方法的核心逻辑：
+//   0. 发送权限检查（群成员、禁言，见 send_guard.go），不通过直接返回原因；
+//      带 replyTo 时校验被引用的消息并生成引用快照（见 reply.go）；
+//      群消息整理 @ 列表（见 mention.go）
+//      会话开启了消息自动删除时，按当前计时算出过期时间（见 disappearing.go）
 //   1. 从 DB 查出发送者的昵称和头像（因为前端需要展示这些信息）
-//   2. 构造 KafkaMessage（包含消息ID、内容、时间戳等完整元数据）
+//   2. 构造 KafkaMessage（包含消息ID、内容、时间戳等完整元数据）；
+//      定时消息带着预分配的 MsgId 进来（见 scheduler.go），其余情况在这里生成
 //   3. 用 JSON 序列化成字节数组
-//   4. 把 receiveId 作为 Kafka 消息的 Key：这样保证"同一个会话"的消息
+//   4. 把 receiveId 作为消息的 Key：这样保证"同一个会话"的消息
 //      总是被同一个消费者分区处理，从而保证消息的顺序性
+//   5. 返回消息ID和发布结果，Client.Read 据此给前端回 ack / nack（见 receipt.go）
 // ============================================================
 
 package chat
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/model"
-
-	"github.com/IBM/sarama"
 )
 
 type KafkaProducer struct {
-	producer sarama.SyncProducer
-	topic    string
+	bus MessageBus
 }
 
+// ChatKafkaProducer 由 InitMessageBus 创建。
 var ChatKafkaProducer *KafkaProducer
 
-func InitKafkaProducer(brokers []string, topic string) error {
-	cfg := sarama.NewConfig()
-	cfg.Producer.Return.Successes = true
-	cfg.Producer.RequiredAcks = sarama.WaitForAll
-	cfg.Producer.Retry.Max = 3
+// errPublishFailed 是返回给前端的失败原因，不暴露底层 broker 的错误细节。
+var errPublishFailed = errors.New("消息发送失败，请重试")
 
-	p, err := sarama.NewSyncProducer(brokers, cfg)
-	if err != nil {
-		return err
+// Publish 把消息写入总线，返回分配的消息ID和创建时间。
+// 返回 nil error 表示总线已经接收，消息不会再丢。
+func (kp *KafkaProducer) Publish(env ChatEnvelope) (string, int64, error) {
+	if kp == nil {
+		return "", 0, errPublishFailed
 	}
 
-	ChatKafkaProducer = &KafkaProducer{
-		producer: p,
-		topic:    topic,
+	db := config.GetDB()
+	if err := checkSendAllowed(db, env); err != nil {
+		return "", 0, err
 	}
-	log.Printf("✅ Kafka Producer 已启动 topic=%s", topic)
-	return nil
-}
-
-func (kp *KafkaProducer) Publish(env ChatEnvelope) {
-	if kp == nil {
-		return
+	rootId, quote, err := resolveReply(db, env)
+	if err != nil {
+		return "", 0, err
+	}
+	mentions, err := resolveMentions(db, env)
+	if err != nil {
+		return "", 0, err
 	}
 
 	// 查询发送者信息
 	var senderName, senderAvatar string
-	db := config.GetDB()
 	var u model.UserInfo
 	if err := db.Where("uuid = ?", env.SendId).First(&u).Error; err == nil {
 		senderName = u.Nickname
 		senderAvatar = u.Avatar
 	}
 
+	msgId := env.MsgId
+	if msgId == "" {
+		msgId = newIDWithPrefix("M")
+	}
 	km := KafkaMessage{
-		MsgId:      newIDWithPrefix("M"),
+		MsgId:      msgId,
 		LocalId:    env.LocalId,
 		Type:       env.Type,
 		SendId:     env.SendId,
@@ -92,21 +88,24 @@ func (kp *KafkaProducer) Publish(env ChatEnvelope) {
 		FileType:   env.FileType,
 		FileSize:   env.FileSize,
 		CreatedAt:  time.Now().Unix(),
+		ReplyTo:    env.ReplyTo,
+		RootId:     rootId,
+		Quote:      quote,
+		Mentions:   mentions,
+		Record:     env.Record,
 	}
+	km.ExpireAt = expireAtFor(db, &km)
 
 	raw, err := json.Marshal(km)
 	if err != nil {
 		log.Printf("❌ Kafka marshal error: %v", err)
-		return
+		return "", 0, errPublishFailed
 	}
 
-	_, _, err = kp.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: kp.topic,
-		Key:   sarama.StringEncoder(env.ReceiveId), // 保证同会话有序
-		Value: sarama.ByteEncoder(raw),
-	})
-
-	if err != nil {
+	// receiveId 作为 key，保证同会话有序
+	if err = kp.bus.Publish(env.ReceiveId, raw); err != nil {
 		log.Printf("❌ Kafka send error: %v", err)
+		return "", 0, errPublishFailed
 	}
+	return km.MsgId, km.CreatedAt, nil
 }
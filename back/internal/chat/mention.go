@@ -0,0 +1,224 @@
+// ============================================================
+// 文件：back/internal/chat/mention.go
+// 作用：群聊 @ 提醒：发送时整理 mentions 列表，写库时建立提醒索引，
+//       分发时给被 @ 的人单独推一条 mention 事件。
+//
+// mentions 从哪里来？
+//   · 前端在输入框里选择成员时，把成员 uuid 放进 mentions 数组（正文里只是 "@昵称" 文本）
+//   · 特殊值 "all" 表示 @所有人；群主/管理员直接在正文里写 "@所有人" / "@all" 也算。
+//     正文里的写法必须是独立的词："@alliance"、"bob@all.com" 这类不算（见 contentMentionsAll）
+//   Publish 时由 resolveMentions 统一整理：去重、去掉自己、过滤掉非群成员，
+//   结果写进 KafkaMessage.Mentions，后面的持久化和分发都只认这个列表。
+//
+// 权限：
+//   @所有人 只有群主和管理员可以用，按 env.SendId 查角色；SendId 由 client.go 固定为连接的登录身份，
+//   前端传的 sendId 不起作用。普通成员显式传 "all" 会被拒绝发送，
+//   正文里写的 "@所有人" 则只当普通文本，不触发提醒。
+//
+// 为什么要单独推 mention 事件？
+//   群消息走 dispatchToGroup，只推给发过 join_group 的在线连接。
+//   大群里很多人不会一直订阅所有群，被 @ 时仍然要收到提醒，
+//   所以这里直接按用户投递（DeliverToUser，跨节点也能送达）。
+//   对这个群开了免打扰的人不推（见 session_pref.go），消息里的 mentions 字段照旧保留。
+// ============================================================
+
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MentionAll 是 mentions 列表里表示"@所有人"的特殊值
+const MentionAll = "all"
+
+// maxMentions 限制一条消息最多 @ 的人数（不含 @所有人）
+const maxMentions = 50
+
+var errMentionAllDenied = errors.New("只有群主和管理员可以@所有人")
+
+// mentionAllKeywords 是正文里等价于 @所有人 的写法
+var mentionAllKeywords = []string{"@所有人", "@all"}
+
+// resolveMentions 整理 env.Mentions，返回写入 KafkaMessage 的最终列表。
+func resolveMentions(db *gorm.DB, env ChatEnvelope) ([]string, error) {
+	if !isGroup(env.ReceiveId) {
+		return nil, nil
+	}
+
+	explicitAll := false
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range env.Mentions {
+		id = strings.TrimSpace(id)
+		switch {
+		case id == MentionAll:
+			explicitAll = true
+		case id == "" || id == env.SendId || seen[id]:
+		default:
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	keywordAll := contentMentionsAll(env.Content)
+	if !explicitAll && !keywordAll && len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > maxMentions {
+		return nil, errors.New("一条消息最多@50人")
+	}
+
+	var result []string
+	if explicitAll || keywordAll {
+		var sender model.GroupMember
+		if err := db.Select("role").
+			Where("group_id = ? AND user_id = ?", env.ReceiveId, env.SendId).
+			First(&sender).Error; err != nil {
+			return nil, errNotInGroup
+		}
+		if sender.Role >= model.GroupRoleAdmin {
+			result = append(result, MentionAll)
+		} else if explicitAll {
+			return nil, errMentionAllDenied
+		}
+	}
+
+	if len(ids) > 0 {
+		var members []string
+		if err := db.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id IN ?", env.ReceiveId, ids).
+			Pluck("user_id", &members).Error; err != nil {
+			return nil, err
+		}
+		inGroup := make(map[string]bool, len(members))
+		for _, m := range members {
+			inGroup[m] = true
+		}
+		for _, id := range ids {
+			if inGroup[id] {
+				result = append(result, id)
+			}
+		}
+	}
+	return result, nil
+}
+
+// contentMentionsAll 判断正文里有没有独立出现的 "@所有人" / "@all"。
+// @ 前面紧挨着字母数字（邮箱地址）不算；"@all" 后面紧跟字母数字，或者 "."、"-" 再跟字母数字
+// （"@alliance"、"@all.com"）也不算。中文前后不需要空格，"大家@all看下" 算。
+func contentMentionsAll(content string) bool {
+	for _, kw := range mentionAllKeywords {
+		for from := 0; ; {
+			i := strings.Index(content[from:], kw)
+			if i < 0 {
+				break
+			}
+			start, end := from+i, from+i+len(kw)
+			from = end
+			if start > 0 && isWordByte(content[start-1]) {
+				continue
+			}
+			if kw == "@all" && end < len(content) {
+				next := content[end]
+				if isWordByte(next) {
+					continue
+				}
+				if (next == '.' || next == '-') && end+1 < len(content) && isWordByte(content[end+1]) {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// isWordByte 判断是否是 ASCII 字母、数字或下划线（中文等多字节字符不算）
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// mentionsAll 判断 mentions 里是否有 @所有人
+func mentionsAll(mentions []string) bool {
+	for _, id := range mentions {
+		if id == MentionAll {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionTargets 把 mentions 展开成需要提醒的用户列表（@所有人 展开为全体成员，发送者除外）。
+func mentionTargets(db *gorm.DB, km *KafkaMessage) ([]string, error) {
+	if len(km.Mentions) == 0 {
+		return nil, nil
+	}
+	if !mentionsAll(km.Mentions) {
+		return km.Mentions, nil
+	}
+	var ids []string
+	err := db.Model(&model.GroupMember{}).
+		Where("group_id = ? AND user_id <> ?", km.ReceiveId, km.SendId).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// saveMentions 建立提醒索引，在 persistMessage 的事务里调用。
+func saveMentions(tx *gorm.DB, km *KafkaMessage) error {
+	targets, err := mentionTargets(tx, km)
+	if err != nil || len(targets) == 0 {
+		return err
+	}
+
+	var isAll int8
+	if mentionsAll(km.Mentions) {
+		isAll = 1
+	}
+	createdAt := time.Unix(km.CreatedAt, 0)
+	rows := make([]model.MessageMention, 0, len(targets))
+	for _, uid := range targets {
+		rows = append(rows, model.MessageMention{
+			MsgUuid:   km.MsgId,
+			UserId:    uid,
+			GroupId:   km.ReceiveId,
+			SendId:    km.SendId,
+			IsAll:     isAll,
+			CreatedAt: createdAt,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, 500).Error
+}
+
+// pushMentions 给被 @ 的用户推 mention 事件，不要求对方订阅过该群；免打扰的用户跳过。
+// 不在线的人另外记离线通知（见 offline_notice.go）。
+func pushMentions(db *gorm.DB, km *KafkaMessage) {
+	targets, err := mentionTargets(db, km)
+	if err != nil || len(targets) == 0 {
+		return
+	}
+	muted := mutedAmong(db, km.ReceiveId, targets)
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":    "mention",
+		"msgId":     km.MsgId,
+		"groupId":   km.ReceiveId,
+		"sendId":    km.SendId,
+		"sendName":  km.SendName,
+		"content":   km.Content,
+		"isAll":     mentionsAll(km.Mentions),
+		"createdAt": km.CreatedAt,
+	})
+	for _, uid := range targets {
+		if !muted[uid] {
+			ChatServer.DeliverToUser(uid, raw)
+		}
+	}
+	recordOfflineNotices(db, km, model.OfflineNoticeMention, km.ReceiveId, targets)
+}
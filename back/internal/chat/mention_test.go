@@ -0,0 +1,33 @@
+package chat
+
+import "testing"
+
+func TestContentMentionsAll(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"@all", true},
+		{"@all 开会了", true},
+		{"大家@all看下", true},
+		{"明天放假 @all", true},
+		{"收到请回复 @all.", true},
+		{"@all, 请看公告", true},
+		{"@所有人", true},
+		{"大家注意@所有人", true},
+		{"@alliance", false},
+		{"@all.com", false},
+		{"bob@all.com", false},
+		{"@all-hands", false},
+		{"@all_team", false},
+		{"x@所有人", false},
+		{"@alliance 和 @all", true},
+		{"没有提醒", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := contentMentionsAll(tt.content); got != tt.want {
+			t.Errorf("contentMentionsAll(%q) = %v，期望 %v", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// ============================================================
+// 文件：back/internal/chat/message_bus.go
+// 作用：定义消息总线抽象（MessageBus），把"发布消息 / 按消费者组订阅"
+//       这两件事从具体的 Kafka 客户端（sarama）中解耦出来。
+//
+// 为什么要抽象一层？
+//   原来 Producer 和三个 Consumer 都直接依赖 sarama，本地开发或集成测试时
+//   必须先拉起一个 Kafka broker，连不上就直接 Fatal。
+//   抽象成接口后，主链路 "WebSocket → 总线 → Dispatcher/Persist/Cache" 不变，
+//   只是"总线"可以换成不同实现：
+//     · kafka   —— 生产环境，消息持久化、可回放、多实例共享消费进度（bus_kafka.go）
+//     · channel —— 进程内 Go channel，无需任何外部依赖，适合开发和测试（bus_channel.go）
+//   通过 config.toml 里的 kafkaConfig.messageMode 选择。
+//
+// 消费者组语义（两种实现保持一致）：
+//   同一条消息会投递给"每一个"消费者组各一份（dispatcher / persist / cache 各收一份），
+//   同一个消费者组内部只处理一次。这正是 Kafka ConsumerGroup 的语义。
+// ============================================================
+
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"chatapp/back/internal/config"
+)
+
+// StartOffset 描述一个消费者组"第一次"订阅时从哪里开始读。
+// 只对 Kafka 有意义；进程内总线没有历史可回放，总是从订阅那一刻开始。
+type StartOffset int
+
+const (
+	StartNewest StartOffset = iota // 只处理订阅之后的新消息（实时推送、缓存）
+	StartOldest                    // 从最早未提交的位置开始（持久化，要求不丢）
+)
+
+// MessageHandler 处理总线上的一条原始消息。
+// 返回 error 表示"没有处理成功"：Kafka 实现下不会提交 offset，交给重投递兜底。
+type MessageHandler func(value []byte) error
+
+// MessageBus 是消息主链路依赖的最小能力集合。
+type MessageBus interface {
+	// Publish 同步发布一条消息，key 相同的消息保证有序。
+	// 返回 nil 表示总线已经接收（Kafka 下即 broker 已确认）。
+	Publish(key string, value []byte) error
+	// Subscribe 以消费者组身份订阅总线，handler 在后台 goroutine 中被逐条调用。
+	Subscribe(group string, start StartOffset, handler MessageHandler)
+	// Close 释放底层连接。
+	Close() error
+}
+
+// 消息模式，对应 kafkaConfig.messageMode。
+const (
+	MessageModeKafka   = "kafka"
+	MessageModeChannel = "channel"
+)
+
+// ChatBus 是全局唯一的消息总线，由 InitMessageBus 在启动时创建。
+var ChatBus MessageBus
+
+// InitMessageBus 根据配置创建消息总线，并顺带初始化 ChatKafkaProducer。
+// 必须在 Start*Consumer 之前调用。
+func InitMessageBus(cfg config.KafkaConfig) error {
+	mode := strings.ToLower(strings.TrimSpace(cfg.MessageMode))
+
+	var (
+		bus MessageBus
+		err error
+	)
+	switch mode {
+	case MessageModeKafka:
+		bus, err = newKafkaBus([]string{cfg.HostPort}, cfg.ChatTopic)
+	case MessageModeChannel:
+		bus = newChannelBus(channelBusBuffer)
+	default:
+		return fmt.Errorf("未知的 messageMode: %q（可选 kafka / channel）", cfg.MessageMode)
+	}
+	if err != nil {
+		return err
+	}
+
+	ChatBus = bus
+	ChatKafkaProducer = &KafkaProducer{bus: bus}
+	slog.Info("message_bus_ready", "mode", mode, "topic", cfg.ChatTopic)
+	return nil
+}
@@ -0,0 +1,257 @@
+// ============================================================
+// 文件：back/internal/chat/offline_digest.go
+// 作用：离线邮件摘要：用户离线超过一定时长，把期间的私聊消息和 @ 提醒合并成一封邮件发出。
+//
+// 流程（每分钟一次）：
+//   1. 找出"最早一条待通知记录已经超过 offlineDelayMinutes"的用户（见 offline_notice.go），
+//      按最早一条的时间排序、每页 digestBatchUsers 人逐页处理（按 (最早时间, user_id) 翻页），
+//      处于免打扰时段而暂时跳过的人不会占住名额、挡住后面的人
+//   2. 逐个检查：
+//      · 已经上线 → 删除待通知记录，不发
+//      · 关闭了邮件摘要、没有邮箱 → 删除待通知记录，不发
+//      · 正处于免打扰时段 → 先不发，记录保留到时段结束后一起发
+//   3. 抢占：UPDATE offline_notice SET digest_id=本次ID WHERE user_id=? AND digest_id=''
+//      多实例部署时同一行只会被一个实例抢到
+//   4. 按消息的最新状态重新生成摘要（撤回、删除的去掉），通过 utils.SendEmail 发送；
+//      成功后删除这些行，失败则放回（digest_id 清空），下一轮重试
+// 超过 offlineNoticeRetention 的记录（包括发送中途退出、一直没放回的）直接删除，不再提醒。
+//
+// 本地测试：[email] security = "none" 指向 MailHog / smtp4dev 之类的本地 SMTP 服务，
+// 把 offlineDelayMinutes 调小即可在它的网页界面里看到邮件。
+// ============================================================
+
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+	"chatapp/back/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	digestTickInterval     = time.Minute
+	digestBatchUsers       = 100
+	digestDefaultDelay     = 15 * time.Minute
+	digestDefaultMaxItems  = 20
+	offlineNoticeRetention = 72 * time.Hour
+)
+
+// StartOfflineDigest 启动离线邮件摘要任务；配置里没有开启时什么也不做。
+func StartOfflineDigest() {
+	if !config.GetConfig().NotifyConfig.EmailDigest {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(digestTickInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runOfflineDigest()
+		}
+	}()
+	slog.Info("offline_digest_started", "interval", digestTickInterval.String(), "delay", digestDelay().String())
+}
+
+func digestDelay() time.Duration {
+	if m := config.GetConfig().NotifyConfig.OfflineDelayMinutes; m > 0 {
+		return time.Duration(m) * time.Minute
+	}
+	return digestDefaultDelay
+}
+
+func runOfflineDigest() {
+	db := config.GetDB()
+	now := time.Now()
+
+	if err := db.Where("created_at < ?", now.Add(-offlineNoticeRetention)).
+		Delete(&model.OfflineNotice{}).Error; err != nil {
+		slog.Warn("offline_notice_purge_failed", "err", err)
+	}
+
+	var after *dueUser
+	for {
+		users, err := dueDigestUsers(db, now.Add(-digestDelay()), after)
+		if err != nil {
+			slog.Error("offline_digest_query_failed", "err", err)
+			return
+		}
+		for _, u := range users {
+			sendOfflineDigest(db, u.UserId, now)
+		}
+		if len(users) < digestBatchUsers {
+			return
+		}
+		after = &users[len(users)-1]
+	}
+}
+
+// dueUser 是一个到期待发摘要的用户及其最早一条待通知记录的时间
+type dueUser struct {
+	UserId  string
+	FirstAt time.Time
+}
+
+// dueDigestUsers 按 (最早一条记录的时间, user_id) 升序取一页到期用户，after 非空时从它之后开始
+func dueDigestUsers(db *gorm.DB, dueBefore time.Time, after *dueUser) ([]dueUser, error) {
+	q := db.Model(&model.OfflineNotice{}).
+		Select("user_id, MIN(created_at) AS first_at").
+		Where("digest_id = ''").
+		Group("user_id")
+	if after != nil {
+		q = q.Having("MIN(created_at) <= ? AND (MIN(created_at) > ? OR (MIN(created_at) = ? AND user_id > ?))",
+			dueBefore, after.FirstAt, after.FirstAt, after.UserId)
+	} else {
+		q = q.Having("MIN(created_at) <= ?", dueBefore)
+	}
+	var users []dueUser
+	err := q.Order("first_at ASC, user_id ASC").Limit(digestBatchUsers).Scan(&users).Error
+	return users, err
+}
+
+// sendOfflineDigest 给一个用户发送离线摘要
+func sendOfflineDigest(db *gorm.DB, userId string, now time.Time) {
+	if len(ChatServer.onlineAmong([]string{userId})) > 0 {
+		clearOfflineNotices(userId)
+		return
+	}
+
+	var st model.NotifySetting
+	db.Where("user_id = ?", userId).Limit(1).Find(&st)
+	var user model.UserInfo
+	db.Select("uuid", "nickname", "email").Where("uuid = ?", userId).Limit(1).Find(&user)
+	if (st.UserId != "" && st.EmailDigest == 0) || strings.TrimSpace(user.Email) == "" {
+		clearOfflineNotices(userId)
+		return
+	}
+	loc := settingLocation(st)
+	if inQuietHours(st, now.In(loc)) {
+		return
+	}
+
+	digestId := newIDWithPrefix("D")
+	res := db.Model(&model.OfflineNotice{}).
+		Where("user_id = ? AND digest_id = ''", userId).
+		Update("digest_id", digestId)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	var notices []model.OfflineNotice
+	db.Where("digest_id = ?", digestId).Order("created_at ASC, id ASC").Find(&notices)
+	notices = refreshNotices(db, notices)
+	if len(notices) == 0 {
+		db.Where("digest_id = ?", digestId).Delete(&model.OfflineNotice{})
+		return
+	}
+
+	subject, body := buildOfflineDigest(db, user.Nickname, notices, loc)
+	if err := utils.SendEmail(strings.TrimSpace(user.Email), subject, body); err != nil {
+		slog.Warn("offline_digest_send_failed", "user_id", userId, "count", len(notices), "err", err)
+		db.Model(&model.OfflineNotice{}).Where("digest_id = ?", digestId).Update("digest_id", "")
+		return
+	}
+	db.Where("digest_id = ?", digestId).Delete(&model.OfflineNotice{})
+	slog.Info("offline_digest_sent", "user_id", userId, "count", len(notices))
+}
+
+// refreshNotices 按消息的最新状态重新生成摘要：已撤回、已删除的消息去掉，编辑过的用新内容
+func refreshNotices(db *gorm.DB, notices []model.OfflineNotice) []model.OfflineNotice {
+	ids := make([]string, 0, len(notices))
+	for _, n := range notices {
+		ids = append(ids, n.MsgId)
+	}
+	var msgs []model.Message
+	db.Select("uuid", "type", "content", "file_name", "is_recalled", "expire_at").
+		Where("uuid IN ?", ids).Find(&msgs)
+	current := make(map[string]*model.Message, len(msgs))
+	for i := range msgs {
+		current[msgs[i].Uuid] = &msgs[i]
+	}
+
+	kept := notices[:0]
+	for _, n := range notices {
+		m, ok := current[n.MsgId]
+		if !ok || m.IsRecalled == 1 {
+			continue
+		}
+		n.Excerpt = offlineExcerpt(m, m.ExpireAt != nil)
+		kept = append(kept, n)
+	}
+	return kept
+}
+
+// buildOfflineDigest 生成邮件标题和正文（纯文本）
+func buildOfflineDigest(db *gorm.DB, nickname string, notices []model.OfflineNotice, loc *time.Location) (string, string) {
+	appName := config.GetConfig().MainConfig.AppName
+	maxItems := config.GetConfig().NotifyConfig.MaxItems
+	if maxItems <= 0 {
+		maxItems = digestDefaultMaxItems
+	}
+
+	var groupIds []string
+	for _, n := range notices {
+		if n.Kind == model.OfflineNoticeMention {
+			groupIds = append(groupIds, n.TargetId)
+		}
+	}
+	groupNames := make(map[string]string)
+	if len(groupIds) > 0 {
+		var groups []model.GroupInfo
+		db.Select("uuid", "name").Where("uuid IN ?", groupIds).Find(&groups)
+		for _, g := range groups {
+			groupNames[g.Uuid] = g.Name
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s，你好：\n\n你离线期间有 %d 条新消息：\n\n", nickname, len(notices))
+	for i, n := range notices {
+		if i == maxItems {
+			fmt.Fprintf(&b, "……还有 %d 条消息未列出\n", len(notices)-maxItems)
+			break
+		}
+		at := n.CreatedAt.In(loc).Format("01-02 15:04")
+		if n.Kind == model.OfflineNoticeMention {
+			fmt.Fprintf(&b, "[群聊 %s] %s @了你（%s）：%s\n", groupNames[n.TargetId], n.SendName, at, n.Excerpt)
+		} else {
+			fmt.Fprintf(&b, "[私聊] %s（%s）：%s\n", n.SendName, at, n.Excerpt)
+		}
+	}
+	fmt.Fprintf(&b, "\n登录 %s 查看完整消息。不想再收到这类邮件，可以在设置里关闭离线邮件提醒。\n", appName)
+
+	return fmt.Sprintf("【%s】你有 %d 条未读消息", appName, len(notices)), b.String()
+}
+
+// settingLocation 返回免打扰时段所在的时区，没有设置或无法识别时用服务器时区
+func settingLocation(st model.NotifySetting) *time.Location {
+	if st.TimeZone != "" {
+		if loc, err := time.LoadLocation(st.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// inQuietHours 判断 now（已换算到用户时区）是否在免打扰时段内，时段可以跨零点
+func inQuietHours(st model.NotifySetting, now time.Time) bool {
+	start, err1 := time.Parse("15:04", st.QuietStart)
+	end, err2 := time.Parse("15:04", st.QuietEnd)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	s, e := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	m := now.Hour()*60 + now.Minute()
+	switch {
+	case s == e:
+		return false
+	case s < e:
+		return m >= s && m < e
+	default:
+		return m >= s || m < e
+	}
+}
@@ -0,0 +1,173 @@
+package chat
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+	"chatapp/back/utils"
+)
+
+// smtpMail 是假 SMTP 服务收到的一封邮件
+type smtpMail struct {
+	from, to string
+	data     string
+}
+
+// startFakeSMTP 在本地端口起一个只够 net/smtp 客户端用的明文 SMTP 服务，收到的邮件从返回的 channel 读
+func startFakeSMTP(t *testing.T) (string, int, <-chan smtpMail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan smtpMail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		var m smtpMail
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				m.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				m.to = strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 end with <CRLF>.<CRLF>")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(strings.TrimPrefix(l, "."))
+				}
+				m.data = b.String()
+				reply("250 OK")
+				mails <- m
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, mails
+}
+
+func TestOfflineDigestEmail(t *testing.T) {
+	host, port, mails := startFakeSMTP(t)
+
+	cfg := config.GetConfig()
+	oldEmail, oldMain, oldNotify := cfg.Email, cfg.MainConfig, cfg.NotifyConfig
+	t.Cleanup(func() { cfg.Email, cfg.MainConfig, cfg.NotifyConfig = oldEmail, oldMain, oldNotify })
+	cfg.Email = config.Email{SmtpHost: host, SmtpPort: port, Username: "noreply@example.com", Security: "none"}
+	cfg.MainConfig.AppName = "GoChat"
+	cfg.NotifyConfig.MaxItems = 2
+
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	notices := []model.OfflineNotice{
+		{Kind: model.OfflineNoticeDirect, SendName: "小明", Excerpt: "晚上一起吃饭吗", CreatedAt: at},
+		{Kind: model.OfflineNoticeDirect, SendName: "小红", Excerpt: "[文件] 报告.pdf", CreatedAt: at.Add(time.Minute)},
+		{Kind: model.OfflineNoticeDirect, SendName: "小刚", Excerpt: "收到请回复", CreatedAt: at.Add(2 * time.Minute)},
+	}
+	subject, body := buildOfflineDigest(nil, "张三", notices, time.UTC)
+	if err := utils.SendEmail("zhangsan@example.com", subject, body); err != nil {
+		t.Fatalf("SendEmail: %v", err)
+	}
+
+	var got smtpMail
+	select {
+	case got = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("假 SMTP 服务没有收到邮件")
+	}
+	if got.from != "noreply@example.com" || got.to != "zhangsan@example.com" {
+		t.Fatalf("信封 from=%q to=%q", got.from, got.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("解析邮件: %v", err)
+	}
+	gotSubject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("解码标题: %v", err)
+	}
+	if want := "【GoChat】你有 3 条未读消息"; gotSubject != want {
+		t.Errorf("标题 = %q，期望 %q", gotSubject, want)
+	}
+	raw, _ := io.ReadAll(msg.Body)
+	gotBody := string(raw)
+	for _, want := range []string{
+		"张三，你好",
+		"[私聊] 小明（03-01 09:30）：晚上一起吃饭吗",
+		"[私聊] 小红（03-01 09:31）：[文件] 报告.pdf",
+		"……还有 1 条消息未列出",
+	} {
+		if !strings.Contains(gotBody, want) {
+			t.Errorf("正文缺少 %q：\n%s", want, gotBody)
+		}
+	}
+	if strings.Contains(gotBody, "收到请回复") {
+		t.Errorf("超过 maxItems 的消息不应列出：\n%s", gotBody)
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		now        string
+		want       bool
+	}{
+		{"未设置", "", "", "23:00", false},
+		{"起止相同", "22:00", "22:00", "22:00", false},
+		{"当天时段内", "12:00", "14:00", "13:59", true},
+		{"当天时段结束", "12:00", "14:00", "14:00", false},
+		{"跨零点 开始", "22:00", "07:00", "22:00", true},
+		{"跨零点 凌晨", "22:00", "07:00", "03:15", true},
+		{"跨零点 白天", "22:00", "07:00", "12:00", false},
+		{"格式错误", "25:00", "07:00", "03:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now, _ := time.Parse("15:04", tt.now)
+			st := model.NotifySetting{QuietStart: tt.start, QuietEnd: tt.end}
+			if got := inQuietHours(st, now); got != tt.want {
+				t.Errorf("inQuietHours(%s-%s, %s) = %v，期望 %v", tt.start, tt.end, tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// ============================================================
+// 文件：back/internal/chat/offline_notice.go
+// 作用：记录"用户离线、没有收到"的私聊消息和 @ 提醒，供离线邮件摘要使用（见 offline_digest.go）。
+//
+// DeliverToUser 对没有连接的用户什么也不做，消息只能等他上线后补发。
+// 这里在推送的同时把这类消息记进 offline_notice 表（见 model/offline_notice.go）：
+//   · 私聊：本节点和其它节点上接收方都没有连接
+//   · @ 提醒：被 @ 的人（含 @所有人）都没有连接
+//   · 收件人对这个会话开了免打扰时不记录（见 session_pref.go）
+//   · 配置 [notifyConfig] emailDigest 关闭时什么也不记
+// 用户上线（本节点第一个连接）时删除他全部待通知的记录，避免上线后又收到邮件。
+// 消息撤回、阅后即焚到期删除时，对应的记录也一起删除（见 service.RecallMessage、disappearing.go）；
+// 发送摘要前还会按消息的最新状态重新生成摘要，兜住记录晚于撤回写入的情况。
+// ============================================================
+
+package chat
+
+import (
+	"log/slog"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const offlineExcerptMaxRunes = 100
+
+// recordOfflineNotices 给 userIds 里当前不在线、没有免打扰的人记录离线通知。
+// conv 是收件人视角的会话：私聊是发送者，群聊是群。
+func recordOfflineNotices(db *gorm.DB, km *KafkaMessage, kind int8, conv string, userIds []string) {
+	if !config.GetConfig().NotifyConfig.EmailDigest || km.Type == msgTypeSystem || len(userIds) == 0 {
+		return
+	}
+
+	online := make(map[string]bool)
+	for _, uid := range ChatServer.onlineAmong(userIds) {
+		online[uid] = true
+	}
+	var offline []string
+	for _, uid := range userIds {
+		if !online[uid] {
+			offline = append(offline, uid)
+		}
+	}
+	muted := mutedAmong(db, conv, offline)
+
+	excerpt := offlineExcerpt(&model.Message{Type: km.Type, Content: km.Content, FileName: km.FileName}, km.ExpireAt > 0)
+	createdAt := time.Unix(km.CreatedAt, 0)
+	var rows []model.OfflineNotice
+	for _, uid := range offline {
+		if muted[uid] {
+			continue
+		}
+		rows = append(rows, model.OfflineNotice{
+			UserId:    uid,
+			MsgId:     km.MsgId,
+			Kind:      kind,
+			SendId:    km.SendId,
+			SendName:  km.SendName,
+			TargetId:  conv,
+			Excerpt:   excerpt,
+			CreatedAt: createdAt,
+		})
+	}
+	if len(rows) == 0 {
+		return
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, 500).Error; err != nil {
+		slog.Warn("offline_notice_record_failed", "msg_id", km.MsgId, "count", len(rows), "err", err)
+	}
+}
+
+// clearOfflineNotices 用户上线后删除他全部待通知的记录（已被摘要任务抢占的不动）
+func clearOfflineNotices(userId string) {
+	if !config.GetConfig().NotifyConfig.EmailDigest {
+		return
+	}
+	if err := config.GetDB().
+		Where("user_id = ? AND digest_id = ''", userId).
+		Delete(&model.OfflineNotice{}).Error; err != nil {
+		slog.Warn("offline_notice_clear_failed", "user_id", userId, "err", err)
+	}
+}
+
+// offlineExcerpt 生成邮件里的消息摘要；阅后即焚的消息不出现内容
+func offlineExcerpt(m *model.Message, disappearing bool) string {
+	if disappearing {
+		return "[阅后即焚消息]"
+	}
+	q := resp.NewMessageQuote(m)
+	r := []rune(q.Excerpt)
+	if len(r) > offlineExcerptMaxRunes {
+		return string(r[:offlineExcerptMaxRunes]) + "…"
+	}
+	return q.Excerpt
+}
@@ -8,7 +8,9 @@
 //      · 私聊：确保 (sendId, recvId) 这对组合有会话
 //      · 群聊：确保 (sendId, groupId) 有会话
 //      如果不存在则自动创建，如果已存在则直接返回 sessionId
-//   3. 构造 model.Message 并写入数据库
+//   3. 构造 model.Message 并写入数据库，同一事务内给每个接收者分配序号 seq（见 sync.go）
+//      带 @ 的群消息也在这个事务里写入 message_mention 提醒索引（见 mention.go）
+//   4. 事务提交后推送 msg_seq，前端据此记录自己已同步到哪里，并写入全文检索索引（见 search 包）
 //
 // 为什么消息写入时要先"确保会话"？
 //   前端的"会话列表"是从 session 表查出来的。
@@ -27,6 +29,7 @@ import (
 
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/model"
+	"chatapp/back/internal/search"
 
 	"gorm.io/gorm"
 )
@@ -75,9 +78,40 @@ func persistMessage(db *gorm.DB, km *KafkaMessage) error {
 		SendName:   senderName,
 		SendAvatar: senderAvatar,
 		ReceiveId:  km.ReceiveId,
-		Status:     1,
+		Status:     MsgStatusSent,
 		CreatedAt:  time.Unix(km.CreatedAt, 0),
+		ReplyTo:    km.ReplyTo,
+		RootId:     km.RootId,
+		Mentions:   km.Mentions,
+		Record:     km.Record,
+	}
+	if km.ExpireAt > 0 {
+		t := time.Unix(km.ExpireAt, 0)
+		msg.ExpireAt = &t
+	}
+
+	// 写消息和分配序号放在同一个事务里：要么都成功，要么都重来
+	var notices []seqNotice
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&msg).Error; err != nil {
+			return err
+		}
+		recipients, err := seqRecipients(tx, km)
+		if err != nil {
+			return err
+		}
+		notices, err = assignSeqs(tx, km.MsgId, recipients)
+		if err != nil {
+			return err
+		}
+		return saveMentions(tx, km)
+	})
+	if err != nil {
+		return err
 	}
 
-	return db.Create(&msg).Error
+	applyDeliveredStatus(db, km.MsgId)
+	pushSeqNotices(km.MsgId, notices)
+	search.IndexMessage(&msg)
+	return nil
 }
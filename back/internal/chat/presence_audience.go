@@ -0,0 +1,131 @@
+// ============================================================
+// 文件：back/internal/chat/presence_audience.go
+// 作用：计算"谁能看到某个用户的在线状态"（受众），并缓存到 Redis。
+//
+// 受众 = 互为好友（双方的 user_contact 记录都在，且谁都没有拉黑谁）
+//      + 至少同在一个群里的其他成员
+// 这个关系是对称的：A 在 B 的受众里，B 也一定在 A 的受众里。
+// 所以新连接收到的 online_users 快照 = 自己的受众里当前在线的人。
+//
+// user_online / user_offline / presence 事件只推给受众里在线的人，
+// 不再遍历全部在线连接：一次上下线的开销只和自己的好友、群友数量有关，
+// 陌生人也无从得知谁在线。
+//
+// 缓存：
+//   Redis KEY = "chat:audience:{userId}"（SET），TTL audienceTTL
+//   受众为空时存一个占位成员，避免没有好友的用户每次都查库。
+//   好友关系、群成员变化时由 service 包删除相关 key（见 service/presence_audience.go），
+//   下次用到时重新计算。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"chatapp/back/internal/config"
+
+	"gorm.io/gorm"
+)
+
+const (
+	audienceKeyPrefix = "chat:audience:"
+	audienceTTL       = 10 * time.Minute
+	audienceEmpty     = "-" // 占位成员：区分"没有缓存"和"受众为空"
+)
+
+// presenceAudience 返回 userId 的在线状态受众，优先读缓存。
+// Redis 不可用时直接查库，不影响连接建立。
+func presenceAudience(userId string) []string {
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := audienceKeyPrefix + userId
+
+	if members, err := rdb.SMembers(ctx, key).Result(); err == nil && len(members) > 0 {
+		ids := make([]string, 0, len(members))
+		for _, m := range members {
+			if m != audienceEmpty {
+				ids = append(ids, m)
+			}
+		}
+		return ids
+	}
+
+	ids := loadAudience(config.GetDB(), userId)
+
+	values := make([]interface{}, 0, len(ids)+1)
+	values = append(values, audienceEmpty)
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	pipe := rdb.Pipeline()
+	pipe.Del(ctx, key)
+	pipe.SAdd(ctx, key, values...)
+	pipe.Expire(ctx, key, audienceTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("presence_audience_cache_failed", "user_id", userId, "err", err)
+	}
+	return ids
+}
+
+// loadAudience 从数据库计算受众：互为好友的人 + 同群的其他成员（去重，不含自己）
+func loadAudience(db *gorm.DB, userId string) []string {
+	var contacts []string
+	if err := db.Table("user_contact AS a").
+		Joins("JOIN user_contact AS b ON b.user_id = a.contact_id AND b.contact_id = a.user_id "+
+			"AND b.contact_type = 0 AND b.status = 0 AND b.deleted_at IS NULL").
+		Where("a.user_id = ? AND a.contact_type = 0 AND a.status = 0 AND a.deleted_at IS NULL", userId).
+		Pluck("a.contact_id", &contacts).Error; err != nil {
+		slog.Warn("presence_audience_contacts_failed", "user_id", userId, "err", err)
+	}
+
+	var peers []string
+	if err := db.Table("group_member AS m").
+		Joins("JOIN group_member AS o ON o.group_id = m.group_id").
+		Where("m.user_id = ? AND o.user_id <> ?", userId, userId).
+		Distinct().
+		Pluck("o.user_id", &peers).Error; err != nil {
+		slog.Warn("presence_audience_groups_failed", "user_id", userId, "err", err)
+	}
+
+	seen := make(map[string]bool, len(contacts)+len(peers))
+	ids := make([]string, 0, len(contacts)+len(peers))
+	for _, list := range [][]string{contacts, peers} {
+		for _, id := range list {
+			if id == "" || id == userId || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// onlineAmong 从 userIds 里挑出当前在线（任意节点上有连接）的用户
+func (s *Server) onlineAmong(userIds []string) []string {
+	if len(userIds) == 0 {
+		return nil
+	}
+	online := make([]string, 0, len(userIds))
+	var rest []string
+	s.Mutex.Lock()
+	for _, uid := range userIds {
+		if len(s.Clients[uid]) > 0 {
+			online = append(online, uid)
+		} else {
+			rest = append(rest, uid)
+		}
+	}
+	s.Mutex.Unlock()
+	return append(online, remoteOnline(rest)...)
+}
+
+// notifyAudience 把一条在线状态事件推给 userId 的受众里当前在线的人
+func (s *Server) notifyAudience(userId string, raw []byte) {
+	for _, uid := range s.onlineAmong(presenceAudience(userId)) {
+		s.DeliverToUser(uid, raw)
+	}
+}
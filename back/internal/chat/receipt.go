@@ -0,0 +1,127 @@
+// ============================================================
+// 文件：back/internal/chat/receipt.go
+// 作用：发送确认（ack / nack）与送达回执（delivered）。
+//
+// 前端需要区分三种状态：发送中 → 已发送 → 已送达，以及"发送失败"。
+//
+//   ack / nack（按 localId 对应，只回给发消息的那条连接）：
+//     Client.Read 把消息交给消息总线后立即回复：
+//       成功 {"action":"ack","localId":...,"msgId":...,"createdAt":...}
+//       失败 {"action":"nack","localId":...,"error":"..."}
+//     ack 表示 broker 已经接收（Kafka 下即所有副本写入），消息不会再丢；
+//     nack 表示这条消息没有进入主链路，前端应显示"发送失败"并允许重发。
+//
+//   delivered（推给发送方的所有连接）：
+//     dispatcher 把消息放进"至少一个接收方连接"的下行队列后，视为已送达：
+//       {"action":"delivered","msgId":...,"receiveId":...,"deliveredAt":...}
+//     接收方可能连在其它节点上，所以回执信息（deliveryReceipt）会随转发帧一起带过去，
+//     由真正投递成功的节点来触发。
+//     同时把 message.status 更新为 2（已送达）。
+//
+// 送达只触发一次：
+//   群聊里可能有很多成员、多个节点同时投递成功，
+//   用 Redis SETNX "chat:msg:delivered:{msgId}" 保证回执只发一次。
+//
+// 送达和持久化的先后：
+//   dispatcher 和 persist 是两个独立的消费者，送达可能发生在消息写库之前，
+//   此时 UPDATE 命中 0 行。所以 persistMessage 提交后会再查一次这个标记（applyDeliveredStatus），
+//   两边各自"先写后查"，无论谁先谁后，状态都不会丢。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	deliveredKeyPrefix = "chat:msg:delivered:"
+	deliveredKeyTTL    = 24 * time.Hour
+)
+
+// 消息状态，对应 model.Message.Status
+const (
+	MsgStatusUnsent    int8 = 0
+	MsgStatusSent      int8 = 1
+	MsgStatusDelivered int8 = 2
+)
+
+// deliveryReceipt 随消息帧一起传递：投递给非发送方的连接成功后，据此回执"已送达"。
+type deliveryReceipt struct {
+	MsgId     string `json:"msgId"`
+	SendId    string `json:"sendId"`
+	ReceiveId string `json:"receiveId"`
+}
+
+// sendAck 把发布结果回给发消息的连接。
+func (c *Client) sendAck(localId, msgId string, createdAt int64, err error) {
+	var frame map[string]interface{}
+	if err != nil {
+		frame = map[string]interface{}{
+			"action":  "nack",
+			"localId": localId,
+			"error":   err.Error(),
+		}
+	} else {
+		frame = map[string]interface{}{
+			"action":    "ack",
+			"localId":   localId,
+			"msgId":     msgId,
+			"createdAt": createdAt,
+		}
+	}
+	raw, _ := json.Marshal(frame)
+	if !c.push(raw) {
+		slog.Warn("ack_dropped", "user_id", c.Uuid, "local_id", localId)
+	}
+}
+
+// markDelivered 记录消息已送达：只有第一次调用会更新数据库并通知发送方。
+func markDelivered(rc *deliveryReceipt) {
+	if rc == nil || rc.MsgId == "" {
+		return
+	}
+	ctx := context.Background()
+	first, err := config.GetRedis().SetNX(ctx, deliveredKeyPrefix+rc.MsgId, 1, deliveredKeyTTL).Result()
+	if err != nil {
+		slog.Warn("delivered_flag_failed", "msg_id", rc.MsgId, "err", err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	now := time.Now()
+	if err := config.GetDB().Model(&model.Message{}).
+		Where("uuid = ? AND status < ?", rc.MsgId, MsgStatusDelivered).
+		Update("status", MsgStatusDelivered).Error; err != nil {
+		slog.Warn("delivered_status_update_failed", "msg_id", rc.MsgId, "err", err)
+	}
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":      "delivered",
+		"msgId":       rc.MsgId,
+		"receiveId":   rc.ReceiveId,
+		"deliveredAt": now.Unix(),
+	})
+	ChatServer.DeliverToUser(rc.SendId, raw)
+}
+
+// applyDeliveredStatus 在消息写库后补上"写库前就已送达"的状态。
+func applyDeliveredStatus(db *gorm.DB, msgId string) {
+	cnt, err := config.GetRedis().Exists(context.Background(), deliveredKeyPrefix+msgId).Result()
+	if err != nil || cnt == 0 {
+		return
+	}
+	db.Model(&model.Message{}).
+		Where("uuid = ? AND status < ?", msgId, MsgStatusDelivered).
+		Update("status", MsgStatusDelivered)
+}
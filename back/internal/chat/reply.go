@@ -0,0 +1,52 @@
+// ============================================================
+// 文件：back/internal/chat/reply.go
+// 作用：回复（引用）消息：发送时校验被引用的消息，生成引用快照，确定所属话题。
+//
+// 话题（thread）的组织方式：
+//   每条回复都记录两个字段：
+//     reply_to —— 直接引用的那条消息
+//     root_id  —— 话题的根消息：引用的是普通消息时就是它本身，
+//                 引用的是一条回复时沿用那条回复的 root_id
+//   这样"列出某个话题的全部回复"只需要 WHERE root_id = ?，不用递归查询。
+//
+// 校验规则：
+//   被引用的消息必须存在、未撤回，且和新消息属于同一个会话（同一个群 / 同一对用户），
+//   防止通过 replyTo 把别的会话里的内容"引用"出来。
+// ============================================================
+
+package chat
+
+import (
+	"errors"
+
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// resolveReply 校验 env.ReplyTo 并返回 (rootId, 引用快照)。没有 replyTo 时返回空。
+func resolveReply(db *gorm.DB, env ChatEnvelope) (string, *resp.MessageQuote, error) {
+	if env.ReplyTo == "" {
+		return "", nil, nil
+	}
+
+	var parent model.Message
+	if err := db.Where("uuid = ?", env.ReplyTo).First(&parent).Error; err != nil {
+		return "", nil, errors.New("引用的消息不存在")
+	}
+	if parent.IsRecalled == 1 {
+		return "", nil, errors.New("引用的消息已撤回")
+	}
+	same := buildSessionId(&KafkaMessage{SendId: parent.SendId, ReceiveId: parent.ReceiveId}) ==
+		buildSessionId(&KafkaMessage{SendId: env.SendId, ReceiveId: env.ReceiveId})
+	if !same {
+		return "", nil, errors.New("只能引用当前会话中的消息")
+	}
+
+	rootId := parent.RootId
+	if rootId == "" {
+		rootId = parent.Uuid
+	}
+	return rootId, resp.NewMessageQuote(&parent), nil
+}
@@ -0,0 +1,206 @@
+// ============================================================
+// 文件：back/internal/chat/scheduler.go
+// 作用：定时消息调度器：定期找出到期的 scheduled_message，通过 KafkaProducer.Publish 发出。
+//
+// 为什么走 Publish 而不是直接写库？
+//   Publish 之后的链路（持久化 / 缓存 / 实时推送 / 送达回执 / seq 分配）和实时发送完全一样，
+//   发送权限（群成员、禁言）、引用、@ 也在发送那一刻按最新状态校验。
+//
+// 防重复发送（多实例部署）：
+//   1. 抢占：UPDATE ... SET status=发送中, locked_until=now+lease WHERE uuid=? AND (待发送 或 抢占已过期)
+//      RowsAffected=1 的实例才继续，其它实例跳过
+//   2. 发布标记：Publish 之前 SETNX "chat:scheduled:published:{msgId}"。
+//      持久化消费者按 uuid 去重，但缓存、推送、未读计数这几个消费者不去重，同一条消息发布两次
+//      会被推两次、计两次未读，所以抢占过期后重新抢到的实例先看这个标记：
+//        · 标记已存在、消息已经写库 → 上一次已经发出去了，直接标记为已发送，不再 Publish
+//        · 标记已存在、消息没有写库 → 上一次在 Publish 之前退出了，重新发布
+//          （lease 远大于写库耗时；极端情况下可能重复推送一次，不会丢消息）
+//      Publish 失败时删除标记，下一轮重试可以正常发布。
+//
+// 重启恢复：
+//   所有状态都在数据库里。发送中途退出的记录，lease 过期后被任意实例重新抢占。
+//
+// 失败处理：
+//   总线暂时不可用（errPublishFailed）最多重试 scheduleMaxAttempts 次；
+//   权限类错误（已退群、被禁言等）直接标记失败。结果通过 WebSocket 通知发送者。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+const (
+	scheduleTickInterval = 5 * time.Second
+	scheduleLease        = time.Minute
+	scheduleBatchSize    = 100
+	scheduleMaxAttempts  = 3
+
+	scheduledPublishedPrefix = "chat:scheduled:published:"
+	scheduledPublishedTTL    = 24 * time.Hour
+)
+
+// StartScheduler 启动定时消息调度器。必须在 InitMessageBus 之后调用。
+func StartScheduler() {
+	go func() {
+		ticker := time.NewTicker(scheduleTickInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runDueScheduled()
+		}
+	}()
+	slog.Info("scheduler_started", "interval", scheduleTickInterval.String())
+}
+
+func runDueScheduled() {
+	db := config.GetDB()
+	now := time.Now()
+
+	var due []model.ScheduledMessage
+	err := db.Where("(status = ? AND send_at <= ?) OR (status = ? AND locked_until < ?)",
+		model.ScheduledStatusPending, now, model.ScheduledStatusSending, now).
+		Order("send_at ASC").
+		Limit(scheduleBatchSize).
+		Find(&due).Error
+	if err != nil {
+		slog.Error("scheduler_query_failed", "err", err)
+		return
+	}
+
+	for i := range due {
+		if claimScheduled(&due[i]) {
+			sendScheduled(&due[i])
+		}
+	}
+}
+
+// claimScheduled 抢占一条定时消息，只有一个实例能成功。
+func claimScheduled(sm *model.ScheduledMessage) bool {
+	now := time.Now()
+	until := now.Add(scheduleLease)
+	res := config.GetDB().Model(&model.ScheduledMessage{}).
+		Where("id = ? AND ((status = ? AND send_at <= ?) OR (status = ? AND locked_until < ?))",
+			sm.Id, model.ScheduledStatusPending, now, model.ScheduledStatusSending, now).
+		Updates(map[string]interface{}{
+			"status":       model.ScheduledStatusSending,
+			"locked_by":    schedulerOwner(),
+			"locked_until": until,
+			"attempts":     sm.Attempts + 1,
+			"updated_at":   now,
+		})
+	if res.Error != nil || res.RowsAffected != 1 {
+		return false
+	}
+	sm.Attempts++
+	return true
+}
+
+func sendScheduled(sm *model.ScheduledMessage) {
+	db := config.GetDB()
+	env := ChatEnvelope{
+		MsgId:     sm.MsgId,
+		Type:      sm.Type,
+		Content:   sm.Content,
+		Url:       sm.Url,
+		FileName:  sm.FileName,
+		FileType:  sm.FileType,
+		FileSize:  sm.FileSize,
+		SendId:    sm.SendId,
+		ReceiveId: sm.ReceiveId,
+		ReplyTo:   sm.ReplyTo,
+		Mentions:  sm.Mentions,
+	}
+
+	if at, ok := alreadyPublished(sm); ok {
+		markScheduledSent(sm, at)
+		return
+	}
+
+	_, createdAt, err := ChatKafkaProducer.Publish(env)
+	if err != nil {
+		config.GetRedis().Del(context.Background(), scheduledPublishedPrefix+sm.MsgId)
+		if errors.Is(err, errPublishFailed) && sm.Attempts < scheduleMaxAttempts {
+			// 总线暂时不可用：放回待发送，下一轮重试
+			db.Model(sm).Updates(map[string]interface{}{
+				"status":       model.ScheduledStatusPending,
+				"locked_until": nil,
+				"updated_at":   time.Now(),
+			})
+			slog.Warn("scheduled_publish_retry", "id", sm.Uuid, "attempts", sm.Attempts, "err", err)
+			return
+		}
+		db.Model(sm).Updates(map[string]interface{}{
+			"status":     model.ScheduledStatusFailed,
+			"error_msg":  err.Error(),
+			"updated_at": time.Now(),
+		})
+		slog.Warn("scheduled_send_failed", "id", sm.Uuid, "send_id", sm.SendId, "err", err)
+		notifyScheduled(sm, "scheduled_failed", 0, err.Error())
+		return
+	}
+
+	markScheduledSent(sm, createdAt)
+}
+
+// alreadyPublished 写入发布标记；标记已存在且消息已经写库时返回消息的发送时间和 true。
+// Redis 不可用时照常发布（宁可重复推送也不丢消息）。
+func alreadyPublished(sm *model.ScheduledMessage) (int64, bool) {
+	ok, err := config.GetRedis().SetNX(context.Background(),
+		scheduledPublishedPrefix+sm.MsgId, schedulerOwner(), scheduledPublishedTTL).Result()
+	if err != nil {
+		slog.Warn("scheduled_mark_failed", "id", sm.Uuid, "msg_id", sm.MsgId, "err", err)
+		return 0, false
+	}
+	if ok {
+		return 0, false
+	}
+	var msg model.Message
+	if err := config.GetDB().Select("created_at").Where("uuid = ?", sm.MsgId).Limit(1).Find(&msg).Error; err != nil || msg.CreatedAt.IsZero() {
+		slog.Warn("scheduled_republish", "id", sm.Uuid, "msg_id", sm.MsgId)
+		return 0, false
+	}
+	return msg.CreatedAt.Unix(), true
+}
+
+// markScheduledSent 标记为已发送并通知发送者
+func markScheduledSent(sm *model.ScheduledMessage, createdAt int64) {
+	config.GetDB().Model(sm).Updates(map[string]interface{}{
+		"status":     model.ScheduledStatusSent,
+		"sent_at":    time.Unix(createdAt, 0),
+		"error_msg":  "",
+		"updated_at": time.Now(),
+	})
+	slog.Info("scheduled_sent", "id", sm.Uuid, "msg_id", sm.MsgId, "send_id", sm.SendId)
+	notifyScheduled(sm, "scheduled_sent", createdAt, "")
+}
+
+// notifyScheduled 把定时消息的发送结果推给发送者
+func notifyScheduled(sm *model.ScheduledMessage, action string, createdAt int64, errMsg string) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":    action,
+		"id":        sm.Uuid,
+		"msgId":     sm.MsgId,
+		"receiveId": sm.ReceiveId,
+		"createdAt": createdAt,
+		"error":     errMsg,
+	})
+	ChatServer.DeliverToUser(sm.SendId, raw)
+}
+
+// schedulerOwner 是写入 locked_by 的实例标识，便于排查问题
+func schedulerOwner() string {
+	if nodeId != "" {
+		return nodeId
+	}
+	host, _ := os.Hostname()
+	return nz(host, "local")
+}
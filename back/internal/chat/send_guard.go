@@ -0,0 +1,62 @@
+// ============================================================
+// 文件：back/internal/chat/send_guard.go
+// 作用：消息进入总线前的发送权限检查。
+//
+// 检查针对 env.SendId：WebSocket 发送时它固定是连接的登录用户（见 Client.Read），
+// 前端无法冒充别人绕过群成员、禁言检查。
+//
+// 群聊：
+//   · 发送者必须是群成员（group_member 表里有记录）
+//   · 发送者没有处于禁言中（group_member.muted_until 晚于当前时间）
+// 消息类型：前端只能发 0 文本 / 1 文件 / 2 通话；
+//   聊天记录消息（type=3）必须带 Record，只能由 /message/forward 生成，前端不能直接发；
+//   系统消息（type=99）只由服务端生成（见 disappearing.go），不经过这里，其它类型一律拒绝。
+// 检查失败时 Publish 直接返回错误，Client.Read 会把原因通过 nack 回给前端。
+//
+// 放在 chat 包而不是 service 包：service 不能被 chat 引用（会循环依赖），
+// 这里只需要一次简单的单表查询，直接查库即可。
+// ============================================================
+
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var errNotInGroup = errors.New("你不在该群聊中")
+
+// msgTypeChatRecord 是合并转发的聊天记录消息类型
+const msgTypeChatRecord int8 = 3
+
+// checkSendAllowed 检查 env 是否允许发送。
+func checkSendAllowed(db *gorm.DB, env ChatEnvelope) error {
+	switch env.Type {
+	case 0, 1, 2:
+	case msgTypeChatRecord:
+		if env.Record == nil {
+			return errors.New("聊天记录消息只能通过转发发送")
+		}
+	default:
+		return errors.New("不支持的消息类型")
+	}
+	if !isGroup(env.ReceiveId) {
+		return nil
+	}
+
+	var m model.GroupMember
+	if err := db.Select("muted_until").
+		Where("group_id = ? AND user_id = ?", env.ReceiveId, env.SendId).
+		First(&m).Error; err != nil {
+		return errNotInGroup
+	}
+	if m.MutedUntil != nil && m.MutedUntil.After(time.Now()) {
+		return fmt.Errorf("你已被禁言，解除时间 %s", m.MutedUntil.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
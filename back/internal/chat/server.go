@@ -15,14 +15,14 @@
 //     内层 value: true（用 bool 作为 Set 的常见 Go 惯用写法）
 //     这是内存中的"群聊在线订阅表"，不是真正的群成员表（那个在数据库里）
 //
-// 在线状态广播机制：
+// 在线状态推送机制（只推给 A 的"受众"：互为好友的人 + 群友，见 presence_audience.go）：
 //   当用户 A 连接时：
 //     1. 把 A 的连接加入 Clients["A的UUID"]
-//     2. 如果是 A 的第一个连接，向所有其他在线用户广播 {"action":"user_online","userId":"A的UUID"}
-//     3. 同时把当前所有在线用户列表发给 A（让 A 的界面立刻显示谁在线）
+//     2. 把受众里当前在线的用户列表发给 A（让 A 的界面立刻显示谁在线）
+//     3. 如果是 A 的第一个连接，向受众里的在线用户推送 {"action":"user_online","userId":"A的UUID"}
 //   当用户 A 断开时：
 //     1. 从 Clients["A的UUID"] 里删掉这条连接
-//     2. 如果 A 所有连接都断了，广播 {"action":"user_offline","userId":"A的UUID"}
+//     2. 如果 A 所有连接都断了，向受众推送 {"action":"user_offline","userId":"A的UUID"}
 //     3. 清除 A 在所有群的订阅记录（groupMembers 里）
 //
 // 为什么消息主链路走 Kafka 而不是直接内存传递？
@@ -45,6 +45,7 @@ import (
 	"time"
 
 	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/resp"
 	"chatapp/back/internal/model"
 
 	"github.com/google/uuid"
@@ -57,6 +58,7 @@ import (
 // WebSocket 层把前端 JSON 解析成它，之后无论是发 Kafka、分流到私聊/群聊，
 // 还是写库，都尽量围绕这个结构展开。
 type ChatEnvelope struct {
+	MsgId     string `json:"msgId,omitempty"` // 预分配的消息ID（定时消息），为空时由 Publish 生成
 	Type      int8   `json:"type"`
 	Content   string `json:"content,omitempty"`
 	Url       string `json:"url,omitempty"`
@@ -66,6 +68,10 @@ type ChatEnvelope struct {
 	SendId    string `json:"sendId"`
 	ReceiveId string `json:"receiveId"`
 	LocalId   string `json:"localId,omitempty"` // 前端生成，用于乐观更新
+	ReplyTo   string `json:"replyTo,omitempty"` // 回复的消息ID
+
+	Mentions []string             `json:"mentions,omitempty"` // 被 @ 的用户，"all" 表示所有人
+	Record   *model.ForwardRecord `json:"record,omitempty"`   // 合并转发的聊天记录（type=3），只能由转发接口生成
 }
 
 // OutgoingMessage 是发回前端的标准消息格式。
@@ -86,6 +92,17 @@ type OutgoingMessage struct {
 	SendAvatar string `json:"sendAvatar"`
 	ReceiveId  string `json:"receiveId"`
 	CreatedAt  int64  `json:"createdAt"`
+	Seq        int64  `json:"seq,omitempty"`        // 仅补发时携带：该消息在接收者序列中的序号
+	IsRecalled int8   `json:"isRecalled,omitempty"` // 仅补发时携带：补发的消息可能已被撤回
+	EditedAt   int64  `json:"editedAt,omitempty"`   // 仅补发时携带：消息最后编辑时间
+	ReplyTo    string `json:"replyTo,omitempty"`    // 回复的消息ID
+	RootId     string `json:"rootId,omitempty"`     // 所在话题的根消息ID
+
+	Quote    *resp.MessageQuote `json:"quote,omitempty"`    // 被引用消息的快照
+	Mentions []string           `json:"mentions,omitempty"` // 被 @ 的用户，"all" 表示所有人
+	ExpireAt int64              `json:"expireAt,omitempty"` // 阅后即焚的过期时间，0 表示永久保留
+
+	Record *model.ForwardRecord `json:"record,omitempty"` // 合并转发的聊天记录（type=3）
 }
 
 // CallSignal 用于 WebRTC 信令转发。
@@ -386,6 +403,23 @@ func (s *Server) deliverToUser(userId string, raw []byte) {
 	}
 }
 
+// PushGroupEvent 向群的全部在线订阅者推送一条系统事件（type=99）。
+// content 是事件名，extra 里的字段会平铺到消息体中，供前端渲染提示。
+func (s *Server) PushGroupEvent(groupId, content string, extra map[string]interface{}) {
+	payload := map[string]interface{}{
+		"uuid":      newIDWithPrefix("SYS"),
+		"type":      99,
+		"receiveId": groupId,
+		"content":   content,
+		"createdAt": time.Now().Unix(),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	raw, _ := json.Marshal(payload)
+	dispatchToGroup(groupId, raw, nil)
+}
+
 // ✅ 推送"群已解散"通知
 func (s *Server) PushGroupDismiss(groupId string) {
 	raw, _ := json.Marshal(map[string]interface{}{
@@ -396,16 +430,8 @@ func (s *Server) PushGroupDismiss(groupId string) {
 		"createdAt": time.Now().Unix(),
 	})
 
-	// ✅ 推给这个群的所有在线成员
-	groupMemsMu.RLock()
-	subs := make(map[string]bool, len(groupMembers[groupId]))
-	for k, v := range groupMembers[groupId] {
-		subs[k] = v
-	}
-	groupMemsMu.RUnlock()
-	for uid := range subs {
-		s.deliverToUser(uid, raw)
-	}
+	// ✅ 推给这个群的所有在线成员（含其它节点上的订阅者）
+	dispatchToGroup(groupId, raw, nil)
 }
 
 // ============== 会话兜底 & 基础查询 ==============
@@ -486,52 +512,59 @@ func nz(s, def string) string {
 }
 
 // AddClient 把一个新的 WebSocket 连接挂到在线表里。
-// 除了保存连接外，它还会主动同步在线用户列表，并在“某用户首次上线”时广播在线事件。
+// 除了保存连接外，它还会把受众里的在线用户同步给新连接，并在“某用户首次上线”时通知受众。
 func (s *Server) AddClient(c *Client) {
-	s.Mutex.Lock()
-
-	// 收集当前在线用户ID（去重）
-	existingIds := make([]string, 0)
-	for uid := range s.Clients {
-		if uid != c.Uuid {
-			existingIds = append(existingIds, uid)
-		}
+	if c.ConnId == "" {
+		c.ConnId = newConnId()
 	}
+	db := config.GetDB()
+	myStatus := loadUserStatus(db, c.Uuid)
+
+	// 受众是对称的：能看到我上线的人，也就是我能看到的人（见 presence_audience.go）
+	// 查库、查 Redis 都放在锁外面，避免拖慢其它连接的推送；隐身的用户不出现在列表里
+	onlineIds := s.onlineAmong(presenceAudience(c.Uuid))
+	visibleIds, statuses := filterVisibleOnline(db, onlineIds)
 
+	s.Mutex.Lock()
 	// 追加连接（不覆盖）
 	s.Clients[c.Uuid] = append(s.Clients[c.Uuid], c)
+	first := len(s.Clients[c.Uuid]) == 1
 	slog.Info("ws_connect", "user_id", c.Uuid, "total_conns", totalConnections(s.Clients), "user_conns", len(s.Clients[c.Uuid]))
 
-	// 发送在线用户列表给新客户端（仅包括其他用户，不含自己）
+	// 发送在线用户列表给新客户端（仅包括受众里的其他用户，不含自己）
+	// statuses 只包含设置了非默认状态的用户；myStatus 是自己保存的状态，用于多端同步
 	onlineMsg, _ := json.Marshal(map[string]interface{}{
-		"action":  "online_users",
-		"userIds": existingIds,
+		"action":   "online_users",
+		"userIds":  visibleIds,
+		"statuses": statuses,
+		"myStatus": myStatus,
 	})
 	select {
 	case c.SendBack <- onlineMsg:
 	default:
 	}
+	s.Mutex.Unlock()
+
+	// 登记到跨节点在线表（未开启集群时是空操作）
+	registerPresence(c.Uuid, c.ConnId)
+
+	// 上线后离线期间的消息可以补发，不再需要邮件提醒
+	if first {
+		clearOfflineNotices(c.Uuid)
+	}
 
-	// 如果是该用户的第一个连接，才广播 user_online 给其他用户
-	if len(s.Clients[c.Uuid]) == 1 {
+	// 如果是该用户的第一个连接，才通知受众 user_online；隐身时不通知
+	if first && myStatus.Status != model.UserStatusInvisible {
 		onlineNotify, _ := json.Marshal(map[string]interface{}{
-			"action": "user_online",
-			"userId": c.Uuid,
+			"action":     "user_online",
+			"userId":     c.Uuid,
+			"status":     myStatus.Status,
+			"statusText": myStatus.StatusText,
 		})
-		for uid, clients := range s.Clients {
-			if uid == c.Uuid {
-				continue
-			}
-			for _, client := range clients {
-				select {
-				case client.SendBack <- onlineNotify:
-				default:
-				}
-			}
+		for _, uid := range onlineIds {
+			s.DeliverToUser(uid, onlineNotify)
 		}
 	}
-
-	s.Mutex.Unlock()
 }
 
 // totalConnections 统计所有连接总数（调试用）
@@ -567,7 +600,7 @@ func (s *Server) removeUserFromAllGroups(userId string) {
 }
 
 // RemoveClient 精确移除某一个连接。
-// 如果这已经是该用户最后一个连接，还会触发离线广播。
+// 如果这已经是该用户（所有节点上）最后一个连接，还会通知受众该用户离线。
 func (s *Server) RemoveClient(c *Client) {
 	s.Mutex.Lock()
 
@@ -584,20 +617,6 @@ func (s *Server) RemoveClient(c *Client) {
 		delete(s.Clients, userId)
 		slog.Info("ws_all_disconnected", "user_id", userId)
 		s.removeUserFromAllGroups(userId)
-
-		// 广播 user_offline（只有最后一个连接断开时才广播）
-		offlineNotify, _ := json.Marshal(map[string]interface{}{
-			"action": "user_offline",
-			"userId": userId,
-		})
-		for _, clients := range s.Clients {
-			for _, client := range clients {
-				select {
-				case client.SendBack <- offlineNotify:
-				default:
-				}
-			}
-		}
 	} else {
 		s.Clients[userId] = newConns
 		slog.Info("ws_disconnect_partial", "user_id", userId, "remaining_conns", len(newConns))
@@ -605,54 +624,92 @@ func (s *Server) RemoveClient(c *Client) {
 
 	_ = c.Conn.Close()
 	s.Mutex.Unlock()
+
+	unregisterPresence(userId, c.ConnId)
+	// 集群模式下还要确认其它节点上也没有连接，否则用户在别的节点上仍然在线
+	if !s.IsUserOnline(userId) {
+		stopAllTyping(userId)
+		// 通知受众 user_offline（只有全部节点上的最后一个连接断开时才通知）
+		s.notifyAudience(userId, offlineNotice(userId))
+	}
 }
 
 // RemoveAllClients 强制移除某个用户的所有连接，常见于主动登出或管理员踢下线。
+// 集群模式下还会通知其它节点一起关闭该用户的连接。
 func (s *Server) RemoveAllClients(userId string) {
 	s.Mutex.Lock()
-	conns, ok := s.Clients[userId]
-	if ok {
-		for _, c := range conns {
-			_ = c.Conn.Close()
-		}
-		delete(s.Clients, userId)
-		s.removeUserFromAllGroups(userId)
-		slog.Info("ws_force_removed", "user_id", userId)
-	}
+	connIds := s.closeLocalClientsLocked(userId)
+	s.Mutex.Unlock()
+
+	unregisterPresence(userId, connIds...)
+	broadcastKickToNodes(userId)
+	stopAllTyping(userId)
+	s.notifyAudience(userId, offlineNotice(userId))
+}
 
-	// 广播离线
-	offlineNotify, _ := json.Marshal(map[string]interface{}{
+// offlineNotice 生成推给受众的 user_offline 事件
+func offlineNotice(userId string) []byte {
+	raw, _ := json.Marshal(map[string]interface{}{
 		"action": "user_offline",
 		"userId": userId,
 	})
-	for _, clients := range s.Clients {
-		for _, client := range clients {
-			select {
-			case client.SendBack <- offlineNotify:
-			default:
-			}
-		}
-	}
+	return raw
+}
+
+// removeLocalClients 只关闭本节点上该用户的连接（收到其它节点的踢下线通知时使用）。
+func (s *Server) removeLocalClients(userId string) {
+	s.Mutex.Lock()
+	connIds := s.closeLocalClientsLocked(userId)
 	s.Mutex.Unlock()
+
+	unregisterPresence(userId, connIds...)
+}
+
+// closeLocalClientsLocked 关闭并移除用户的全部本地连接，返回被移除的连接ID。
+// 调用方必须持有 s.Mutex。
+func (s *Server) closeLocalClientsLocked(userId string) []string {
+	conns, ok := s.Clients[userId]
+	if !ok {
+		return nil
+	}
+	connIds := make([]string, 0, len(conns))
+	for _, c := range conns {
+		_ = c.Conn.Close()
+		connIds = append(connIds, c.ConnId)
+	}
+	delete(s.Clients, userId)
+	s.removeUserFromAllGroups(userId)
+	slog.Info("ws_force_removed", "user_id", userId)
+	return connIds
 }
 
 // DeliverToUser 是对外暴露的推送入口，供其它包发送控制消息或系统通知。
+// 先推本节点的连接，再把消息转发给该用户在其它节点上的连接（集群模式）。
 func (s *Server) DeliverToUser(userId string, raw []byte) {
+	s.deliverLocal(userId, raw)
+	forwardToRemoteNodes(userId, raw, nil)
+}
+
+// deliverLocal 只推给本节点上的连接，返回成功放入下行队列的连接数。
+func (s *Server) deliverLocal(userId string, raw []byte) int {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
 	conns, ok := s.Clients[userId]
 	if !ok || len(conns) == 0 {
 		slog.Debug("deliver_user_offline", "user_id", userId)
-		return
+		return 0
 	}
 	slog.Debug("deliver_to_user", "user_id", userId, "conn_count", len(conns))
 
+	delivered := 0
 	for _, c := range conns {
-		select {
-		case c.SendBack <- raw:
-		default:
-			// 下行拥塞保护
+		if c.push(raw) {
+			delivered++
+		} else {
+			// 下行拥塞保护：丢弃的消息可通过 seq 补发找回
+			slog.Debug("deliver_dropped", "user_id", userId, "conn_id", c.ConnId)
 		}
 	}
+	return delivered
 }
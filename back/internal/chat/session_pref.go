@@ -0,0 +1,38 @@
+// ============================================================
+// 文件：back/internal/chat/session_pref.go
+// 作用：推送提醒类事件前，过滤掉对这个会话开了免打扰的用户。
+//
+// 会话偏好保存在 session_pref 表（见 model/session_pref.go，由 service 包维护），
+// 这里只读：muted = 1 且 mute_until 为空（一直免打扰）或还没到。
+// 只影响 mention 这类"提醒"事件；消息本身照常分发，前端据会话列表里的 muted 决定是否弹通知。
+// ============================================================
+
+package chat
+
+import (
+	"log/slog"
+	"time"
+
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// mutedAmong 返回 userIds 里对 targetId 这个会话开了免打扰的用户
+func mutedAmong(db *gorm.DB, targetId string, userIds []string) map[string]bool {
+	if len(userIds) == 0 {
+		return nil
+	}
+	var ids []string
+	if err := db.Model(&model.SessionPref{}).
+		Where("target_id = ? AND user_id IN ? AND muted = 1 AND (mute_until IS NULL OR mute_until > ?)", targetId, userIds, time.Now()).
+		Pluck("user_id", &ids).Error; err != nil {
+		slog.Warn("session_mute_lookup_failed", "target_id", targetId, "err", err)
+		return nil
+	}
+	muted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		muted[id] = true
+	}
+	return muted
+}
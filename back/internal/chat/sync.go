@@ -0,0 +1,318 @@
+// ============================================================
+// 文件：back/internal/chat/sync.go
+// 作用：每用户消息序列号（seq）的分配，以及断线重连后的增量补发。
+//
+// 问题背景：
+//   AddClient 只会推一份 online_users，用户离线期间的消息、
+//   或者因为 SendBack 缓冲满被 deliverLocal 丢弃的消息，都要等用户手动刷新历史才能看到。
+//
+// 序列号怎么分配（assignSeqs，在持久化事务里执行）：
+//   消息写库时，给"所有能看到这条消息的人"各分配一个新的 seq：
+//     私聊 → 发送方 + 接收方
+//     群聊 → 全体群成员
+//   seq 按用户独立递增且连续（1, 2, 3 ...），存在 message_seq 表里。
+//   写库成功后给这些用户推一帧 {"action":"msg_seq","msgId":...,"seq":...}，
+//   前端据此更新自己"已见过的最大 seq"。
+//   大群一条消息要推很多帧，集群模式下按节点合并转发（见 cluster.go 的 deliverToUsers），
+//   不会每个成员各查一次登记表、各发一次 PUBLISH。
+//
+// 补发怎么做（Client.StartSync）：
+//   1. 前端重连时带上 /wss?token=...&since=<最大seq>，
+//      或者在连接上发 {"action":"sync","since":<最大seq>}
+//   2. 服务端把这个连接标记为"补发中"：期间到达的实时消息先暂存在 pending 里
+//   3. 按 seq 升序从 MySQL 分批读出 seq > since 的消息，逐条推给这个连接；
+//      自己"仅对自己删除"的消息和清空会话之前的消息不推，但游标照样越过它们
+//      （条件和 service/message_hide_service.go 的 visibleToAcross 一致）
+//   4. 推一帧 {"action":"sync_done","seq":<最后一条的seq>,"hasMore":false}
+//   5. 放出暂存的实时消息，切换回正常的实时推送
+//   这样前端看到的顺序是"先补齐历史，再接上实时"，中间不会有空洞。
+//   一次补发最多 syncMaxMessages 条，超出时 hasMore=true，前端可以接着发 sync 继续拉。
+// ============================================================
+
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+	"chatapp/back/internal/service"
+
+	"gorm.io/gorm"
+)
+
+const (
+	syncBatchSize    = 200  // 每次查库的条数
+	syncMaxMessages  = 2000 // 单次补发的上限
+	syncPendingLimit = 1000 // 补发期间最多暂存的实时消息数
+)
+
+// seqNotice 是写库成功后推给前端的序号通知。
+type seqNotice struct {
+	UserId string
+	Seq    int64
+}
+
+// seqRecipients 返回能看到这条消息的全部用户（去重）。
+func seqRecipients(tx *gorm.DB, km *KafkaMessage) ([]string, error) {
+	if !isGroup(km.ReceiveId) {
+		if km.ReceiveId == "" || km.ReceiveId == km.SendId {
+			return []string{km.SendId}, nil
+		}
+		return []string{km.SendId, km.ReceiveId}, nil
+	}
+
+	var members []string
+	if err := tx.Model(&model.GroupMember{}).Where("group_id = ?", km.ReceiveId).
+		Pluck("user_id", &members).Error; err != nil {
+		return nil, fmt.Errorf("查询群成员失败: %w", err)
+	}
+	seen := make(map[string]bool, len(members)+1)
+	ids := make([]string, 0, len(members)+1)
+	for _, uid := range append(members, km.SendId) {
+		if uid != "" && !seen[uid] {
+			seen[uid] = true
+			ids = append(ids, uid)
+		}
+	}
+	return ids, nil
+}
+
+// assignSeqs 为每个接收者分配下一个 seq 并写入 message_seq。
+// 必须在事务中调用：user_sequence 的行锁保证同一用户的 seq 不重复、不跳号。
+// 用户ID排序后再加锁，多个节点并发持久化时不会互相死锁。
+func assignSeqs(tx *gorm.DB, msgId string, userIds []string) ([]seqNotice, error) {
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+	ids := append([]string(nil), userIds...)
+	sort.Strings(ids)
+
+	values := strings.TrimSuffix(strings.Repeat("(?, 1),", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if err := tx.Exec(
+		"INSERT INTO user_sequence (user_id, max_seq) VALUES "+values+
+			" ON DUPLICATE KEY UPDATE max_seq = max_seq + 1",
+		args...,
+	).Error; err != nil {
+		return nil, fmt.Errorf("分配序号失败: %w", err)
+	}
+
+	var seqs []model.UserSequence
+	if err := tx.Where("user_id IN ?", ids).Find(&seqs).Error; err != nil {
+		return nil, fmt.Errorf("读取序号失败: %w", err)
+	}
+
+	now := time.Now()
+	rows := make([]model.MessageSeq, 0, len(seqs))
+	notices := make([]seqNotice, 0, len(seqs))
+	for _, s := range seqs {
+		rows = append(rows, model.MessageSeq{UserId: s.UserId, Seq: s.MaxSeq, MsgUuid: msgId, CreatedAt: now})
+		notices = append(notices, seqNotice{UserId: s.UserId, Seq: s.MaxSeq})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, fmt.Errorf("写入消息序号失败: %w", err)
+	}
+	return notices, nil
+}
+
+// pushSeqNotices 把分配好的 seq 推给各自的用户。
+func pushSeqNotices(msgId string, notices []seqNotice) {
+	frames := make(map[string][]byte, len(notices))
+	for _, n := range notices {
+		raw, _ := json.Marshal(map[string]interface{}{
+			"action": "msg_seq",
+			"msgId":  msgId,
+			"seq":    n.Seq,
+		})
+		frames[n.UserId] = raw
+	}
+	ChatServer.deliverToUsers(frames)
+}
+
+// ============== 连接级补发 ==============
+
+// push 把一帧放进下行队列（非阻塞，缓冲满则丢弃）。
+// 连接处于补发中时，实时消息先暂存，等历史补完再按顺序放出。
+func (c *Client) push(raw []byte) bool {
+	c.syncMu.Lock()
+	if c.syncing {
+		if len(c.pending) >= syncPendingLimit {
+			c.syncMu.Unlock()
+			slog.Warn("sync_pending_overflow", "user_id", c.Uuid, "conn_id", c.ConnId)
+			return false
+		}
+		c.pending = append(c.pending, raw)
+		c.syncMu.Unlock()
+		return true
+	}
+	c.syncMu.Unlock()
+
+	select {
+	case c.SendBack <- raw:
+		return true
+	default:
+		return false
+	}
+}
+
+// pushWait 阻塞写入下行队列，最多等待 writeWait；超时说明连接已经卡死或断开。
+func (c *Client) pushWait(raw []byte) bool {
+	select {
+	case c.SendBack <- raw:
+		return true
+	case <-time.After(writeWait):
+		return false
+	}
+}
+
+// StartSync 从 since 之后开始为这个连接补发消息。
+// 应在 AddClient 之前调用，这样注册之后到达的实时消息会排在补发内容之后。
+// 同一连接已经在补发时直接忽略。
+func (c *Client) StartSync(since int64) {
+	c.syncMu.Lock()
+	if c.syncing {
+		c.syncMu.Unlock()
+		return
+	}
+	c.syncing = true
+	c.syncMu.Unlock()
+
+	go func() {
+		defer c.finishSync()
+		c.runSync(since)
+	}()
+}
+
+// runSync 按 seq 升序分批读出缺失的消息并推送，最后推 sync_done。
+func (c *Client) runSync(since int64) {
+	db := config.GetDB()
+	cursor := since
+	sent := 0
+	hasMore := false
+
+	type syncRow struct {
+		model.Message
+		Seq    int64 `gorm:"column:seq"`
+		Hidden bool  `gorm:"column:hidden"`
+	}
+
+	for {
+		var rows []syncRow
+		err := db.Table("message_seq AS s").
+			Select("m.*, s.seq, "+syncHiddenExpr, c.Uuid, c.Uuid, c.Uuid).
+			Joins("JOIN message AS m ON m.uuid = s.msg_uuid").
+			Where("s.user_id = ? AND s.seq > ?", c.Uuid, cursor).
+			Order("s.seq ASC").
+			Limit(syncBatchSize).
+			Scan(&rows).Error
+		if err != nil {
+			slog.Error("sync_query_failed", "user_id", c.Uuid, "since", cursor, "err", err)
+			hasMore = true
+			break
+		}
+
+		var replyIds []string
+		for _, r := range rows {
+			if r.ReplyTo != "" && !r.Hidden {
+				replyIds = append(replyIds, r.ReplyTo)
+			}
+		}
+		quotes := service.LoadMessageQuotes(replyIds)
+
+		for _, r := range rows {
+			if r.Hidden {
+				cursor = r.Seq
+				continue
+			}
+			raw, _ := json.Marshal(OutgoingMessage{
+				Uuid:       r.Uuid,
+				Type:       r.Type,
+				Content:    r.Content,
+				Url:        r.Url,
+				FileName:   r.FileName,
+				FileType:   r.FileType,
+				FileSize:   r.FileSize,
+				SendId:     r.SendId,
+				SendName:   r.SendName,
+				SendAvatar: r.SendAvatar,
+				ReceiveId:  r.ReceiveId,
+				CreatedAt:  r.CreatedAt.Unix(),
+				Seq:        r.Seq,
+				IsRecalled: r.IsRecalled,
+				EditedAt:   unixOrZero(r.EditedAt),
+				ReplyTo:    r.ReplyTo,
+				RootId:     r.RootId,
+				Quote:      quotes[r.ReplyTo],
+				Mentions:   r.Mentions,
+				ExpireAt:   unixOrZero(r.ExpireAt),
+				Record:     r.Record,
+			})
+			if !c.pushWait(raw) {
+				slog.Warn("sync_aborted", "user_id", c.Uuid, "conn_id", c.ConnId, "seq", cursor)
+				return
+			}
+			cursor = r.Seq
+			sent++
+		}
+
+		if len(rows) < syncBatchSize {
+			break
+		}
+		if sent >= syncMaxMessages {
+			hasMore = true
+			break
+		}
+	}
+
+	done, _ := json.Marshal(map[string]interface{}{
+		"action":  "sync_done",
+		"seq":     cursor,
+		"hasMore": hasMore,
+	})
+	c.pushWait(done)
+	slog.Info("sync_done", "user_id", c.Uuid, "conn_id", c.ConnId, "since", since, "sent", sent, "has_more", hasMore)
+}
+
+// syncHiddenExpr 判断补发的消息对本人是否已隐藏：仅对自己删除，或者在清空会话之前。
+// 参数依次是三个 userId。
+const syncHiddenExpr = "(EXISTS (SELECT 1 FROM message_user_state AS mus WHERE mus.user_id = ? AND mus.msg_uuid = m.uuid) " +
+	"OR m.id <= COALESCE((SELECT cc.cleared_msg_id FROM conversation_clear AS cc WHERE cc.user_id = ? " +
+	"AND cc.target_id = CASE WHEN m.receive_id = ? THEN m.send_id ELSE m.receive_id END), 0)) AS hidden"
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// finishSync 放出补发期间暂存的实时消息，然后恢复正常推送。
+// 放出时不持有 syncMu，避免卡住 deliverLocal；放完再检查一次，直到暂存区清空。
+func (c *Client) finishSync() {
+	for {
+		c.syncMu.Lock()
+		batch := c.pending
+		c.pending = nil
+		if len(batch) == 0 {
+			c.syncing = false
+			c.syncMu.Unlock()
+			return
+		}
+		c.syncMu.Unlock()
+
+		for _, raw := range batch {
+			if !c.pushWait(raw) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// ============================================================
+// 文件：back/internal/chat/typing.go
+// 作用："对方正在输入…"：转发 typing_start / typing_stop，并在服务端做超时兜底。
+//
+// 前端约定：
+//   输入框有内容变化时发 {"action":"typing_start","receiveId":对方或群ID}，
+//   可以每隔几秒重复发送来"续期"；清空输入框或发出消息时发 typing_stop。
+//
+// 服务端：
+//   · 只推给会话参与者：私聊 → 对方；群聊 → 群的在线订阅者（前端忽略自己的事件）
+//   · 同一个人在同一个会话里重复 typing_start 只续期，不重复推送
+//   · typingTimeout 内没有续期（前端崩溃、断网、切走页面），服务端自动推 typing_stop
+//   · 发出消息、最后一个连接断开时也会自动结束输入状态
+//   · 第一次 typing_start 时校验权限（群成员 / 好友），避免给陌生人推送
+//
+// 输入状态只保存在本节点内存里：同一个用户的多个连接可能在不同节点，
+// 各节点各自维护自己收到的那部分，超时机制保证不会残留。
+// ============================================================
+
+package chat
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+const typingTimeout = 6 * time.Second
+
+var (
+	typingMu     sync.Mutex
+	typingTimers = make(map[string]*time.Timer) // "userId|receiveId" -> 超时定时器
+)
+
+func typingKey(userId, receiveId string) string {
+	return userId + "|" + receiveId
+}
+
+// startTyping 处理 typing_start：新的输入状态推送给会话参与者，已有的只续期。
+func startTyping(userId, receiveId string) {
+	if receiveId == "" || receiveId == userId {
+		return
+	}
+	key := typingKey(userId, receiveId)
+
+	typingMu.Lock()
+	if t, ok := typingTimers[key]; ok {
+		t.Reset(typingTimeout)
+		typingMu.Unlock()
+		return
+	}
+	typingMu.Unlock()
+
+	if !canSignalTyping(userId, receiveId) {
+		return
+	}
+
+	typingMu.Lock()
+	if _, ok := typingTimers[key]; ok {
+		typingMu.Unlock()
+		return
+	}
+	typingTimers[key] = time.AfterFunc(typingTimeout, func() {
+		stopTyping(userId, receiveId)
+	})
+	typingMu.Unlock()
+
+	pushTyping("typing_start", userId, receiveId)
+}
+
+// stopTyping 结束输入状态；本来就不在输入时什么也不做。
+func stopTyping(userId, receiveId string) {
+	key := typingKey(userId, receiveId)
+	typingMu.Lock()
+	t, ok := typingTimers[key]
+	if ok {
+		t.Stop()
+		delete(typingTimers, key)
+	}
+	typingMu.Unlock()
+
+	if ok {
+		pushTyping("typing_stop", userId, receiveId)
+	}
+}
+
+// stopAllTyping 结束某个用户在所有会话里的输入状态（最后一个连接断开时调用）
+func stopAllTyping(userId string) {
+	prefix := userId + "|"
+	var targets []string
+	typingMu.Lock()
+	for key := range typingTimers {
+		if strings.HasPrefix(key, prefix) {
+			targets = append(targets, strings.TrimPrefix(key, prefix))
+		}
+	}
+	typingMu.Unlock()
+
+	for _, receiveId := range targets {
+		stopTyping(userId, receiveId)
+	}
+}
+
+func pushTyping(action, userId, receiveId string) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":    action,
+		"userId":    userId,
+		"receiveId": receiveId,
+		"timeout":   int(typingTimeout.Seconds()),
+	})
+	if isGroup(receiveId) {
+		dispatchToGroup(receiveId, raw, nil)
+		return
+	}
+	ChatServer.DeliverToUser(receiveId, raw)
+}
+
+// canSignalTyping 群聊要求是群成员，私聊要求对方在自己的好友列表里
+func canSignalTyping(userId, receiveId string) bool {
+	db := config.GetDB()
+	var cnt int64
+	if isGroup(receiveId) {
+		db.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", receiveId, userId).
+			Count(&cnt)
+		return cnt > 0
+	}
+	db.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND contact_type = 0 AND status = 0", userId, receiveId).
+		Count(&cnt)
+	return cnt > 0
+}
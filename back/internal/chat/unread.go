@@ -0,0 +1,74 @@
+// ============================================================
+// 文件：back/internal/chat/unread.go
+// 作用：新消息到来时给接收者的未读计数加一（缓存消费者调用）。
+//
+// Redis KEY = "chat:unread:{userId}"（HASH），field = 会话标识（同 buildSessionId），value = 未读数。
+// 计数的"真相"在 MySQL：read_cursor 表记录每个人读到了哪条消息（见 model/read_cursor.go），
+// 未读数 = 游标之后别人发来的消息数。Redis 里只是它的缓存：
+//   · 某个会话的 field 不存在时，由 service 包从 MySQL 算出来再写入（见 service/read_cursor_service.go）
+//   · 这里只给"已经存在的 field"加一（Lua 里 HEXISTS 判断），
+//     避免在没有基准值的情况下从 0 开始累加，得到一个偏小的错误数字
+//   · 用户标记已读时 service 包会重新计算并覆盖
+// 撤回、阅后即焚删除不会减计数，最迟在下次标记已读或 HASH 过期（service 写入时设置 TTL）后纠正。
+//
+// 接收者：私聊 → 对方；群聊 → 除发送者外的全部成员。系统消息（type=99）不计入未读。
+// ============================================================
+
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrIfExistsScript：field 存在时 HINCRBY 1，不存在时什么也不做
+var incrIfExistsScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+end
+return -1
+`)
+
+func unreadKey(userId string) string {
+	return fmt.Sprintf("chat:unread:%s", userId)
+}
+
+// messageReceivers 返回消息的接收者：私聊是对方，群聊是除发送者外的全部成员
+func messageReceivers(km *KafkaMessage) []string {
+	if isGroup(km.ReceiveId) {
+		var receivers []string
+		if err := config.GetDB().Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id <> ?", km.ReceiveId, km.SendId).
+			Pluck("user_id", &receivers).Error; err != nil {
+			slog.Warn("receivers_lookup_failed", "group_id", km.ReceiveId, "err", err)
+			return nil
+		}
+		return receivers
+	}
+	if km.ReceiveId != km.SendId {
+		return []string{km.ReceiveId}
+	}
+	return nil
+}
+
+// incrUnreadCounters 给消息的全部接收者的未读计数加一
+func incrUnreadCounters(ctx context.Context, rdb *redis.Client, km *KafkaMessage, receivers []string) {
+	if km.Type == msgTypeSystem || len(receivers) == 0 {
+		return
+	}
+
+	field := buildSessionId(km)
+	pipe := rdb.Pipeline()
+	for _, uid := range receivers {
+		incrIfExistsScript.Eval(ctx, pipe, []string{unreadKey(uid)}, field)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		slog.Warn("unread_incr_failed", "msg_id", km.MsgId, "err", err)
+	}
+}
@@ -0,0 +1,125 @@
+// ============================================================
+// 文件：back/internal/chat/user_status.go
+// 作用：用户在线状态（在线 / 离开 / 忙碌 / 隐身 + 自定义文字）的读取、修改和推送。
+//
+// 和 cluster.go 里"在线登记表"的区别：
+//   登记表回答"这个用户现在有没有连接、连在哪个节点"，由连接的建立/断开自动维护；
+//   这里是用户自己选择的状态，保存在 user_status 表（见 model/user_status.go）。
+//   别人看到的状态 = 没有连接时 offline；有连接时按这里的设置，隐身显示为 offline。
+//
+// 修改状态：前端通过 WebSocket 发 {"action":"set_status","status":"busy","statusText":"开会中"}。
+// 修改后推送 presence 事件：
+//   · 给受众（互为好友的人 + 群友，见 presence_audience.go）：{"action":"presence","userId":...,"status":...,"statusText":...}（隐身时 status=offline）
+//   · 给自己的其它设备：同样的事件，但带真实状态，方便多端同步界面
+// ============================================================
+
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const userStatusTextMaxRunes = 64
+
+// loadUserStatus 查询用户保存的状态，没有记录时返回 online
+func loadUserStatus(db *gorm.DB, userId string) model.UserStatus {
+	var st model.UserStatus
+	if err := db.Where("user_id = ?", userId).Limit(1).Find(&st).Error; err != nil || st.UserId == "" {
+		return model.UserStatus{UserId: userId, Status: model.UserStatusOnline}
+	}
+	return st
+}
+
+// statusView 是推给别人的状态
+type statusView struct {
+	Status     string `json:"status"`
+	StatusText string `json:"statusText,omitempty"`
+}
+
+// filterVisibleOnline 从在线用户里去掉隐身的，并返回其中设置了非默认状态的用户的状态
+func filterVisibleOnline(db *gorm.DB, userIds []string) ([]string, map[string]statusView) {
+	statuses := make(map[string]statusView)
+	if len(userIds) == 0 {
+		return userIds, statuses
+	}
+
+	var rows []model.UserStatus
+	db.Where("user_id IN ?", userIds).Find(&rows)
+	saved := make(map[string]model.UserStatus, len(rows))
+	for _, st := range rows {
+		saved[st.UserId] = st
+	}
+
+	visible := make([]string, 0, len(userIds))
+	for _, uid := range userIds {
+		st, ok := saved[uid]
+		if ok && st.Status == model.UserStatusInvisible {
+			continue
+		}
+		visible = append(visible, uid)
+		if ok && (st.Status != model.UserStatusOnline || st.StatusText != "") {
+			statuses[uid] = statusView{Status: st.Status, StatusText: st.StatusText}
+		}
+	}
+	return visible, statuses
+}
+
+// visibleStatus 是别人看到的状态：隐身显示为离线，且不带自定义文字
+func visibleStatus(st model.UserStatus) (string, string) {
+	if st.Status == model.UserStatusInvisible {
+		return model.UserStatusOffline, ""
+	}
+	return st.Status, st.StatusText
+}
+
+// SetUserStatus 修改用户状态，并推送给受众和自己的其它设备。
+func (s *Server) SetUserStatus(userId, status, text string) (*model.UserStatus, error) {
+	switch status {
+	case model.UserStatusOnline, model.UserStatusAway, model.UserStatusBusy, model.UserStatusInvisible:
+	default:
+		return nil, errors.New("不支持的状态")
+	}
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > userStatusTextMaxRunes {
+		return nil, errors.New("状态文字最多64个字符")
+	}
+
+	st := model.UserStatus{UserId: userId, Status: status, StatusText: text, UpdatedAt: time.Now()}
+	if err := config.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "status_text", "updated_at"}),
+	}).Create(&st).Error; err != nil {
+		slog.Error("user_status_save_failed", "user_id", userId, "err", err)
+		return nil, errors.New("设置失败")
+	}
+
+	vis, visText := visibleStatus(st)
+	raw, _ := json.Marshal(map[string]interface{}{
+		"action":     "presence",
+		"userId":     userId,
+		"status":     vis,
+		"statusText": visText,
+	})
+	s.notifyAudience(userId, raw)
+
+	self, _ := json.Marshal(map[string]interface{}{
+		"action":     "presence",
+		"userId":     userId,
+		"status":     st.Status,
+		"statusText": st.StatusText,
+	})
+	s.DeliverToUser(userId, self)
+
+	slog.Info("user_status_changed", "user_id", userId, "status", status)
+	return &st, nil
+}
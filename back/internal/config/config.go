@@ -85,6 +85,7 @@ type LogConfig struct {
 
 // KafkaConfig 描述消息队列相关配置。
 type KafkaConfig struct {
+	// MessageMode 选择消息总线实现："kafka" 或 "channel"（进程内，开发/测试用）。
 	MessageMode string        `toml:"messageMode"`
 	HostPort    string        `toml:"hostPort"`
 	LoginTopic  string        `toml:"loginTopic"`
@@ -100,12 +101,15 @@ type StaticSrcConfig struct {
 	StaticFilePath   string `toml:"staticFilePath"`
 }
 
-// Email 用于邮箱验证码登录流程。
+// Email 用于邮箱验证码登录流程和离线消息摘要。
 type Email struct {
 	SmtpHost string `toml:"smtp_host"`
 	SmtpPort int    `toml:"smtp_port"`
 	Username string `toml:"username"`
 	Password string `toml:"password"`
+	// Security 是连接方式：tls（直接 TLS，默认，端口通常 465）、starttls（端口通常 587）、
+	// none（明文，只用于本地 SMTP 测试服务，如 MailHog / smtp4dev）。
+	Security string `toml:"security"`
 }
 
 // AdminConfig 描述启动时自动创建/更新的管理员账号。
@@ -131,6 +135,56 @@ type SecurityConfig struct {
 	TURNSecret string `toml:"turnSecret"`
 }
 
+// ClusterConfig 描述多实例部署时的跨节点路由配置。
+type ClusterConfig struct {
+	// Enabled 为 true 时启用 Redis 在线登记表和节点间转发；单机部署保持 false。
+	Enabled bool `toml:"enabled"`
+	// NodeId 是当前节点的唯一标识，留空则用"主机名-随机串"自动生成。
+	NodeId string `toml:"nodeId"`
+}
+
+// GroupConfig 描述群聊角色的权限矩阵。
+// 可选权限：edit_info（改群名/公告/头像）、approve_join（审核入群）、kick（踢人）、mute（禁言）、pin（置顶消息）。
+// 群主始终拥有全部权限，不受这里的配置影响。
+type GroupConfig struct {
+	// AdminPermissions 是管理员拥有的权限；不配置时使用默认值（除 pin 以外全部）。
+	AdminPermissions []string `toml:"adminPermissions"`
+	// MemberPermissions 是普通成员拥有的权限，默认没有任何管理权限。
+	MemberPermissions []string `toml:"memberPermissions"`
+}
+
+// MessageConfig 描述消息相关的业务规则。
+type MessageConfig struct {
+	// EditWindowMinutes 是消息发出后允许编辑的时长（分钟），0 表示不允许编辑。
+	EditWindowMinutes int `toml:"editWindowMinutes"`
+	// MaxPinned 是每个会话最多置顶的消息数，不配置时为 10。
+	MaxPinned int `toml:"maxPinned"`
+}
+
+// SearchConfig 描述消息全文检索的后端。
+type SearchConfig struct {
+	// Backend 可选 mysql（FULLTEXT ngram 索引，默认）或 memory（进程内，开发/测试用）。
+	Backend string `toml:"backend"`
+}
+
+// ExportConfig 描述聊天记录导出。
+type ExportConfig struct {
+	// ExportPath 是导出文件（zip）的保存目录，不对外静态暴露，只能通过下载接口获取。
+	ExportPath string `toml:"exportPath"`
+	// ExpireHours 是导出文件的保留时长（小时），过期后删除。
+	ExpireHours int `toml:"expireHours"`
+}
+
+// NotifyConfig 描述离线通知（邮件摘要）。
+type NotifyConfig struct {
+	// EmailDigest 为 true 时启用离线邮件摘要；用户还可以在个人设置里单独关闭。
+	EmailDigest bool `toml:"emailDigest"`
+	// OfflineDelayMinutes 是第一条未送达消息之后、仍然离线多久才发邮件（分钟），不配置时为 15。
+	OfflineDelayMinutes int `toml:"offlineDelayMinutes"`
+	// MaxItems 是一封邮件里最多列出的消息条数，其余只给出总数，不配置时为 20。
+	MaxItems int `toml:"maxItems"`
+}
+
 // Config 是整个配置文件的聚合根。
 // 读取 TOML 后，业务代码统一通过 GetConfig() 拿到它。
 type Config struct {
@@ -144,6 +198,12 @@ type Config struct {
 	Email           `toml:"email"`
 	AdminConfig     `toml:"adminConfig"`
 	SecurityConfig  `toml:"securityConfig"`
+	ClusterConfig   `toml:"clusterConfig"`
+	GroupConfig     `toml:"groupConfig"`
+	MessageConfig   `toml:"messageConfig"`
+	SearchConfig    `toml:"searchConfig"`
+	ExportConfig    `toml:"exportConfig"`
+	NotifyConfig    `toml:"notifyConfig"`
 }
 
 var config *Config = new(Config)
@@ -192,6 +252,25 @@ func InitDB() {
 		&model.ContactApply{},
 		&model.Message{},
 		&model.Session{},
+		&model.UserSequence{},
+		&model.MessageSeq{},
+		&model.GroupMember{},
+		&model.MessageEdit{},
+		&model.MessageReaction{},
+		&model.MessageMention{},
+		&model.MessageSearch{},
+		&model.ExportJob{},
+		&model.MessageUserState{},
+		&model.ConversationClear{},
+		&model.ScheduledMessage{},
+		&model.DisappearingTimer{},
+		&model.PinnedMessage{},
+		&model.UserStatus{},
+		&model.ReadCursor{},
+		&model.SessionPref{},
+		&model.SessionDraft{},
+		&model.OfflineNotice{},
+		&model.NotifySetting{},
 
 		// 这里可以添加更多表，例如 &model.Message{} ...
 	)
@@ -24,25 +24,27 @@ func CreateGroupApply(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "申请成功"})
 }
 
-// 获取群聊待审核申请
+// 获取群聊待审核申请（需要 approve_join 权限）
 func GetGroupApplyList(c *gin.Context) {
+	userId := c.GetString("userId")
 	groupId := c.Query("groupUuid")
 
-	list, err := service.GetGroupApplyList(groupId)
+	list, err := service.GetGroupApplyList(userId, groupId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": list})
 }
 
-// 处理群聊申请
+// 处理群聊申请（需要 approve_join 权限）
 func HandleGroupApply(c *gin.Context) {
+	userId := c.GetString("userId")
 	applyUuid := c.PostForm("applyUuid")
 	approve := c.PostForm("approve") == "true"
 
-	if err := service.HandleGroupApply(applyUuid, approve); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理失败"})
+	if err := service.HandleGroupApply(userId, applyUuid, approve); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "处理成功"})
@@ -0,0 +1,75 @@
+// ============================================================
+// 文件：back/internal/controller/v1/export.go
+// 作用：聊天记录导出的 HTTP handler：发起导出、查询进度、下载 zip。
+//
+// 导出在后台 goroutine 里执行（见 service/export_service.go），
+// 进度通过 WebSocket 推给发起人：
+//   {"action":"export_progress","jobId":"...","status":1,"progress":45,"messageCount":1200}
+// status=2 表示完成，前端收到后调用下载接口；status=3 表示失败，errorMsg 为原因。
+// ============================================================
+package v1
+
+import (
+	"chatapp/back/internal/chat"
+	"chatapp/back/internal/model"
+	"chatapp/back/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateExport 发起会话导出
+func CreateExport(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form struct {
+		TargetId string `json:"targetId" binding:"required"` // 对方用户 UUID 或群 UUID
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	job, err := service.CreateExportJob(userId, form.TargetId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	go service.RunExportJob(job.Uuid, func(j *model.ExportJob) {
+		raw, _ := json.Marshal(map[string]interface{}{
+			"action":       "export_progress",
+			"jobId":        j.Uuid,
+			"targetId":     j.TargetId,
+			"status":       j.Status,
+			"progress":     j.Progress,
+			"messageCount": j.MessageCount,
+			"errorMsg":     j.ErrorMsg,
+		})
+		chat.ChatServer.DeliverToUser(j.UserId, raw)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "导出任务已创建", "data": job})
+}
+
+// GetExportStatus 查询导出进度
+func GetExportStatus(c *gin.Context) {
+	userId := c.GetString("userId")
+	job, err := service.GetExportJob(userId, c.Query("jobId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// DownloadExport 下载导出的 zip 文件
+func DownloadExport(c *gin.Context) {
+	userId := c.GetString("userId")
+	path, name, err := service.GetExportFile(userId, c.Query("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.FileAttachment(path, name)
+}
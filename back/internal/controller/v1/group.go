@@ -2,10 +2,11 @@
 // 文件：back/internal/controller/v1/group.go
 // 作用：群聊管理相关的 HTTP handler：创建群、加群、退群、解散群、成员管理。
 //
-// 群主权限保护：
-//   涉及"解散群"、"移除成员"、"修改群名/公告/头像"的接口，
-//   在 service 层会检查当前用户是否是群主（OwnerId == 当前用户UUID），
-//   不是群主的操作直接返回 403 错误。
+// 群权限保护：
+//   "解散群"、"设置管理员"、"转让群主"只有群主可以操作；
+//   "移除成员"、"禁言"、"修改群名/公告/头像"按角色权限矩阵判断
+//   （service.CheckGroupPermission，管理员权限可在 groupConfig 里配置）。
+//   角色和禁言变化会通过 WebSocket 推给群内在线成员。
 // ============================================================
 package v1
 
@@ -13,6 +14,9 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"strconv"
+	"time"
+
 	"chatapp/back/internal/chat"
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/dto/req"
@@ -106,8 +110,7 @@ func EnterGroupDirectly(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "群不存在"})
 		return
 	}
-	var oldMembers []string
-	_ = json.Unmarshal(group.Members, &oldMembers)
+	oldMembers, _ := service.GetGroupMemberIds(groupUuid)
 
 	// 2) 执行加入（你原本已有的业务）
 	if err := service.EnterGroup(userId, groupUuid, message); err != nil {
@@ -167,11 +170,10 @@ func QuitGroup(c *gin.Context) {
 		return
 	}
 
-	// 解 JSON，拿退出前的成员列表用于广播
-	var members []string
-	_ = json.Unmarshal(group.Members, &members)
+	// 拿退出前的成员列表用于广播
+	members, _ := service.GetGroupMemberIds(req.GroupId)
 
-	// 使用 service 层（负责更新 members 并清理 user_contact）
+	// 使用 service 层（负责删除 group_member 并清理 user_contact）
 	if err := service.LeaveGroup(userId, req.GroupId); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -216,14 +218,17 @@ func GetGroupMemberList(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// 成员详情（角色、入群时间、禁言状态），members 保持原来的 ID 数组格式以兼容前端
+	details, _ := service.GetGroupMembers(groupUuid)
 
 	c.JSON(http.StatusOK, gin.H{
-		"groupUuid": groupUuid,
-		"members":   members,
+		"groupUuid":     groupUuid,
+		"members":       members,
+		"memberDetails": details,
 	})
 }
 
-// 移除群成员（群主权限）
+// 移除群成员（需要 kick 权限）
 func RemoveGroupMember(c *gin.Context) {
 	ownerId := c.GetString("userId") // 当前登录用户
 	groupUuid := c.PostForm("groupUuid")
@@ -295,31 +300,143 @@ func GetGroupInfo(c *gin.Context) {
 		return
 	}
 
-	// 反序列化成员列表，同时做鉴权
-	var members []string
-	_ = json.Unmarshal(group.Members, &members)
-
-	isMember := false
-	for _, m := range members {
-		if m == userId {
-			isMember = true
-			break
-		}
-	}
-	if !isMember {
+	// 鉴权：只有群成员可以查看
+	if !service.IsGroupMember(userId, groupId) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权限查看该群详情"})
 		return
 	}
+	members, _ := service.GetGroupMemberIds(groupId)
+	pinned, _ := service.GetPinnedMessages(userId, groupId)
+
+	// 当前用户的角色和权限，前端据此决定显示哪些管理入口
+	var myRole int8
+	if me, err := service.GetGroupMember(groupId, userId); err == nil {
+		myRole = me.Role
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"uuid":        group.Uuid,
-		"name":        group.Name,
-		"notice":      group.Notice,
-		"ownerId":     group.OwnerId,
-		"memberCount": group.MemberCnt,
-		"avatar":      group.Avatar,
-		"members":     members,
+		"uuid":          group.Uuid,
+		"name":          group.Name,
+		"notice":        group.Notice,
+		"ownerId":       group.OwnerId,
+		"memberCount":   group.MemberCnt,
+		"avatar":        group.Avatar,
+		"members":       members,
+		"myRole":        myRole,
+		"myPermissions": service.RolePermissions(myRole),
+		"pinned":        pinned,
+	})
+}
+
+// SetGroupAdmin 设置/取消管理员（仅群主）
+func SetGroupAdmin(c *gin.Context) {
+	userId := c.GetString("userId")
+	groupUuid := c.PostForm("groupUuid")
+	targetUserId := c.PostForm("targetUserId")
+	admin := c.PostForm("admin") == "true"
+
+	if groupUuid == "" || targetUserId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少参数"})
+		return
+	}
+
+	role, err := service.SetGroupAdmin(userId, groupUuid, targetUserId, admin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	go notifyGroupMembers(groupUuid, map[string]any{
+		"action":   "group_role_changed",
+		"groupId":  groupUuid,
+		"userId":   targetUserId,
+		"role":     role,
+		"operator": userId,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功", "role": role})
+}
+
+// MuteGroupMember 禁言/解除禁言（需要 mute 权限）。minutes 为 0 表示解除禁言。
+func MuteGroupMember(c *gin.Context) {
+	userId := c.GetString("userId")
+	groupUuid := c.PostForm("groupUuid")
+	targetUserId := c.PostForm("targetUserId")
+	minutes, err := strconv.Atoi(c.DefaultPostForm("minutes", "0"))
+
+	if groupUuid == "" || targetUserId == "" || err != nil || minutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少参数"})
+		return
+	}
+
+	until, err := service.MuteGroupMember(userId, groupUuid, targetUserId, time.Duration(minutes)*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var untilUnix int64
+	if until != nil {
+		untilUnix = until.Unix()
+	}
+	go notifyGroupMembers(groupUuid, map[string]any{
+		"action":     "group_member_muted",
+		"groupId":    groupUuid,
+		"userId":     targetUserId,
+		"mutedUntil": untilUnix, // 0 表示已解除禁言
+		"operator":   userId,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功", "mutedUntil": untilUnix})
+}
+
+// TransferGroupOwner 转让群主（仅群主）。leave=true 时原群主转让后同时退群。
+func TransferGroupOwner(c *gin.Context) {
+	userId := c.GetString("userId")
+	var req struct {
+		GroupId    string `json:"groupId" form:"groupId" binding:"required"`
+		NewOwnerId string `json:"newOwnerId" form:"newOwnerId" binding:"required"`
+		Leave      bool   `json:"leave" form:"leave"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少参数"})
+		return
+	}
+
+	if err := service.TransferGroupOwner(userId, req.GroupId, req.NewOwnerId, req.Leave); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 系统事件推给群的在线订阅者（含其它节点）
+	chat.ChatServer.PushGroupEvent(req.GroupId, "group_owner_transferred", map[string]interface{}{
+		"oldOwnerId":   userId,
+		"newOwnerId":   req.NewOwnerId,
+		"oldOwnerLeft": req.Leave,
 	})
+	// 原群主退群时，它自己可能已不在订阅表里，单独通知一次做 UI 清理
+	if req.Leave {
+		raw, _ := json.Marshal(map[string]any{
+			"action":  "group_quit",
+			"groupId": req.GroupId,
+			"userId":  userId,
+		})
+		chat.ChatServer.DeliverToUser(userId, raw)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "群主已转让"})
+}
+
+// notifyGroupMembers 把控制消息推给群内全部成员（在线的才会收到，含其它节点）。
+func notifyGroupMembers(groupId string, payload map[string]any) {
+	members, err := service.GetGroupMemberIds(groupId)
+	if err != nil {
+		return
+	}
+	raw, _ := json.Marshal(payload)
+	for _, uid := range members {
+		chat.ChatServer.DeliverToUser(uid, raw)
+	}
 }
 
 // 更新群公告
@@ -12,10 +12,12 @@ package v1
 import (
 	"chatapp/back/internal/chat"
 	"chatapp/back/internal/dto/req"
+	"chatapp/back/internal/model"
 	"chatapp/back/internal/service"
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -66,6 +68,87 @@ func GetGroupMessageList(c *gin.Context) {
 	})
 }
 
+// GetMessageThread 获取话题：根消息 + 全部回复（分页）
+func GetMessageThread(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.GetMessageThreadRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	thread, err := service.GetMessageThread(userId, form.MsgId, form.Limit, form.BeforeTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": thread})
+}
+
+// SearchMessages 在自己能访问的全部会话里搜索消息
+func SearchMessages(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.SearchMessageRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	res, err := service.SearchMessages(userId, service.SearchMessageParams{
+		Keyword:        form.Keyword,
+		SenderId:       form.SenderId,
+		ConversationId: form.ConversationId,
+		Type:           form.Type,
+		StartTime:      form.StartTime,
+		EndTime:        form.EndTime,
+		Limit:          form.Limit,
+		Offset:         form.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": res})
+}
+
+// GetMentions 获取"@我"的未读提醒，同时返回按群统计的未读数
+func GetMentions(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.GetMentionsRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	list, err := service.GetUnreadMentions(userId, form.GroupId, form.Limit, form.BeforeTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	counts, err := service.CountUnreadMentions(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "counts": counts})
+}
+
+// MarkMentionsRead 标记 @ 提醒为已读
+func MarkMentionsRead(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.MarkMentionsReadRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	if err := service.MarkMentionsRead(userId, form.GroupId, form.MsgIds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已标记"})
+}
+
 // RecallMessage 撤回消息
 func RecallMessage(c *gin.Context) {
 	userId := c.GetString("userId")
@@ -133,6 +216,116 @@ func RecallMessageFull(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "撤回成功"})
 }
 
+// EditMessage 编辑自己发出的文本消息，并把新内容推给会话的所有参与者
+func EditMessage(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form struct {
+		MsgId   string `json:"msgId" binding:"required"`
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	msg, err := service.EditMessage(userId, form.MsgId, form.Content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var editedAt int64
+	if msg.EditedAt != nil {
+		editedAt = msg.EditedAt.Unix()
+	}
+
+	// 同步 Redis 里的最近消息缓存
+	if err := chat.UpdateCachedMessage(msg.SendId, msg.ReceiveId, msg.Uuid, func(km *chat.KafkaMessage) {
+		km.Content = msg.Content
+		km.EditedAt = editedAt
+	}); err != nil {
+		log.Printf("⚠️ 更新消息缓存失败 msg=%s: %v", msg.Uuid, err)
+	}
+
+	payload := map[string]any{
+		"action":    "msg_edit",
+		"msgId":     msg.Uuid,
+		"sendId":    msg.SendId,
+		"receiveId": msg.ReceiveId,
+		"content":   msg.Content,
+		"editedAt":  editedAt,
+	}
+	raw, _ := json.Marshal(payload)
+	chat.DeliverToConversation(msg.SendId, msg.ReceiveId, raw)
+
+	c.JSON(http.StatusOK, gin.H{"message": "编辑成功", "data": msg})
+}
+
+// GetMessageEditHistory 查看消息的编辑历史
+func GetMessageEditHistory(c *gin.Context) {
+	userId := c.GetString("userId")
+	msgId := c.Query("msgId")
+	if msgId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	list, err := service.GetMessageEditHistory(userId, msgId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// reactionForm 是添加/取消表情回应的请求体
+type reactionForm struct {
+	MsgId string `json:"msgId" binding:"required"`
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// AddReaction 给消息添加表情回应
+func AddReaction(c *gin.Context) {
+	handleReaction(c, "add", service.AddReaction)
+}
+
+// RemoveReaction 取消表情回应
+func RemoveReaction(c *gin.Context) {
+	handleReaction(c, "remove", service.RemoveReaction)
+}
+
+// handleReaction 是添加/取消表情的公共流程：执行操作后把最新聚合结果推给会话参与者。
+func handleReaction(c *gin.Context, op string, fn func(userId, msgId, emoji string) (*model.Message, error)) {
+	userId := c.GetString("userId")
+	var form reactionForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	msg, err := fn(userId, form.MsgId, form.Emoji)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reactions := service.GetReactionSummary(msg.Uuid)
+	payload := map[string]any{
+		"action":    "msg_reaction",
+		"op":        op,
+		"msgId":     msg.Uuid,
+		"sendId":    msg.SendId,
+		"receiveId": msg.ReceiveId,
+		"userId":    userId,
+		"emoji":     strings.TrimSpace(form.Emoji),
+		"reactions": reactions,
+	}
+	raw, _ := json.Marshal(payload)
+	chat.DeliverToConversation(msg.SendId, msg.ReceiveId, raw)
+
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功", "reactions": reactions})
+}
+
 // MarkMessagesRead 标记消息为已读
 func MarkMessagesRead(c *gin.Context) {
 	userId := c.GetString("userId")
@@ -162,7 +355,93 @@ func MarkMessagesRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "已读"})
 }
 
-// ClearConversation 删除好友时清除双方的聊天记录（物理删除）
+// MarkConversationRead 按已读游标标记会话已读（私聊、群聊通用）
+func MarkConversationRead(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.MarkConversationReadRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	state, senders, err := service.MarkConversationRead(userId, form.TargetId, form.MsgId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 同步到自己的其它设备
+	syncRaw, _ := json.Marshal(map[string]any{
+		"action":        "read_sync",
+		"targetId":      state.TargetId,
+		"lastReadMsgId": state.LastReadMsgId,
+		"lastReadSeq":   state.LastReadSeq,
+		"unread":        state.Unread,
+		"readAt":        state.ReadAt.Unix(),
+	})
+	chat.ChatServer.DeliverToUser(userId, syncRaw)
+
+	// 通知被读到消息的发送者：私聊沿用 msg_read，群聊是 group_read
+	now := time.Now().Unix()
+	for _, sid := range senders {
+		payload := map[string]any{
+			"action":        "msg_read",
+			"senderId":      sid,
+			"receiverId":    userId,
+			"lastReadMsgId": state.LastReadMsgId,
+			"time":          now,
+		}
+		if sid != form.TargetId {
+			payload = map[string]any{
+				"action":        "group_read",
+				"groupId":       form.TargetId,
+				"readerId":      userId,
+				"lastReadMsgId": state.LastReadMsgId,
+				"time":          now,
+			}
+		}
+		raw, _ := json.Marshal(payload)
+		chat.ChatServer.DeliverToUser(sid, raw)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": state})
+}
+
+// GetMessageReaders 查询自己发出的消息被谁读过（群聊显示"N 人已读"和名单）
+func GetMessageReaders(c *gin.Context) {
+	userId := c.GetString("userId")
+	msgId := c.Query("msgId")
+	if msgId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	readers, err := service.GetMessageReaders(userId, msgId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": readers})
+}
+
+// DeleteMessagesForMe 删除消息，只对自己生效
+func DeleteMessagesForMe(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form struct {
+		MsgIds []string `json:"msgIds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	if err := service.DeleteMessagesForMe(userId, form.MsgIds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
+
+// ClearConversation 清空自己在某个会话里的聊天记录（私聊或群聊），对方的记录不受影响
 func ClearConversation(c *gin.Context) {
 	userId := c.GetString("userId")
 	var form struct {
@@ -180,3 +459,251 @@ func ClearConversation(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "已清除"})
 }
+
+// scheduledForm 是创建 / 修改定时消息的请求体
+type scheduledForm struct {
+	Id        string   `json:"id"` // 修改时必填
+	ReceiveId string   `json:"receiveId"`
+	Type      int8     `json:"type"`
+	Content   string   `json:"content"`
+	Url       string   `json:"url"`
+	FileName  string   `json:"fileName"`
+	FileType  string   `json:"fileType"`
+	FileSize  string   `json:"fileSize"`
+	ReplyTo   string   `json:"replyTo"`
+	Mentions  []string `json:"mentions"`
+	SendAt    int64    `json:"sendAt" binding:"required"` // Unix 时间戳
+}
+
+func (f *scheduledForm) params() service.ScheduledMessageParams {
+	return service.ScheduledMessageParams{
+		ReceiveId: f.ReceiveId,
+		Type:      f.Type,
+		Content:   f.Content,
+		Url:       f.Url,
+		FileName:  f.FileName,
+		FileType:  f.FileType,
+		FileSize:  f.FileSize,
+		ReplyTo:   f.ReplyTo,
+		Mentions:  f.Mentions,
+		SendAt:    f.SendAt,
+	}
+}
+
+// CreateScheduledMessage 创建定时消息
+func CreateScheduledMessage(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form scheduledForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	sm, err := service.CreateScheduledMessage(userId, form.params())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已创建", "data": sm})
+}
+
+// ListScheduledMessages 列出定时消息，all=1 时包含已发送/已取消/失败的
+func ListScheduledMessages(c *gin.Context) {
+	userId := c.GetString("userId")
+	list, err := service.ListScheduledMessages(userId, c.Query("all") == "1")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// UpdateScheduledMessage 修改定时消息的内容和发送时间
+func UpdateScheduledMessage(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form scheduledForm
+	if err := c.ShouldBindJSON(&form); err != nil || form.Id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	sm, err := service.UpdateScheduledMessage(userId, form.Id, form.params())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已修改", "data": sm})
+}
+
+// CancelScheduledMessage 取消定时消息
+func CancelScheduledMessage(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form struct {
+		Id string `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	if err := service.CancelScheduledMessage(userId, form.Id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已取消"})
+}
+
+// GetDisappearingTimer 查询会话的消息自动删除计时（秒，0 表示关闭）
+func GetDisappearingTimer(c *gin.Context) {
+	userId := c.GetString("userId")
+	targetId := c.Query("targetId")
+	if targetId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	t, err := service.GetDisappearingTimer(userId, targetId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": t})
+}
+
+// SetDisappearingTimer 修改会话的消息自动删除计时，并在会话里发一条系统提示
+func SetDisappearingTimer(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form struct {
+		TargetId string `json:"targetId" binding:"required"` // 私聊对方或群的 uuid
+		Ttl      *int64 `json:"ttl" binding:"required"`      // 0 关闭，3600 / 86400 / 604800
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	changed, err := service.SetDisappearingTimer(userId, form.TargetId, *form.Ttl)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if changed {
+		if err := chat.ChatKafkaProducer.PublishTimerNotice(userId, form.TargetId, *form.Ttl); err != nil {
+			log.Printf("⚠️ 发送计时变更提示失败 target=%s: %v", form.TargetId, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功", "ttl": *form.Ttl})
+}
+
+// PinMessage 置顶消息，并把最新的置顶列表推给会话参与者
+func PinMessage(c *gin.Context) {
+	handlePin(c, "msg_pin", service.PinMessage)
+}
+
+// UnpinMessage 取消置顶
+func UnpinMessage(c *gin.Context) {
+	handlePin(c, "msg_unpin", service.UnpinMessage)
+}
+
+// handlePin 是置顶/取消置顶的公共流程
+func handlePin(c *gin.Context, action string, fn func(userId, msgId string) (*model.Message, error)) {
+	userId := c.GetString("userId")
+	var form struct {
+		MsgId string `json:"msgId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	msg, err := fn(userId, form.MsgId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 私聊的会话对象是"对方"，群聊是群本身
+	targetId := msg.ReceiveId
+	if targetId == userId {
+		targetId = msg.SendId
+	}
+	pinned, _ := service.GetPinnedMessages(userId, targetId)
+
+	payload := map[string]any{
+		"action":     action,
+		"msgId":      msg.Uuid,
+		"sendId":     msg.SendId,
+		"receiveId":  msg.ReceiveId,
+		"operatorId": userId,
+		"pinned":     pinned,
+	}
+	raw, _ := json.Marshal(payload)
+	chat.DeliverToConversation(msg.SendId, msg.ReceiveId, raw)
+
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功", "pinned": pinned})
+}
+
+// GetPinnedMessages 查询会话的置顶消息，targetId 是私聊对方或群的 uuid
+func GetPinnedMessages(c *gin.Context) {
+	userId := c.GetString("userId")
+	targetId := c.Query("targetId")
+	if targetId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	list, err := service.GetPinnedMessages(userId, targetId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// ForwardMessages 转发消息到一个或多个会话，逐个会话返回发送结果
+func ForwardMessages(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.ForwardMessageRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	payloads, err := service.PrepareForward(userId, form.MsgIds, form.TargetIds, form.Merge)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]gin.H, 0, len(form.TargetIds))
+	for _, targetId := range form.TargetIds {
+		msgIds := make([]string, 0, len(payloads))
+		var sendErr error
+		for _, p := range payloads {
+			msgId, _, err := chat.ChatKafkaProducer.Publish(chat.ChatEnvelope{
+				Type:      p.Type,
+				Content:   p.Content,
+				Url:       p.Url,
+				FileName:  p.FileName,
+				FileType:  p.FileType,
+				FileSize:  p.FileSize,
+				SendId:    userId,
+				ReceiveId: targetId,
+				Record:    p.Record,
+			})
+			if err != nil {
+				// 被禁言、总线不可用等：这个会话剩下的消息不再发送
+				sendErr = err
+				break
+			}
+			msgIds = append(msgIds, msgId)
+		}
+
+		r := gin.H{"targetId": targetId, "msgIds": msgIds}
+		if sendErr != nil {
+			r["error"] = sendErr.Error()
+		}
+		results = append(results, r)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
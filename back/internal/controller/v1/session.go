@@ -1,12 +1,17 @@
 // ============================================================
 // 文件：back/internal/controller/v1/session.go
-// 作用：会话列表相关的 HTTP handler：打开会话、查询列表、删除会话。
+// 作用：会话列表相关的 HTTP handler：打开会话、查询列表、删除会话、会话偏好。
+//       /session/list 是统一的会话列表（排序 + 预览 + 未读数，见 service/session_list_service.go）。
+//       /session/pref、/session/reorder 修改置顶 / 免打扰 / 归档 / 标记未读，
+//       修改后推 session_pref / session_reorder 事件，同步到自己的其它设备。
 // ============================================================
 package v1
 
 import (
+	"chatapp/back/internal/chat"
 	"chatapp/back/internal/dto/req"
 	"chatapp/back/internal/service"
+	"encoding/json"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -25,7 +30,9 @@ func OpenSession(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": session})
+	// 带上会话的置顶消息；对方不是好友等情况下查不到，返回空列表
+	pinned, _ := service.GetPinnedMessages(userId, form.ReceiveId)
+	c.JSON(http.StatusOK, gin.H{"data": session, "pinned": pinned})
 }
 
 // 获取用户会话列表
@@ -39,6 +46,17 @@ func GetUserSessionList(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": list})
 }
 
+// GetSessionList 获取会话列表：按最近活跃排序，带最后一条消息预览和未读数
+func GetSessionList(c *gin.Context) {
+	userId := c.GetString("userId")
+	list, err := service.GetSessionList(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
 // 获取群聊会话列表
 func GetGroupSessionList(c *gin.Context) {
 	groupId := c.Query("groupId")
@@ -68,3 +86,49 @@ func CheckOpenSessionAllowed(c *gin.Context) {
 	allowed, _ := service.CheckOpenSessionAllowed(userId, targetId)
 	c.JSON(http.StatusOK, gin.H{"allowed": allowed})
 }
+
+// UpdateSessionPref 修改会话偏好（置顶、免打扰、归档、标记未读），只修改传了的字段
+func UpdateSessionPref(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.UpdateSessionPrefRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	view, err := service.UpdateSessionPref(userId, &form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 同步到自己的其它设备
+	raw, _ := json.Marshal(map[string]any{
+		"action": "session_pref",
+		"pref":   view,
+	})
+	chat.ChatServer.DeliverToUser(userId, raw)
+
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// ReorderPinnedSessions 调整置顶会话的顺序，targetIds 是排好序的全部置顶会话
+func ReorderPinnedSessions(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.ReorderPinnedSessionsRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := service.ReorderPinnedSessions(userId, form.TargetIds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw, _ := json.Marshal(map[string]any{
+		"action":    "session_reorder",
+		"targetIds": form.TargetIds,
+	})
+	chat.ChatServer.DeliverToUser(userId, raw)
+
+	c.JSON(http.StatusOK, gin.H{"message": "排序成功"})
+}
@@ -132,3 +132,25 @@ func UpdateUserInfo(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "更新成功", "data": user})
 }
+
+// GetNotifySetting 获取通知设置（离线邮件摘要、免打扰时段）
+func GetNotifySetting(c *gin.Context) {
+	userId := c.GetString("userId")
+	c.JSON(http.StatusOK, gin.H{"data": service.GetNotifySetting(userId)})
+}
+
+// UpdateNotifySetting 修改通知设置，只修改传了的字段
+func UpdateNotifySetting(c *gin.Context) {
+	userId := c.GetString("userId")
+	var form req.UpdateNotifySettingRequest
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	setting, err := service.UpdateNotifySetting(userId, &form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": setting})
+}
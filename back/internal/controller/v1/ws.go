@@ -10,6 +10,7 @@
 //   3. 检查 Redis 黑名单（是否已登出）
 //   4. 调用 upgrader.Upgrade：把这个 HTTP 连接"升级"成 WebSocket 连接
 //   5. 创建 Client 对象，调用 ChatServer.AddClient 注册到在线用户表
+//      如果带了 ?since=<seq>，注册前先开始补发缺失的消息（见 chat/sync.go）
 //   6. 启动读协程（Read）和写协程（Write），维持长连接
 //
 // websocket.Upgrader 的 CheckOrigin 配置：
@@ -32,6 +33,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"chatapp/back/internal/chat"
@@ -114,6 +116,10 @@ func WsLogin(c *gin.Context) {
 		Uuid:     userId,
 		SendBack: make(chan []byte, 100),
 	}
+	// 带 since 重连时先进入补发状态，再注册，保证"先补历史、后接实时"
+	if since, err := strconv.ParseInt(c.Query("since"), 10, 64); err == nil && since >= 0 {
+		client.StartSync(since)
+	}
 	chat.ChatServer.AddClient(client)
 
 	// 5️⃣ 启动读写协程。
@@ -17,3 +17,48 @@ type GetGroupMessageListRequest struct {
 	Limit      int    `json:"limit"`
 	BeforeTime int64  `json:"beforeTime"` // Unix时间戳，分页用
 }
+
+// 搜索消息
+type SearchMessageRequest struct {
+	Keyword        string `json:"keyword" binding:"required"`
+	SenderId       string `json:"senderId"`       // 只看某人发的
+	ConversationId string `json:"conversationId"` // 只搜某个会话：对方用户 UUID 或群 UUID
+	Type           *int8  `json:"type"`           // 消息类型：0 文本，1 文件（按文件名）
+	StartTime      int64  `json:"startTime"`      // Unix时间戳，包含
+	EndTime        int64  `json:"endTime"`        // Unix时间戳，不包含
+	Limit          int    `json:"limit"`          // 默认20，最大100
+	Offset         int    `json:"offset"`
+}
+
+// 获取"@我"的未读提醒
+type GetMentionsRequest struct {
+	GroupId    string `json:"groupId"` // 为空时返回全部群
+	Limit      int    `json:"limit"`
+	BeforeTime int64  `json:"beforeTime"` // Unix时间戳，分页用
+}
+
+// 标记 @ 提醒已读
+type MarkMentionsReadRequest struct {
+	GroupId string   `json:"groupId"` // 标记整个群
+	MsgIds  []string `json:"msgIds"`  // 只标记这些消息，优先于 groupId
+}
+
+// 获取话题（某条消息的全部回复）
+type GetMessageThreadRequest struct {
+	MsgId      string `json:"msgId" binding:"required"` // 根消息或话题中任意一条回复的 uuid
+	Limit      int    `json:"limit"`
+	BeforeTime int64  `json:"beforeTime"` // Unix时间戳，分页用
+}
+
+// 转发消息
+type ForwardMessageRequest struct {
+	MsgIds    []string `json:"msgIds" binding:"required"`    // 源消息 uuid
+	TargetIds []string `json:"targetIds" binding:"required"` // 目标会话：对方用户 uuid 或群 uuid
+	Merge     bool     `json:"merge"`                        // true：合并成一条聊天记录；false：逐条转发
+}
+
+// 标记会话已读（私聊、群聊通用）
+type MarkConversationReadRequest struct {
+	TargetId string `json:"targetId" binding:"required"` // 对方用户 uuid 或群 uuid
+	MsgId    string `json:"msgId"`                       // 已读到的消息，为空时为会话里最新一条
+}
@@ -1,6 +1,6 @@
 // ============================================================
 // 文件：back/internal/dto/req/session_req.go
-// 作用：会话操作（打开会话、删除会话）的请求参数结构体。
+// 作用：会话操作（打开会话、删除会话、会话偏好）的请求参数结构体。
 // ============================================================
 package req
 
@@ -10,3 +10,18 @@ type OpenSessionRequest struct {
 	ReceiveName string `json:"receiveName" binding:"required"` // 名称
 	Avatar      string `json:"avatar"`                         // 头像
 }
+
+// 修改会话偏好，只修改传了的字段
+type UpdateSessionPrefRequest struct {
+	TargetId     string `json:"targetId" binding:"required"` // 对方用户或群的 uuid
+	Pinned       *bool  `json:"pinned"`                      // 置顶
+	Muted        *bool  `json:"muted"`                       // 免打扰
+	MuteUntil    int64  `json:"muteUntil"`                   // 免打扰截止时间（Unix秒），0 表示一直免打扰，只在 muted=true 时使用
+	Archived     *bool  `json:"archived"`                    // 归档
+	MarkedUnread *bool  `json:"markedUnread"`                // 标记为未读
+}
+
+// 调整置顶会话的顺序
+type ReorderPinnedSessionsRequest struct {
+	TargetIds []string `json:"targetIds" binding:"required"` // 全部置顶会话，按新的顺序排列
+}
@@ -1,6 +1,6 @@
 // ============================================================
 // 文件：back/internal/dto/req/user_req.go
-// 作用：用户信息更新请求的参数结构体。所有字段可选，只更新非空字段。
+// 作用：用户信息、通知设置更新请求的参数结构体。所有字段可选，只更新传了的字段。
 // ============================================================
 package req
 
@@ -12,3 +12,11 @@ type UpdateUserRequest struct {
 	Signature string `json:"signature"` // 个性签名
 	Password  string `json:"password"`  // 新密码（可选）
 }
+
+// UpdateNotifySettingRequest 修改通知设置，只修改传了的字段
+type UpdateNotifySettingRequest struct {
+	EmailDigest *bool   `json:"emailDigest"` // 是否接收离线邮件摘要
+	QuietStart  *string `json:"quietStart"`  // 免打扰开始时间 HH:MM，和 quietEnd 同时为空表示取消免打扰
+	QuietEnd    *string `json:"quietEnd"`    // 免打扰结束时间 HH:MM
+	TimeZone    *string `json:"timeZone"`    // 免打扰时段的时区（IANA 名称），空表示服务器时区
+}
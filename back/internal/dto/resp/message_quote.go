@@ -0,0 +1,62 @@
+// ============================================================
+// 文件：back/internal/dto/resp/message_quote.go
+// 作用：回复消息时附带的"被引用消息快照"（MessageQuote）。
+//
+// 为什么是快照而不是只给一个 replyTo ID？
+//   前端渲染引用气泡需要"谁说的、说了什么"，如果只给 ID，
+//   被引用的消息可能不在当前加载的页里，前端还得再发一次请求。
+//   所以后端在发送时就把发送者、摘要、类型一并带上。
+//
+// 摘要规则：
+//   文本取前 quoteExcerptRunes 个字符；文件显示 "[文件] 文件名"；通话显示 "[通话]"；
+//   合并转发显示 "[聊天记录] 标题"。
+//   被引用的消息之后撤回了，IsRecalled=1 且摘要置空，前端显示"该消息已撤回"。
+// ============================================================
+package resp
+
+import (
+	"chatapp/back/internal/model"
+)
+
+const quoteExcerptRunes = 50
+
+// MessageQuote 被引用消息的快照
+type MessageQuote struct {
+	MsgId      string `json:"msgId"`
+	SendId     string `json:"sendId"`
+	SendName   string `json:"sendName"`
+	Type       int8   `json:"type"`
+	Excerpt    string `json:"excerpt"`
+	IsRecalled int8   `json:"isRecalled,omitempty"`
+}
+
+// NewMessageQuote 根据被引用的消息生成快照
+func NewMessageQuote(m *model.Message) *MessageQuote {
+	q := &MessageQuote{
+		MsgId:      m.Uuid,
+		SendId:     m.SendId,
+		SendName:   m.SendName,
+		Type:       m.Type,
+		IsRecalled: m.IsRecalled,
+	}
+	if m.IsRecalled == 1 {
+		return q
+	}
+
+	switch m.Type {
+	case 1:
+		q.Excerpt = "[文件] " + m.FileName
+	case 2:
+		q.Excerpt = "[通话]"
+	case 3:
+		q.Excerpt = "[聊天记录] " + m.Content
+	default:
+		r := []rune(m.Content)
+		if len(r) > quoteExcerptRunes {
+			q.Excerpt = string(r[:quoteExcerptRunes]) + "…"
+		} else {
+			q.Excerpt = m.Content
+		}
+	}
+	return q
+}
@@ -0,0 +1,28 @@
+// ============================================================
+// 文件：back/internal/model/disappearing_timer.go
+// 作用：定义"阅后即焚"计时设置表 disappearing_timer：每个会话一行。
+//
+// ConversationKey 与 Redis 最近消息缓存使用同一套会话标识（见 chat/session_id.go）：
+//   群聊：  "G:" + 群uuid
+//   私聊：  两个用户 uuid 排序后用 ":" 拼接
+// 设置对会话的全部参与者生效，私聊双方、群里所有成员看到的是同一个计时。
+//
+// TtlSeconds 只允许 0（关闭）/ 1小时 / 1天 / 7天。
+// 开启期间发出的消息在 message.expire_at 上记录过期时间，由后台清理任务删除（见 chat/disappearing.go）；
+// 修改计时不会影响已经发出的消息。
+// ============================================================
+package model
+
+import "time"
+
+type DisappearingTimer struct {
+	Id              int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	ConversationKey string    `gorm:"column:conversation_key;uniqueIndex;type:varchar(45);not null;comment:会话标识" json:"-"`
+	TtlSeconds      int64     `gorm:"column:ttl_seconds;not null;default:0;comment:消息存活秒数，0表示关闭" json:"ttl"`
+	UpdatedBy       string    `gorm:"column:updated_by;type:char(20);not null;comment:最后修改人uuid" json:"updatedBy"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;not null;comment:最后修改时间" json:"updatedAt"`
+}
+
+func (DisappearingTimer) TableName() string {
+	return "disappearing_timer"
+}
@@ -0,0 +1,47 @@
+// ============================================================
+// 文件：back/internal/model/export_job.go
+// 作用：定义聊天记录导出任务表模型，对应 export_job 表。
+//
+// 一行 = 一次"导出某个会话"的请求，生命周期：
+//   0 排队中 → 1 导出中 → 2 已完成（可下载）→ 4 已过期（文件已删除）
+//                      ↘ 3 失败（ErrorMsg 记录原因）
+// 导出文件保存在 exportConfig.exportPath 下，ExpiresAt 之后由清理任务删除。
+//
+// 多实例部署：
+//   ActiveUser 在排队中 / 导出中时等于发起人，结束后置空；唯一索引保证每人同时只有一个进行中的任务
+//   （MySQL 唯一索引允许多个 NULL）。
+//   LeaseUntil 是执行任务的实例的租约，执行期间定期续期；过期说明那个实例已经退出，清理任务把它标记为失败。
+// ============================================================
+package model
+
+import "time"
+
+// 导出任务状态
+const (
+	ExportStatusPending = 0
+	ExportStatusRunning = 1
+	ExportStatusDone    = 2
+	ExportStatusFailed  = 3
+	ExportStatusExpired = 4
+)
+
+type ExportJob struct {
+	Id           int64      `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	Uuid         string     `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:任务uuid" json:"jobId"`
+	UserId       string     `gorm:"column:user_id;type:char(20);not null;index;comment:发起人uuid" json:"userId"`
+	TargetId     string     `gorm:"column:target_id;type:char(20);not null;comment:会话对象uuid（用户或群）" json:"targetId"`
+	Status       int8       `gorm:"column:status;not null;default:0;comment:状态，0.排队中，1.导出中，2.已完成，3.失败，4.已过期" json:"status"`
+	Progress     int        `gorm:"column:progress;not null;default:0;comment:进度百分比" json:"progress"`
+	MessageCount int        `gorm:"column:message_count;not null;default:0;comment:已导出消息数" json:"messageCount"`
+	FilePath     string     `gorm:"column:file_path;type:varchar(255);comment:导出文件路径" json:"-"`
+	ErrorMsg     string     `gorm:"column:error_msg;type:varchar(255);comment:失败原因" json:"errorMsg,omitempty"`
+	ExpiresAt    *time.Time `gorm:"column:expires_at;comment:文件过期时间" json:"expiresAt"`
+	FinishedAt   *time.Time `gorm:"column:finished_at;comment:完成时间" json:"finishedAt"`
+	CreatedAt    time.Time  `gorm:"column:created_at;not null;comment:创建时间" json:"createdAt"`
+	ActiveUser   *string    `gorm:"column:active_user;type:char(20);uniqueIndex;comment:进行中的任务填发起人uuid，结束后置空" json:"-"`
+	LeaseUntil   *time.Time `gorm:"column:lease_until;comment:执行实例的租约到期时间" json:"-"`
+}
+
+func (ExportJob) TableName() string {
+	return "export_job"
+}
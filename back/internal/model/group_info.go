@@ -2,15 +2,12 @@
 // 文件：back/internal/model/group_info.go
 // 作用：定义群聊主表模型，对应数据库的 group_info 表。
 //
-// Members 字段（JSON 列）：
-//   类型是 json.RawMessage，数据库列类型是 JSON。
-//   存储格式：["userId1", "userId2", "userId3"]（用户UUID的 JSON 数组）
-//   为什么不用单独的群成员表？
-//   - 群成员不需要复杂查询（查成员只需要反序列化这个数组）
-//   - JSON 列比额外的关联表省一次 JOIN，读取更快
-//   - 缺点：成员数量很多时，更新一个成员就要读写整个 JSON，有并发安全问题
-//     （本项目群成员数量有限，这个权衡是合理的）
-//   查找"我参与的群"时，使用 MySQL 的 JSON_CONTAINS 函数在数据库层过滤。
+// Members 字段（JSON 列，已废弃）：
+//   早期版本用这个 JSON 数组（["userId1", "userId2"]）保存群成员，
+//   并发入群会互相覆盖，也无法记录成员角色、入群时间等属性。
+//   现在群成员统一保存在 group_member 表（见 group_member.go），
+//   这个列只作为旧数据迁移的来源保留，业务代码不再读写。
+//   MemberCnt 仍然保留，作为成员数的冗余计数，随入群/退群同步增减。
 //
 // Uuid 的设计（6位数字）：
 //   群 UUID 是 6 位随机数字（000000~999999），比用户 UUID 短，
@@ -36,7 +33,7 @@ type GroupInfo struct {
 	Uuid      string          `gorm:"column:uuid;uniqueIndex;type:char(6);not null;comment:群组唯一id"`
 	Name      string          `gorm:"column:name;type:varchar(20);not null;comment:群名称"`
 	Notice    string          `gorm:"column:notice;type:varchar(500);comment:群公告"`
-	Members   json.RawMessage `gorm:"column:members;type:json;comment:群组成员"`
+	Members   json.RawMessage `gorm:"column:members;type:json;comment:群组成员（已废弃，见group_member表）"`
 	MemberCnt int             `gorm:"column:member_cnt;default:1;comment:群人数"`
 	OwnerId   string          `gorm:"column:owner_id;type:char(20);not null;comment:群主uuid"`
 	AddMode   int8            `gorm:"column:add_mode;default:0;comment:加群方式，0.直接，1.审核"`
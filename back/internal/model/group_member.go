@@ -0,0 +1,47 @@
+// ============================================================
+// 文件：back/internal/model/group_member.go
+// 作用：定义群成员表模型，对应数据库的 group_member 表。
+//
+// 为什么从 group_info.members（JSON 数组）拆成独立的表？
+//   JSON 列每次加人/踢人都要"读出整个数组 → 修改 → 整体写回"，
+//   两个人同时入群时后写的会覆盖先写的（丢成员）；群越大，这次读写越重。
+//   拆成一行一个成员之后：
+//   · 入群就是 INSERT 一行，退群就是 DELETE 一行，互不覆盖
+//   · (group_id, user_id) 唯一索引从数据库层面防止重复入群
+//   · "我加入的群" 走 user_id 索引，不再需要 JSON_CONTAINS 全表扫描
+//   · 每个成员可以带上自己的属性：角色、邀请人、入群时间、禁言截止时间
+//
+// Role 字段：
+//   0 = 普通成员
+//   1 = 管理员
+//   2 = 群主（与 group_info.owner_id 保持一致）
+//
+// MutedUntil：
+//   NULL 表示未被禁言；非 NULL 且晚于当前时间表示禁言中。
+//
+// 旧数据迁移见 back/database/group_member_migration.go。
+// ============================================================
+package model
+
+import "time"
+
+// 群成员角色
+const (
+	GroupRoleMember int8 = 0
+	GroupRoleAdmin  int8 = 1
+	GroupRoleOwner  int8 = 2
+)
+
+type GroupMember struct {
+	Id         int64      `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	GroupId    string     `gorm:"column:group_id;type:char(6);not null;comment:群组uuid;uniqueIndex:idx_group_user,priority:1" json:"groupId"`
+	UserId     string     `gorm:"column:user_id;type:char(20);not null;index;comment:成员uuid;uniqueIndex:idx_group_user,priority:2" json:"userId"`
+	Role       int8       `gorm:"column:role;not null;default:0;comment:角色，0.成员，1.管理员，2.群主" json:"role"`
+	InviterId  string     `gorm:"column:inviter_id;type:char(20);comment:邀请人/审核人uuid，直接加入为空" json:"inviterId"`
+	MutedUntil *time.Time `gorm:"column:muted_until;comment:禁言截止时间" json:"mutedUntil"`
+	JoinedAt   time.Time  `gorm:"column:joined_at;not null;comment:入群时间" json:"joinedAt"`
+}
+
+func (GroupMember) TableName() string {
+	return "group_member"
+}
@@ -7,9 +7,25 @@
 //   - type = 0：文本消息，使用 content 字段
 //   - type = 1：文件消息，使用 url / fileName / fileType / fileSize 字段
 //   - type = 2：通话记录，使用 avData 字段
+//   - type = 3：合并转发的聊天记录，content 是标题，record 是按时间排好序的消息快照
 //   没有用到的字段留空（NULL），这叫"宽表"设计，用一张表覆盖多种场景，
 //   避免了多表联查的复杂性（代价是有些字段会浪费空间）。
 //
+// EditedAt（最后编辑时间）：
+//   NULL 表示从未编辑过；编辑前的旧版本保存在 message_edit 表。
+//
+// ReplyTo / RootId（回复与话题）：
+//   ReplyTo 是直接引用的消息，RootId 是所在话题的根消息（见 chat/reply.go），
+//   普通消息两者都为空。idx_root_time 用于按话题分页列出回复。
+//
+// Mentions（@ 提醒）：
+//   被 @ 的用户 uuid 列表，"all" 表示 @所有人，以 JSON 数组存储；
+//   按用户查询"@我"走 message_mention 索引表，不查这一列。
+//
+// ExpireAt（阅后即焚）：
+//   会话开启了消息自动删除时（见 disappearing_timer.go），发送时写入过期时间；
+//   NULL 表示永久保留。后台清理任务按这一列的索引找出到期的消息并物理删除。
+//
 // ReadAt（已读时间）：
 //   指针类型 *time.Time，NULL 表示"未读"，非 NULL 表示"已读，时间是XXX"。
 //   用 NULL 而不是 bool(IsRead) 的好处：可以知道消息是什么时候被读的。
@@ -42,11 +58,41 @@ type Message struct {
 	FileType   string     `gorm:"column:file_type;type:char(10);comment:文件类型" json:"fileType"`
 	FileName   string     `gorm:"column:file_name;type:varchar(255);comment:文件名" json:"fileName"`
 	FileSize   string     `gorm:"column:file_size;type:char(20);comment:文件大小" json:"fileSize"`
-	Status     int8       `gorm:"column:status;not null;comment:状态，0.未发送，1.已发送" json:"status"`
+	Status     int8       `gorm:"column:status;not null;comment:状态，0.未发送，1.已发送，2.已送达" json:"status"`
 	IsRecalled int8       `gorm:"column:is_recalled;default:0;comment:是否撤回，0.否，1.是" json:"isRecalled"`
 	ReadAt     *time.Time `gorm:"column:read_at;comment:已读时间" json:"readAt"`
-	CreatedAt  time.Time  `gorm:"column:created_at;not null;comment:创建时间;index:idx_session_time,priority:2;index:idx_receive_time,priority:2" json:"createdAt"`
+	EditedAt   *time.Time `gorm:"column:edited_at;comment:最后编辑时间" json:"editedAt"`
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;comment:创建时间;index:idx_session_time,priority:2;index:idx_receive_time,priority:2;index:idx_root_time,priority:2" json:"createdAt"`
 	AVdata     string     `gorm:"column:av_data;comment:通话传递数据" json:"avData"`
+	ReplyTo    string     `gorm:"column:reply_to;type:char(20);comment:回复的消息uuid" json:"replyTo,omitempty"`
+	RootId     string     `gorm:"column:root_id;type:char(20);comment:话题根消息uuid;index:idx_root_time,priority:1" json:"rootId,omitempty"`
+	Mentions   []string   `gorm:"column:mentions;type:json;serializer:json;comment:被@的用户" json:"mentions,omitempty"`
+	ExpireAt   *time.Time `gorm:"column:expire_at;index;comment:过期时间，NULL表示永久保留" json:"expireAt,omitempty"`
+
+	Record *ForwardRecord `gorm:"column:record;type:json;serializer:json;comment:合并转发的聊天记录" json:"record,omitempty"`
+}
+
+// ForwardRecord 是合并转发消息（type=3）携带的聊天记录快照。
+// 转发之后源消息被编辑、撤回或删除，都不影响这里的内容；附件只保存 url，不复制文件。
+type ForwardRecord struct {
+	Title string        `json:"title"`
+	Items []ForwardItem `json:"items"`
+}
+
+// ForwardItem 是聊天记录里的一条消息。转发的消息本身是聊天记录时，Record 保存嵌套的那一份。
+type ForwardItem struct {
+	MsgId      string         `json:"msgId"`
+	Type       int8           `json:"type"`
+	SendId     string         `json:"sendId"`
+	SendName   string         `json:"sendName"`
+	SendAvatar string         `json:"sendAvatar"`
+	Content    string         `json:"content,omitempty"`
+	Url        string         `json:"url,omitempty"`
+	FileName   string         `json:"fileName,omitempty"`
+	FileType   string         `json:"fileType,omitempty"`
+	FileSize   string         `json:"fileSize,omitempty"`
+	CreatedAt  int64          `json:"createdAt"`
+	Record     *ForwardRecord `json:"record,omitempty"`
 }
 
 // TableName 显式指定数据库表名。
@@ -0,0 +1,26 @@
+// ============================================================
+// 文件：back/internal/model/message_edit.go
+// 作用：定义消息编辑历史表模型，对应 message_edit 表。
+//
+// 每编辑一次，就把"被替换掉的旧内容"存一行：
+//   第 1 次编辑 → 存原始内容
+//   第 2 次编辑 → 存第 1 次编辑后的内容
+//   ……
+// message 表里始终是最新内容，按 edited_at 升序把这里的记录排起来，
+// 再接上 message.content，就是完整的版本链。
+// ============================================================
+package model
+
+import "time"
+
+type MessageEdit struct {
+	Id       int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	MsgUuid  string    `gorm:"column:msg_uuid;type:char(20);not null;index;comment:消息uuid" json:"msgId"`
+	Content  string    `gorm:"column:content;type:TEXT;comment:被替换前的内容" json:"content"`
+	EditorId string    `gorm:"column:editor_id;type:char(20);not null;comment:编辑人uuid" json:"editorId"`
+	EditedAt time.Time `gorm:"column:edited_at;not null;comment:本次编辑时间" json:"editedAt"`
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edit"
+}
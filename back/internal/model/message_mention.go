@@ -0,0 +1,29 @@
+// ============================================================
+// 文件：back/internal/model/message_mention.go
+// 作用：定义 @ 提醒索引表模型，对应 message_mention 表。
+//
+// 一行 = 某条群消息 @ 了某个用户。
+// @所有人 在写库时展开成每个成员一行（发送者本人除外），
+// 这样"我的未读 @"只需要按 user_id 查一张表，不用再区分两种情况。
+//
+// ReadAt 为 NULL 表示未读；用户在"@我"列表里查看或进入群聊后标记已读。
+// (msg_uuid, user_id) 唯一，消费者重复处理同一条消息时不会产生重复提醒。
+// ============================================================
+package model
+
+import "time"
+
+type MessageMention struct {
+	Id        int64      `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	MsgUuid   string     `gorm:"column:msg_uuid;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_msg_user,priority:1" json:"msgId"`
+	UserId    string     `gorm:"column:user_id;type:char(20);not null;comment:被@的用户uuid;uniqueIndex:idx_msg_user,priority:2;index:idx_user_time,priority:1" json:"userId"`
+	GroupId   string     `gorm:"column:group_id;type:char(20);not null;comment:群聊uuid" json:"groupId"`
+	SendId    string     `gorm:"column:send_id;type:char(20);not null;comment:发送者uuid" json:"sendId"`
+	IsAll     int8       `gorm:"column:is_all;default:0;comment:是否来自@所有人，0.否，1.是" json:"isAll"`
+	ReadAt    *time.Time `gorm:"column:read_at;comment:已读时间" json:"readAt"`
+	CreatedAt time.Time  `gorm:"column:created_at;not null;comment:创建时间;index:idx_user_time,priority:2" json:"createdAt"`
+}
+
+func (MessageMention) TableName() string {
+	return "message_mention"
+}
@@ -0,0 +1,24 @@
+// ============================================================
+// 文件：back/internal/model/message_reaction.go
+// 作用：定义消息表情回应表模型，对应 message_reaction 表。
+//
+// 一行 = 某个用户对某条消息点了某个表情。
+// (msg_uuid, user_id, emoji) 唯一：同一个人对同一条消息的同一个表情只能点一次，
+// 但可以点多个不同的表情。
+// 列表接口按 (msg_uuid, emoji) 分组计数，得到"👍 3  ❤️ 1"这样的聚合结果。
+// ============================================================
+package model
+
+import "time"
+
+type MessageReaction struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	MsgUuid   string    `gorm:"column:msg_uuid;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_msg_user_emoji,priority:1" json:"msgId"`
+	UserId    string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_msg_user_emoji,priority:2" json:"userId"`
+	Emoji     string    `gorm:"column:emoji;type:varchar(32);not null;comment:表情;uniqueIndex:idx_msg_user_emoji,priority:3" json:"emoji"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;comment:创建时间" json:"createdAt"`
+}
+
+func (MessageReaction) TableName() string {
+	return "message_reaction"
+}
@@ -0,0 +1,28 @@
+// ============================================================
+// 文件：back/internal/model/message_search.go
+// 作用：定义消息全文检索表模型，对应 message_search 表（search 包 MySQL 实现使用）。
+//
+// 为什么不直接在 message.content 上建全文索引？
+//   · message 是写入最频繁的大表，全文索引会拖慢每一次 INSERT
+//   · 文件消息要按文件名搜索，通话、系统事件不需要搜索，单独一张表更好控制"搜什么"
+//   · 以后换成 ES 之类的外部引擎时，只需要替换 search 包的实现，message 表不用动
+//
+// FULLTEXT 索引使用 ngram 分词器（MySQL 5.7.6+ 内置），默认按 2 个字切分，
+// 中文不需要额外的分词词典也能搜索。
+// ============================================================
+package model
+
+import "time"
+
+type MessageSearch struct {
+	MsgUuid   string    `gorm:"column:msg_uuid;primaryKey;type:char(20);comment:消息uuid" json:"msgId"`
+	SendId    string    `gorm:"column:send_id;type:char(20);not null;index;comment:发送者uuid" json:"sendId"`
+	ReceiveId string    `gorm:"column:receive_id;type:char(20);not null;index;comment:接收者uuid或群uuid" json:"receiveId"`
+	Type      int8      `gorm:"column:type;not null;comment:消息类型" json:"type"`
+	Content   string    `gorm:"column:content;type:TEXT;comment:可搜索的文本;index:idx_ft_content,class:FULLTEXT,option:WITH PARSER ngram" json:"content"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;index;comment:消息发送时间" json:"createdAt"`
+}
+
+func (MessageSearch) TableName() string {
+	return "message_search"
+}
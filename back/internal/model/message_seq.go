@@ -0,0 +1,47 @@
+// ============================================================
+// 文件：back/internal/model/message_seq.go
+// 作用：定义"每用户消息序列号"相关的两张表，支撑断线重连后的增量同步。
+//
+// 为什么需要序列号？
+//   WebSocket 断线期间推送的消息、或者因为下行拥塞被丢弃的消息，
+//   客户端都收不到。只靠时间戳补拉不可靠（同一秒多条消息、服务器时钟漂移），
+//   所以给"每个用户能看到的每条消息"分配一个严格递增、连续的序号 seq。
+//   客户端记住自己见过的最大 seq，重连时带上 since=seq，服务端把缺的补发过来。
+//   seq 连续还有一个好处：客户端看到 seq 从 5 跳到 7，就知道 6 丢了，可以主动补拉。
+//
+// UserSequence（user_sequence 表）：
+//   每个用户一行，max_seq 是当前已分配的最大序号。
+//   分配序号用 INSERT ... ON DUPLICATE KEY UPDATE max_seq = max_seq + 1，
+//   在持久化事务里执行，行锁保证并发安全。
+//
+// MessageSeq（message_seq 表）：
+//   "用户 X 的第 N 号消息是哪条消息"。私聊一条消息对应两行（收发双方），
+//   群聊一条消息对应每个成员一行。
+//   (user_id, seq) 唯一：按序号范围查询；(user_id, msg_uuid) 唯一：重复消费时不会重复分配。
+// ============================================================
+package model
+
+import "time"
+
+// UserSequence 记录每个用户当前已分配的最大消息序号。
+type UserSequence struct {
+	UserId string `gorm:"column:user_id;primaryKey;type:char(20);comment:用户uuid"`
+	MaxSeq int64  `gorm:"column:max_seq;not null;default:0;comment:已分配的最大序号"`
+}
+
+func (UserSequence) TableName() string {
+	return "user_sequence"
+}
+
+// MessageSeq 把消息映射到某个用户的序号上。
+type MessageSeq struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId    string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_seq,priority:1;uniqueIndex:idx_user_msg,priority:1"`
+	Seq       int64     `gorm:"column:seq;not null;comment:用户内递增序号;uniqueIndex:idx_user_seq,priority:2"`
+	MsgUuid   string    `gorm:"column:msg_uuid;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_user_msg,priority:2"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;comment:创建时间"`
+}
+
+func (MessageSeq) TableName() string {
+	return "message_seq"
+}
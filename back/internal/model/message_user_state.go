@@ -0,0 +1,44 @@
+// ============================================================
+// 文件：back/internal/model/message_user_state.go
+// 作用：定义"仅对自己删除"的两张表：单条消息隐藏、整个会话清空。
+//
+// 和撤回、旧版清空记录的区别：
+//   撤回（is_recalled）对所有人生效；旧版 ClearConversation 物理删除双方的记录。
+//   这里只记录"某个用户不想再看到哪些消息"，message 表本身不动，
+//   对方（以及群里其他人）的历史记录完全不受影响。
+//
+// MessageUserState（message_user_state 表）：
+//   一行 = 某个用户隐藏了某条消息。
+//
+// ConversationClear（conversation_clear 表）：
+//   一行 = 某个用户在某个会话里"清空到哪里"。
+//   ClearedMsgId 记录清空时该会话最新一条消息的自增 id，id 不大于它的消息对该用户不可见；
+//   之后的新消息照常显示。再次清空时覆盖这个值。
+//   TargetId 是私聊对方的用户 uuid 或群 uuid。
+// ============================================================
+package model
+
+import "time"
+
+type MessageUserState struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	UserId    string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_msg_state,priority:1" json:"userId"`
+	MsgUuid   string    `gorm:"column:msg_uuid;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_user_msg_state,priority:2" json:"msgId"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;comment:隐藏时间" json:"createdAt"`
+}
+
+func (MessageUserState) TableName() string {
+	return "message_user_state"
+}
+
+type ConversationClear struct {
+	Id           int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	UserId       string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_target,priority:1" json:"userId"`
+	TargetId     string    `gorm:"column:target_id;type:char(20);not null;comment:会话对象uuid（用户或群）;uniqueIndex:idx_user_target,priority:2" json:"targetId"`
+	ClearedMsgId int64     `gorm:"column:cleared_msg_id;not null;comment:清空时最新消息的自增id" json:"clearedMsgId"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;not null;comment:最后清空时间" json:"updatedAt"`
+}
+
+func (ConversationClear) TableName() string {
+	return "conversation_clear"
+}
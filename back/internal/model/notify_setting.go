@@ -0,0 +1,27 @@
+// ============================================================
+// 文件：back/internal/model/notify_setting.go
+// 作用：定义通知设置表 notify_setting：每个用户一行，控制离线邮件摘要。
+//
+//   EmailDigest          是否接收离线邮件摘要（默认接收；没有记录时也按接收处理）
+//   QuietStart/QuietEnd  免打扰时段，格式 "HH:MM"，可以跨零点（如 22:00 ~ 08:00）；
+//                        两个都为空表示不设免打扰。时段内不发邮件，结束后再把积攒的消息一起发出
+//   TimeZone             免打扰时段所在的时区（IANA 名称，如 Asia/Shanghai），为空时按服务器时区
+//
+// 总开关在配置文件 [notifyConfig] emailDigest；这里只是每个用户自己的选择。
+// ============================================================
+package model
+
+import "time"
+
+type NotifySetting struct {
+	UserId      string    `gorm:"column:user_id;primaryKey;type:char(20);comment:用户uuid"`
+	EmailDigest int8      `gorm:"column:email_digest;not null;default:1;comment:是否接收离线邮件摘要"`
+	QuietStart  string    `gorm:"column:quiet_start;type:char(5);not null;default:'';comment:免打扰开始时间 HH:MM"`
+	QuietEnd    string    `gorm:"column:quiet_end;type:char(5);not null;default:'';comment:免打扰结束时间 HH:MM"`
+	TimeZone    string    `gorm:"column:time_zone;type:varchar(64);not null;default:'';comment:免打扰时段的时区"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;not null;comment:最后修改时间"`
+}
+
+func (NotifySetting) TableName() string {
+	return "notify_setting"
+}
@@ -0,0 +1,40 @@
+// ============================================================
+// 文件：back/internal/model/offline_notice.go
+// 作用：定义离线通知表 offline_notice：用户不在线时收到、还没通知到的消息，一行 = (收件人, 消息)。
+//
+// 记录哪些消息？
+//   Kind=1 私聊消息：推送时接收方没有任何连接
+//   Kind=2 @ 提醒：群消息 @ 了这个人（含 @所有人），而他不在线
+//   对会话开了免打扰的不记录；系统消息不记录。
+//
+// 生命周期（见 chat/offline_notice.go、chat/offline_digest.go）：
+//   1. 推送时发现收件人离线 → 插入一行，DigestId 为空表示"待通知"
+//   2. 用户重新上线 → 删除他全部待通知的行（消息已经能通过补发看到了）
+//   3. 离线超过配置的时长 → 摘要任务写入 DigestId 抢占这些行，合并成一封邮件发送，成功后删除
+// (user_id, msg_id) 唯一，多个节点重复记录同一条消息时只保留一行。
+// ============================================================
+package model
+
+import "time"
+
+const (
+	OfflineNoticeDirect  int8 = 1 // 私聊消息
+	OfflineNoticeMention int8 = 2 // 群里 @ 了我
+)
+
+type OfflineNotice struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId    string    `gorm:"column:user_id;type:char(20);not null;comment:收件人uuid;uniqueIndex:idx_user_msg,priority:1"`
+	MsgId     string    `gorm:"column:msg_id;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_user_msg,priority:2"`
+	Kind      int8      `gorm:"column:kind;not null;comment:1.私聊消息 2.@提醒"`
+	SendId    string    `gorm:"column:send_id;type:char(20);not null;comment:发送者uuid"`
+	SendName  string    `gorm:"column:send_name;type:varchar(20);not null;default:'';comment:发送者昵称"`
+	TargetId  string    `gorm:"column:target_id;type:char(20);not null;comment:会话：私聊是发送者uuid，@提醒是群uuid"`
+	Excerpt   string    `gorm:"column:excerpt;type:varchar(200);not null;default:'';comment:消息摘要"`
+	DigestId  string    `gorm:"column:digest_id;type:char(20);not null;default:'';index;comment:抢占这一行的摘要任务ID，空表示待通知"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;index;comment:消息时间"`
+}
+
+func (OfflineNotice) TableName() string {
+	return "offline_notice"
+}
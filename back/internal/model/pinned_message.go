@@ -0,0 +1,24 @@
+// ============================================================
+// 文件：back/internal/model/pinned_message.go
+// 作用：定义会话置顶消息表 pinned_message：一行 = 某个会话置顶了某条消息。
+//
+// ConversationKey 与 disappearing_timer 相同（见 disappearing_timer.go）：
+//   群聊 "G:" + 群uuid，私聊两个用户 uuid 排序后拼接。
+// 置顶对会话的全部参与者可见，每个会话最多 messageConfig.maxPinned 条。
+// (conversation_key, msg_uuid) 唯一，重复置顶同一条消息不会产生两行。
+// ============================================================
+package model
+
+import "time"
+
+type PinnedMessage struct {
+	Id              int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	ConversationKey string    `gorm:"column:conversation_key;type:varchar(45);not null;comment:会话标识;uniqueIndex:idx_conv_msg,priority:1" json:"-"`
+	MsgUuid         string    `gorm:"column:msg_uuid;type:char(20);not null;comment:消息uuid;uniqueIndex:idx_conv_msg,priority:2" json:"msgId"`
+	PinnedBy        string    `gorm:"column:pinned_by;type:char(20);not null;comment:置顶人uuid" json:"pinnedBy"`
+	CreatedAt       time.Time `gorm:"column:created_at;not null;comment:置顶时间" json:"pinnedAt"`
+}
+
+func (PinnedMessage) TableName() string {
+	return "pinned_message"
+}
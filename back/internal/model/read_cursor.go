@@ -0,0 +1,32 @@
+// ============================================================
+// 文件：back/internal/model/read_cursor.go
+// 作用：定义已读游标表 read_cursor：一行 = 某个用户在某个会话里读到了哪条消息。
+//
+// ConversationKey 与 disappearing_timer 相同（见 disappearing_timer.go）：
+//   群聊 "G:" + 群uuid，私聊两个用户 uuid 排序后拼接。
+//
+// 游标记录三个位置，用途不同：
+//   LastReadId    读到的消息的自增 id。同一会话里 id 越大消息越新，
+//                 "这条消息之后还有几条未读"、"这条群消息被谁读过"都按它比较
+//   LastReadMsgId 读到的消息的 uuid，推给前端用
+//   LastReadSeq   这条消息在该用户自己序列里的 seq（见 message_seq.go），还没分配时为 0
+// 游标只会往前移，标记一条更早的消息为已读不会让游标后退。
+// (user_id, conversation_key) 唯一；(conversation_key, last_read_id) 用于查群消息的已读成员。
+// ============================================================
+package model
+
+import "time"
+
+type ReadCursor struct {
+	Id              int64     `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	UserId          string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_conv,priority:1" json:"userId"`
+	ConversationKey string    `gorm:"column:conversation_key;type:varchar(45);not null;comment:会话标识;uniqueIndex:idx_user_conv,priority:2;index:idx_conv_read,priority:1" json:"-"`
+	LastReadId      int64     `gorm:"column:last_read_id;not null;default:0;comment:已读到的消息自增id;index:idx_conv_read,priority:2" json:"-"`
+	LastReadMsgId   string    `gorm:"column:last_read_msg_id;type:char(20);not null;default:'';comment:已读到的消息uuid" json:"lastReadMsgId"`
+	LastReadSeq     int64     `gorm:"column:last_read_seq;not null;default:0;comment:已读到的消息在用户序列中的seq" json:"lastReadSeq"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;not null;comment:最后已读时间" json:"readAt"`
+}
+
+func (ReadCursor) TableName() string {
+	return "read_cursor"
+}
@@ -0,0 +1,57 @@
+// ============================================================
+// 文件：back/internal/model/scheduled_message.go
+// 作用：定义定时消息表模型，对应 scheduled_message 表。
+//
+// 生命周期：
+//   0 待发送 → 1 发送中 → 2 已发送
+//           ↘ 3 已取消（用户取消）        ↘ 4 发送失败（ErrorMsg 记录原因）
+//
+// MsgId 在创建时就预先分配：
+//   调度器把它作为消息ID发布到总线，持久化消费者按 uuid 做幂等。
+//   即使发布成功后进程崩溃、重启后再发布一次，数据库里也只会有一条消息。
+//
+// 多实例部署：
+//   调度器先用条件 UPDATE（WHERE status=0）"抢占"一条记录，抢到的实例才发送，
+//   LockedUntil 是抢占的有效期，发送中途进程退出的记录过期后会被重新抢占。
+// ============================================================
+package model
+
+import "time"
+
+// 定时消息状态
+const (
+	ScheduledStatusPending   = 0
+	ScheduledStatusSending   = 1
+	ScheduledStatusSent      = 2
+	ScheduledStatusCancelled = 3
+	ScheduledStatusFailed    = 4
+)
+
+type ScheduledMessage struct {
+	Id          int64      `gorm:"column:id;primaryKey;comment:自增id" json:"-"`
+	Uuid        string     `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:定时消息uuid" json:"id"`
+	MsgId       string     `gorm:"column:msg_id;type:char(20);not null;comment:预分配的消息uuid" json:"msgId"`
+	SendId      string     `gorm:"column:send_id;type:char(20);not null;index;comment:发送者uuid" json:"sendId"`
+	ReceiveId   string     `gorm:"column:receive_id;type:char(20);not null;comment:接收者uuid或群uuid" json:"receiveId"`
+	Type        int8       `gorm:"column:type;not null;comment:消息类型，0.文本，1.文件" json:"type"`
+	Content     string     `gorm:"column:content;type:TEXT;comment:消息内容" json:"content"`
+	Url         string     `gorm:"column:url;type:char(255);comment:文件url" json:"url"`
+	FileName    string     `gorm:"column:file_name;type:varchar(255);comment:文件名" json:"fileName"`
+	FileType    string     `gorm:"column:file_type;type:char(10);comment:文件类型" json:"fileType"`
+	FileSize    string     `gorm:"column:file_size;type:char(20);comment:文件大小" json:"fileSize"`
+	ReplyTo     string     `gorm:"column:reply_to;type:char(20);comment:回复的消息uuid" json:"replyTo,omitempty"`
+	Mentions    []string   `gorm:"column:mentions;type:json;serializer:json;comment:被@的用户" json:"mentions,omitempty"`
+	SendAt      time.Time  `gorm:"column:send_at;not null;comment:计划发送时间;index:idx_status_send_at,priority:2" json:"sendAt"`
+	Status      int8       `gorm:"column:status;not null;default:0;comment:状态，0.待发送，1.发送中，2.已发送，3.已取消，4.发送失败;index:idx_status_send_at,priority:1" json:"status"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0;comment:已尝试发送次数" json:"-"`
+	LockedBy    string     `gorm:"column:locked_by;type:varchar(64);comment:抢占发送的节点" json:"-"`
+	LockedUntil *time.Time `gorm:"column:locked_until;comment:抢占有效期" json:"-"`
+	ErrorMsg    string     `gorm:"column:error_msg;type:varchar(255);comment:失败原因" json:"errorMsg,omitempty"`
+	SentAt      *time.Time `gorm:"column:sent_at;comment:实际发送时间" json:"sentAt"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null;comment:创建时间" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;not null;comment:更新时间" json:"updatedAt"`
+}
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_message"
+}
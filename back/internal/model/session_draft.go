@@ -0,0 +1,26 @@
+// ============================================================
+// 文件：back/internal/model/session_draft.go
+// 作用：定义会话草稿表 session_draft：用户在某个会话输入框里还没发出去的内容，一行 = (用户, 对方用户或群)。
+//
+// 草稿保存在服务端，换设备（电脑 ↔ 手机）后可以接着写：
+//   前端输入停顿一会儿（防抖）后通过 WebSocket 保存，内容为空时删除这一行；
+//   在这个会话里发出消息后自动删除（见 chat/draft.go）。
+// ReplyTo 是草稿正在回复的消息，恢复草稿时一起恢复引用。
+// 只属于自己，不会推给会话里的其他人。
+// ============================================================
+package model
+
+import "time"
+
+type SessionDraft struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId    string    `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_target,priority:1"`
+	TargetId  string    `gorm:"column:target_id;type:char(20);not null;comment:对方用户或群uuid;uniqueIndex:idx_user_target,priority:2"`
+	Content   string    `gorm:"column:content;type:TEXT;comment:草稿内容"`
+	ReplyTo   string    `gorm:"column:reply_to;type:char(20);not null;default:'';comment:回复的消息uuid"`
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;comment:最后修改时间"`
+}
+
+func (SessionDraft) TableName() string {
+	return "session_draft"
+}
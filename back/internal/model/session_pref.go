@@ -0,0 +1,33 @@
+// ============================================================
+// 文件：back/internal/model/session_pref.go
+// 作用：定义会话偏好表 session_pref：某个用户对某个会话的个人设置，一行 = (用户, 对方用户或群)。
+//
+// 字段含义：
+//   Pinned / PinOrder 置顶；置顶的会话排在列表最前，之间按 PinOrder 从小到大（可以拖动调整）
+//   Muted / MuteUntil 免打扰；MuteUntil 为空表示一直免打扰，到期后自动失效（查询时判断，不用定时任务）
+//   Archived          归档；会话仍然存在，前端放进"已归档"分组
+//   MarkedUnread      手动标记为未读；下次标记已读时自动清除
+//
+// 只影响自己，不影响会话里的其他人。没有记录时全部按默认值（不置顶、不免打扰……）处理。
+// (user_id, target_id) 唯一；target_id 单独建索引，用于"这个群里谁开了免打扰"。
+// ============================================================
+package model
+
+import "time"
+
+type SessionPref struct {
+	Id           int64      `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId       string     `gorm:"column:user_id;type:char(20);not null;comment:用户uuid;uniqueIndex:idx_user_target,priority:1"`
+	TargetId     string     `gorm:"column:target_id;type:char(20);not null;comment:对方用户或群uuid;uniqueIndex:idx_user_target,priority:2;index"`
+	Pinned       int8       `gorm:"column:pinned;not null;default:0;comment:是否置顶"`
+	PinOrder     int        `gorm:"column:pin_order;not null;default:0;comment:置顶顺序，越小越靠前"`
+	Muted        int8       `gorm:"column:muted;not null;default:0;comment:是否免打扰"`
+	MuteUntil    *time.Time `gorm:"column:mute_until;comment:免打扰截止时间，为空表示一直免打扰"`
+	Archived     int8       `gorm:"column:archived;not null;default:0;comment:是否归档"`
+	MarkedUnread int8       `gorm:"column:marked_unread;not null;default:0;comment:是否手动标记为未读"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;not null;comment:最后修改时间"`
+}
+
+func (SessionPref) TableName() string {
+	return "session_pref"
+}
@@ -0,0 +1,36 @@
+// ============================================================
+// 文件：back/internal/model/user_status.go
+// 作用：定义用户在线状态设置表 user_status：每个用户一行。
+//
+// 这里存的是用户"自己选择"的状态，不是"是否有连接"：
+//   online    在线（默认，没有记录时也按 online 处理）
+//   away      离开
+//   busy      忙碌
+//   invisible 隐身：有连接，但对别人显示为离线
+// StatusText 是自定义状态文字（比如"开会中"），最多 64 个字符。
+//
+// 用户没有任何连接时，别人看到的始终是 offline；连上之后显示这里保存的状态。
+// 状态保存在数据库里，重连、换设备、重启服务都不会丢。
+// ============================================================
+package model
+
+import "time"
+
+const (
+	UserStatusOnline    = "online"
+	UserStatusAway      = "away"
+	UserStatusBusy      = "busy"
+	UserStatusInvisible = "invisible"
+	UserStatusOffline   = "offline" // 只用于推送给别人，不会写进这张表
+)
+
+type UserStatus struct {
+	UserId     string    `gorm:"column:user_id;primaryKey;type:char(20);comment:用户uuid" json:"userId"`
+	Status     string    `gorm:"column:status;type:varchar(16);not null;default:online;comment:状态，online/away/busy/invisible" json:"status"`
+	StatusText string    `gorm:"column:status_text;type:varchar(64);not null;default:'';comment:自定义状态文字" json:"statusText"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;not null;comment:最后修改时间" json:"updatedAt"`
+}
+
+func (UserStatus) TableName() string {
+	return "user_status"
+}
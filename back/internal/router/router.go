@@ -49,14 +49,38 @@ import (
 func initMessageRoutes(r *gin.RouterGroup) {
 	message := r.Group("/message")
 	{
-		message.POST("/list", v1.GetMessageList)                   // 获取私聊消息
-		message.POST("/groupList", v1.GetGroupMessageList)         // 获取群聊消息
-		message.POST("/uploadAvatar", v1.UploadAvatar)             // 上传头像（更新用户profile）
-		message.POST("/uploadImage", v1.UploadImage)               // 上传图片（群头像等，不绑定用户）
-		message.POST("/uploadFile", v1.UploadFile)                 // 上传文件
-		message.POST("/recall", v1.RecallMessageFull)              // 撤回消息
-		message.POST("/markRead", v1.MarkMessagesRead)             // 标记已读
-		message.POST("/clearConversation", v1.ClearConversation)   // 删除好友时清除聊天记录
+		message.POST("/list", v1.GetMessageList)                     // 获取私聊消息
+		message.POST("/groupList", v1.GetGroupMessageList)           // 获取群聊消息
+		message.POST("/thread", v1.GetMessageThread)                 // 获取话题（回复列表）
+		message.POST("/search", v1.SearchMessages)                   // 搜索消息
+		message.POST("/uploadAvatar", v1.UploadAvatar)               // 上传头像（更新用户profile）
+		message.POST("/uploadImage", v1.UploadImage)                 // 上传图片（群头像等，不绑定用户）
+		message.POST("/uploadFile", v1.UploadFile)                   // 上传文件
+		message.POST("/recall", v1.RecallMessageFull)                // 撤回消息
+		message.POST("/markRead", v1.MarkMessagesRead)               // 标记已读
+		message.POST("/read", v1.MarkConversationRead)               // 按已读游标标记会话已读（私聊、群聊通用）
+		message.GET("/readers", v1.GetMessageReaders)                // 消息的已读成员
+		message.POST("/clearConversation", v1.ClearConversation)     // 清空会话记录（仅自己）
+		message.POST("/deleteForMe", v1.DeleteMessagesForMe)         // 删除消息（仅自己）
+		message.POST("/edit", v1.EditMessage)                        // 编辑消息
+		message.GET("/editHistory", v1.GetMessageEditHistory)        // 消息编辑历史
+		message.POST("/reaction/add", v1.AddReaction)                // 添加表情回应
+		message.POST("/reaction/remove", v1.RemoveReaction)          // 取消表情回应
+		message.POST("/mentions", v1.GetMentions)                    // "@我"的未读提醒
+		message.POST("/mentions/read", v1.MarkMentionsRead)          // 标记@提醒已读
+		message.POST("/export", v1.CreateExport)                     // 导出会话记录（后台任务）
+		message.GET("/export/status", v1.GetExportStatus)            // 导出进度
+		message.GET("/export/download", v1.DownloadExport)           // 下载导出文件
+		message.POST("/scheduled/create", v1.CreateScheduledMessage) // 创建定时消息
+		message.GET("/scheduled/list", v1.ListScheduledMessages)     // 定时消息列表
+		message.POST("/scheduled/update", v1.UpdateScheduledMessage) // 修改定时消息
+		message.POST("/scheduled/cancel", v1.CancelScheduledMessage) // 取消定时消息
+		message.GET("/disappearing", v1.GetDisappearingTimer)        // 查询消息自动删除计时
+		message.POST("/disappearing", v1.SetDisappearingTimer)       // 设置消息自动删除计时
+		message.POST("/pin", v1.PinMessage)                          // 置顶消息
+		message.POST("/unpin", v1.UnpinMessage)                      // 取消置顶
+		message.GET("/pinned", v1.GetPinnedMessages)                 // 会话的置顶消息
+		message.POST("/forward", v1.ForwardMessages)                 // 转发消息（逐条 / 合并）
 	}
 
 }
@@ -66,6 +90,8 @@ func initUserRoutes(r *gin.RouterGroup) {
 	user := r.Group("/user")
 	{
 		user.POST("/update", v1.UpdateUserInfo)
+		user.GET("/notifySetting", v1.GetNotifySetting)     // 通知设置：离线邮件摘要、免打扰时段
+		user.POST("/notifySetting", v1.UpdateNotifySetting) // 修改通知设置
 
 	}
 }
@@ -104,6 +130,9 @@ func initGroupRoutes(r *gin.RouterGroup) {
 		group.POST("/updateNotice", v1.UpdateGroupNotice)
 		group.POST("/updateAvatar", v1.UpdateGroupAvatar)
 		group.GET("/info", v1.GetGroupInfo)
+		group.POST("/setAdmin", v1.SetGroupAdmin)           // 设置/取消管理员（群主）
+		group.POST("/muteMember", v1.MuteGroupMember)       // 禁言/解除禁言
+		group.POST("/transferOwner", v1.TransferGroupOwner) // 转让群主
 
 	}
 }
@@ -141,9 +170,12 @@ func initSessionRoutes(r *gin.RouterGroup) {
 	session := r.Group("/session")
 	{
 		session.POST("/open", v1.OpenSession)                    // 打开会话
+		session.GET("/list", v1.GetSessionList)                  // 会话列表（按最近活跃排序，带预览和未读数）
 		session.GET("/userList", v1.GetUserSessionList)          // 获取用户会话列表
 		session.GET("/groupList", v1.GetGroupSessionList)        // 获取群聊会话列表
 		session.POST("/delete", v1.DeleteSession)                // 删除会话
+		session.POST("/pref", v1.UpdateSessionPref)              // 会话偏好：置顶 / 免打扰 / 归档 / 标记未读
+		session.POST("/reorder", v1.ReorderPinnedSessions)       // 调整置顶会话顺序
 		session.GET("/checkAllowed", v1.CheckOpenSessionAllowed) // 检查是否允许打开
 	}
 
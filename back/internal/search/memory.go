@@ -0,0 +1,128 @@
+// ============================================================
+// 文件：back/internal/search/memory.go
+// 作用：SearchIndex 的进程内实现，不依赖数据库。
+//
+// 适用场景：
+//   本地开发（配合 messageMode=channel 一个二进制跑通）和测试。
+//
+// 实现方式：
+//   文档存在 map 里，查询时逐条做不区分大小写的子串匹配（多个关键词"且"），
+//   再按访问范围和过滤条件筛选。数据量大时是线性扫描，不要用于生产。
+//
+// 和 MySQL 实现的差异：
+//   · 进程重启后索引为空，只包含启动之后持久化的消息
+//   · 不会 JOIN message 表确认撤回状态，依赖撤回时调用 Delete
+//   · 不区分"仅对自己删除"的消息，这类消息仍然能被搜到
+// ============================================================
+
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memoryIndex struct {
+	mu   sync.RWMutex
+	docs map[string]Document
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{docs: make(map[string]Document)}
+}
+
+func (m *memoryIndex) Index(doc Document) error {
+	m.mu.Lock()
+	m.docs[doc.MsgId] = doc
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryIndex) Delete(msgIds ...string) error {
+	m.mu.Lock()
+	for _, id := range msgIds {
+		delete(m.docs, id)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryIndex) Search(q Query) (*Result, error) {
+	normalizePage(&q)
+	terms := Terms(q.Keyword)
+	if len(terms) == 0 {
+		return &Result{}, nil
+	}
+	groups := make(map[string]bool, len(q.GroupIds))
+	for _, g := range q.GroupIds {
+		groups[g] = true
+	}
+
+	m.mu.RLock()
+	var matched []Document
+	for _, d := range m.docs {
+		if visible(d, q, groups) && containsAll(d.Content, terms) {
+			matched = append(matched, d)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	res := &Result{Total: int64(len(matched))}
+	if q.Offset >= len(matched) {
+		return res, nil
+	}
+	end := min(len(matched), q.Offset+q.Limit)
+	for _, d := range matched[q.Offset:end] {
+		res.Hits = append(res.Hits, Hit{
+			MsgId:     d.MsgId,
+			SendId:    d.SendId,
+			ReceiveId: d.ReceiveId,
+			Type:      d.Type,
+			Content:   d.Content,
+			Snippet:   Highlight(d.Content, terms),
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// visible 判断文档是否在调用者的访问范围内，并满足过滤条件
+func visible(d Document, q Query, groups map[string]bool) bool {
+	if d.SendId != q.UserId && d.ReceiveId != q.UserId && !groups[d.ReceiveId] {
+		return false
+	}
+	if q.SenderId != "" && d.SendId != q.SenderId {
+		return false
+	}
+	if c := q.ConversationId; c != "" {
+		direct := (d.SendId == q.UserId && d.ReceiveId == c) || (d.SendId == c && d.ReceiveId == q.UserId)
+		if !direct && d.ReceiveId != c {
+			return false
+		}
+	}
+	if q.Type != nil && d.Type != *q.Type {
+		return false
+	}
+	if !q.StartTime.IsZero() && d.CreatedAt.Before(q.StartTime) {
+		return false
+	}
+	if !q.EndTime.IsZero() && !d.CreatedAt.Before(q.EndTime) {
+		return false
+	}
+	return true
+}
+
+func containsAll(text string, terms []string) bool {
+	lower := strings.ToLower(text)
+	for _, t := range terms {
+		if !strings.Contains(lower, strings.ToLower(t)) {
+			return false
+		}
+	}
+	return true
+}
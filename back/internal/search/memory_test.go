@@ -0,0 +1,128 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func int8p(v int8) *int8 { return &v }
+
+// newTestIndex 建一个带固定文档的内存索引：
+// U1 和 U2 私聊，U3 在群 G1 里发言，U3 和 U2 私聊（U1 看不到）
+func newTestIndex(t *testing.T) *memoryIndex {
+	t.Helper()
+	idx := newMemoryIndex()
+	for _, d := range []Document{
+		{MsgId: "M1", SendId: "U1", ReceiveId: "U2", Type: 0, Content: "Hello world", CreatedAt: t0},
+		{MsgId: "M2", SendId: "U2", ReceiveId: "U1", Type: 0, Content: "world peace", CreatedAt: t0.Add(time.Minute)},
+		{MsgId: "M3", SendId: "U3", ReceiveId: "G1", Type: 0, Content: "hello group world", CreatedAt: t0.Add(2 * time.Minute)},
+		{MsgId: "M4", SendId: "U3", ReceiveId: "U2", Type: 0, Content: "hello secret world", CreatedAt: t0.Add(3 * time.Minute)},
+		{MsgId: "M5", SendId: "U1", ReceiveId: "G1", Type: 1, Content: "world.pdf", CreatedAt: t0.Add(4 * time.Minute)},
+	} {
+		if err := idx.Index(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx
+}
+
+func hitIds(res *Result) []string {
+	ids := []string{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.MsgId)
+	}
+	return ids
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	idx := newTestIndex(t)
+	base := Query{UserId: "U1", GroupIds: []string{"G1"}}
+
+	tests := []struct {
+		name  string
+		edit  func(q *Query)
+		want  []string
+		total int64
+	}{
+		{"按时间倒序", func(q *Query) { q.Keyword = "world" }, []string{"M5", "M3", "M2", "M1"}, 4},
+		{"多个关键词取交集", func(q *Query) { q.Keyword = "hello world" }, []string{"M3", "M1"}, 2},
+		{"不区分大小写", func(q *Query) { q.Keyword = "HELLO" }, []string{"M3", "M1"}, 2},
+		{"空关键词", func(q *Query) { q.Keyword = "   " }, []string{}, 0},
+		{"不在群里看不到群消息", func(q *Query) { q.Keyword = "world"; q.GroupIds = nil }, []string{"M5", "M2", "M1"}, 3},
+		{"按发送者", func(q *Query) { q.Keyword = "world"; q.SenderId = "U2" }, []string{"M2"}, 1},
+		{"按私聊会话", func(q *Query) { q.Keyword = "world"; q.ConversationId = "U2" }, []string{"M2", "M1"}, 2},
+		{"按群会话", func(q *Query) { q.Keyword = "world"; q.ConversationId = "G1" }, []string{"M5", "M3"}, 2},
+		{"按类型", func(q *Query) { q.Keyword = "world"; q.Type = int8p(1) }, []string{"M5"}, 1},
+		{"时间范围左闭右开", func(q *Query) {
+			q.Keyword = "world"
+			q.StartTime = t0.Add(time.Minute)
+			q.EndTime = t0.Add(3 * time.Minute)
+		}, []string{"M3", "M2"}, 2},
+		{"分页", func(q *Query) { q.Keyword = "world"; q.Limit = 2; q.Offset = 1 }, []string{"M3", "M2"}, 4},
+		{"超出最后一页", func(q *Query) { q.Keyword = "world"; q.Offset = 10 }, []string{}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := base
+			tt.edit(&q)
+			res, err := idx.Search(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIds(res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hits = %v，期望 %v", got, tt.want)
+			}
+			if res.Total != tt.total {
+				t.Errorf("total = %d，期望 %d", res.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexHitFields(t *testing.T) {
+	idx := newTestIndex(t)
+	res, err := idx.Search(Query{UserId: "U1", Keyword: "peace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hit{
+		MsgId:     "M2",
+		SendId:    "U2",
+		ReceiveId: "U1",
+		Type:      0,
+		Content:   "world peace",
+		Snippet:   "world <em>peace</em>",
+		CreatedAt: t0.Add(time.Minute),
+	}
+	if len(res.Hits) != 1 || !reflect.DeepEqual(res.Hits[0], want) {
+		t.Fatalf("hits = %+v，期望 %+v", res.Hits, want)
+	}
+}
+
+func TestMemoryIndexOverwriteAndDelete(t *testing.T) {
+	idx := newTestIndex(t)
+	q := Query{UserId: "U1", GroupIds: []string{"G1"}}
+
+	// 同一 MsgId 再次 Index 覆盖原文档（编辑消息）
+	idx.Index(Document{MsgId: "M1", SendId: "U1", ReceiveId: "U2", Content: "edited text", CreatedAt: t0})
+	q.Keyword = "hello"
+	if res, _ := idx.Search(q); !reflect.DeepEqual(hitIds(res), []string{"M3"}) {
+		t.Errorf("覆盖后 hello = %v，期望 [M3]", hitIds(res))
+	}
+	q.Keyword = "edited"
+	if res, _ := idx.Search(q); !reflect.DeepEqual(hitIds(res), []string{"M1"}) {
+		t.Errorf("覆盖后 edited = %v，期望 [M1]", hitIds(res))
+	}
+
+	// 删除（撤回），不存在的 ID 忽略
+	if err := idx.Delete("M3", "M5", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	q.Keyword = "world"
+	if res, _ := idx.Search(q); !reflect.DeepEqual(hitIds(res), []string{"M2"}) {
+		t.Errorf("删除后 world = %v，期望 [M2]", hitIds(res))
+	}
+}
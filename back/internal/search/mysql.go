@@ -0,0 +1,160 @@
+// ============================================================
+// 文件：back/internal/search/mysql.go
+// 作用：SearchIndex 的 MySQL 实现：message_search 表 + FULLTEXT(ngram) 索引。
+//
+// 查询方式：
+//   MATCH(content) AGAINST('+"关键词1" +"关键词2"' IN BOOLEAN MODE)
+//   每个关键词加引号按短语匹配，多个关键词之间是"且"的关系。
+//   ngram 默认按 2 个字切分，单个字的关键词无法命中全文索引，退化为 LIKE。
+//   用户输入里的布尔运算符（+ - * " 等）会被去掉，避免改变查询语义。
+//
+// 一致性：
+//   结果再 JOIN 一次 message 表，只返回仍然存在且未撤回的消息，
+//   即使索引删除失败，也不会把已撤回的内容搜出来。
+//   调用者"仅对自己删除"的消息和清空之前的记录同样会被排除
+//   （message_user_state / conversation_clear，见 model/message_user_state.go）。
+// ============================================================
+
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mysqlIndex struct {
+	db *gorm.DB
+}
+
+func newMySQLIndex(db *gorm.DB) *mysqlIndex {
+	return &mysqlIndex{db: db}
+}
+
+func (m *mysqlIndex) Index(doc Document) error {
+	row := model.MessageSearch{
+		MsgUuid:   doc.MsgId,
+		SendId:    doc.SendId,
+		ReceiveId: doc.ReceiveId,
+		Type:      doc.Type,
+		Content:   doc.Content,
+		CreatedAt: doc.CreatedAt,
+	}
+	return m.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&row).Error
+}
+
+func (m *mysqlIndex) Delete(msgIds ...string) error {
+	if len(msgIds) == 0 {
+		return nil
+	}
+	return m.db.Where("msg_uuid IN ?", msgIds).Delete(&model.MessageSearch{}).Error
+}
+
+func (m *mysqlIndex) Search(q Query) (*Result, error) {
+	normalizePage(&q)
+	terms := Terms(q.Keyword)
+	if len(terms) == 0 {
+		return &Result{}, nil
+	}
+
+	tx := m.db.Table("message_search AS s").
+		Joins("JOIN message AS msg ON msg.uuid = s.msg_uuid AND msg.is_recalled = 0")
+
+	// 访问范围
+	if len(q.GroupIds) > 0 {
+		tx = tx.Where("(s.send_id = ? OR s.receive_id = ? OR s.receive_id IN ?)", q.UserId, q.UserId, q.GroupIds)
+	} else {
+		tx = tx.Where("(s.send_id = ? OR s.receive_id = ?)", q.UserId, q.UserId)
+	}
+
+	// 排除调用者自己删除 / 清空的消息；会话对象：发给我的取发送者，否则取接收者（对方或群）
+	tx = tx.Where("NOT EXISTS (SELECT 1 FROM message_user_state AS mus WHERE mus.user_id = ? AND mus.msg_uuid = s.msg_uuid)", q.UserId).
+		Where("msg.id > COALESCE((SELECT cc.cleared_msg_id FROM conversation_clear AS cc "+
+			"WHERE cc.user_id = ? AND cc.target_id = IF(msg.receive_id = ?, msg.send_id, msg.receive_id)), 0)",
+			q.UserId, q.UserId)
+
+	// 关键词
+	var phrases []string
+	for _, t := range terms {
+		t = stripBooleanOperators(t)
+		if t == "" {
+			continue
+		}
+		if utf8.RuneCountInString(t) < 2 {
+			tx = tx.Where("s.content LIKE ?", "%"+escapeLike(t)+"%")
+			continue
+		}
+		phrases = append(phrases, `+"`+t+`"`)
+	}
+	if len(phrases) > 0 {
+		tx = tx.Where("MATCH(s.content) AGAINST(? IN BOOLEAN MODE)", strings.Join(phrases, " "))
+	}
+
+	// 过滤条件
+	if q.SenderId != "" {
+		tx = tx.Where("s.send_id = ?", q.SenderId)
+	}
+	if q.ConversationId != "" {
+		tx = tx.Where("((s.send_id = ? AND s.receive_id = ?) OR (s.send_id = ? AND s.receive_id = ?) OR s.receive_id = ?)",
+			q.UserId, q.ConversationId, q.ConversationId, q.UserId, q.ConversationId)
+	}
+	if q.Type != nil {
+		tx = tx.Where("s.type = ?", *q.Type)
+	}
+	if !q.StartTime.IsZero() {
+		tx = tx.Where("s.created_at >= ?", q.StartTime)
+	}
+	if !q.EndTime.IsZero() {
+		tx = tx.Where("s.created_at < ?", q.EndTime)
+	}
+
+	// 之后的 Count 和分页查询共用上面的条件
+	tx = tx.Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []model.MessageSearch
+	if err := tx.Select("s.*").Order("s.created_at DESC").
+		Limit(q.Limit).Offset(q.Offset).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(rows))
+	for _, r := range rows {
+		hits = append(hits, Hit{
+			MsgId:     r.MsgUuid,
+			SendId:    r.SendId,
+			ReceiveId: r.ReceiveId,
+			Type:      r.Type,
+			Content:   r.Content,
+			Snippet:   Highlight(r.Content, terms),
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return &Result{Hits: hits, Total: total}, nil
+}
+
+// stripBooleanOperators 去掉 BOOLEAN MODE 下有特殊含义的字符
+func stripBooleanOperators(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '+', '-', '<', '>', '(', ')', '~', '*', '"', '@':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// escapeLike 转义 LIKE 里的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
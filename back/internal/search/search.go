@@ -0,0 +1,187 @@
+// ============================================================
+// 文件：back/internal/search/search.go
+// 作用：消息全文检索的抽象（SearchIndex）以及全局实例的初始化。
+//
+// 和 MessageBus 的思路一样：接口只描述"建索引 / 删索引 / 查询"三件事，
+// 具体实现通过 config.toml 里的 searchConfig.backend 选择：
+//   · mysql  —— message_search 表 + FULLTEXT(ngram) 索引，生产环境使用（mysql.go）
+//   · memory —— 进程内索引，不依赖数据库，适合本地开发和测试（memory.go）
+//
+// 数据流：
+//   持久化消费者写库成功后调用 IndexMessage；
+//   编辑消息重新索引，撤回 / 清空聊天记录时调用 Remove。
+//   索引只是辅助数据，失败只记日志，不影响消息主链路。
+//
+// 访问控制：
+//   Query 里带上调用者 UserId 和他所在的群 GroupIds，
+//   实现只返回"调用者参与的私聊 + 所在群"的消息。
+// ============================================================
+
+package search
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+// Document 是写入索引的一条消息
+type Document struct {
+	MsgId     string
+	SendId    string
+	ReceiveId string
+	Type      int8
+	Content   string // 文本消息为正文，文件消息为文件名
+	CreatedAt time.Time
+}
+
+// Query 描述一次搜索
+type Query struct {
+	Keyword string
+
+	// 访问范围：调用者本人参与的私聊 + 所在的群
+	UserId   string
+	GroupIds []string
+
+	// 可选过滤条件
+	SenderId       string    // 发送者
+	ConversationId string    // 会话：对方用户 uuid 或群 uuid
+	Type           *int8     // 消息类型
+	StartTime      time.Time // 零值表示不限
+	EndTime        time.Time // 零值表示不限
+
+	Limit  int
+	Offset int
+}
+
+// Hit 是一条搜索结果
+type Hit struct {
+	MsgId     string    `json:"msgId"`
+	SendId    string    `json:"sendId"`
+	ReceiveId string    `json:"receiveId"`
+	Type      int8      `json:"type"`
+	Content   string    `json:"content"`
+	Snippet   string    `json:"snippet"` // 命中片段，关键词用 <em></em> 包裹，其余部分已做 HTML 转义
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Result 是搜索结果的一页
+type Result struct {
+	Hits  []Hit `json:"hits"`
+	Total int64 `json:"total"`
+}
+
+// SearchIndex 是消息检索依赖的最小能力集合。
+type SearchIndex interface {
+	// Index 写入或覆盖一条消息的索引。
+	Index(doc Document) error
+	// Delete 删除消息索引，不存在的 ID 忽略。
+	Delete(msgIds ...string) error
+	// Search 按条件查询，结果按发送时间倒序。
+	Search(q Query) (*Result, error)
+}
+
+// 检索后端，对应 searchConfig.backend。
+const (
+	BackendMySQL  = "mysql"
+	BackendMemory = "memory"
+)
+
+// Default 是全局检索实例，由 Init 在启动时创建；未初始化时 IndexMessage / Remove 不做任何事。
+var Default SearchIndex
+
+// Init 根据配置创建检索实例。backend 留空时使用 mysql。
+func Init(cfg config.SearchConfig) error {
+	backend := strings.ToLower(strings.TrimSpace(cfg.Backend))
+	switch backend {
+	case BackendMySQL, "":
+		backend = BackendMySQL
+		Default = newMySQLIndex(config.GetDB())
+	case BackendMemory:
+		Default = newMemoryIndex()
+	default:
+		return fmt.Errorf("未知的 search backend: %q（可选 mysql / memory）", cfg.Backend)
+	}
+	slog.Info("search_index_ready", "backend", backend)
+	return nil
+}
+
+// DocumentFromMessage 把消息转换成索引文档；不需要搜索的消息返回 false。
+func DocumentFromMessage(m *model.Message) (Document, bool) {
+	var text string
+	switch m.Type {
+	case 0:
+		text = m.Content
+	case 1:
+		text = m.FileName
+	}
+	if m.IsRecalled == 1 || strings.TrimSpace(text) == "" {
+		return Document{}, false
+	}
+	return Document{
+		MsgId:     m.Uuid,
+		SendId:    m.SendId,
+		ReceiveId: m.ReceiveId,
+		Type:      m.Type,
+		Content:   text,
+		CreatedAt: m.CreatedAt,
+	}, true
+}
+
+// IndexMessage 为消息建立索引，失败只记日志。
+func IndexMessage(m *model.Message) {
+	if Default == nil {
+		return
+	}
+	doc, ok := DocumentFromMessage(m)
+	if !ok {
+		return
+	}
+	if err := Default.Index(doc); err != nil {
+		slog.Warn("search_index_failed", "msg_id", m.Uuid, "err", err)
+	}
+}
+
+// Remove 删除消息索引，失败只记日志。
+func Remove(msgIds ...string) {
+	if Default == nil || len(msgIds) == 0 {
+		return
+	}
+	if err := Default.Delete(msgIds...); err != nil {
+		slog.Warn("search_delete_failed", "count", len(msgIds), "err", err)
+	}
+}
+
+// maxTerms 限制一次搜索的关键词个数
+const maxTerms = 5
+
+// Terms 把用户输入按空白切分成关键词，去重并限制个数。
+func Terms(keyword string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range strings.Fields(keyword) {
+		k := strings.ToLower(t)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		terms = append(terms, t)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func normalizePage(q *Query) {
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+}
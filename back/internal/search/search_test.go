@@ -0,0 +1,63 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"chatapp/back/internal/model"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"hello", []string{"hello"}},
+		{"  hello \t world\n", []string{"hello", "world"}},
+		{"Go go GO rust", []string{"Go", "rust"}},
+		{"a b c d e f g", []string{"a", "b", "c", "d", "e"}},
+		{"a a b b c d e f", []string{"a", "b", "c", "d", "e"}},
+		{"中文 搜索", []string{"中文", "搜索"}},
+	}
+	for _, tt := range tests {
+		if got := Terms(tt.keyword); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q，期望 %q", tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentFromMessage(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		msg     model.Message
+		content string
+		ok      bool
+	}{
+		{"文本消息取正文", model.Message{Type: 0, Content: "hi", FileName: "x"}, "hi", true},
+		{"文件消息取文件名", model.Message{Type: 1, Content: "", FileName: "报告.pdf"}, "报告.pdf", true},
+		{"空白正文不索引", model.Message{Type: 0, Content: "  "}, "", false},
+		{"已撤回不索引", model.Message{Type: 0, Content: "hi", IsRecalled: 1}, "", false},
+		{"通话消息不索引", model.Message{Type: 2, Content: "通话"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.msg
+			m.Uuid, m.SendId, m.ReceiveId, m.CreatedAt = "M1", "U1", "U2", at
+			doc, ok := DocumentFromMessage(&m)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v，期望 %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			want := Document{MsgId: "M1", SendId: "U1", ReceiveId: "U2", Type: m.Type, Content: tt.content, CreatedAt: at}
+			if doc != want {
+				t.Errorf("doc = %+v，期望 %+v", doc, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// ============================================================
+// 文件：back/internal/search/snippet.go
+// 作用：生成搜索结果的高亮片段。
+//
+// 做法：
+//   找到第一个命中的关键词，前后各截取 snippetContext 个字符，
+//   片段内所有关键词（不区分大小写）用 <em></em> 包裹。
+//   其余文本先做 HTML 转义，前端可以直接用 v-html 渲染，不会被注入脚本。
+// ============================================================
+
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const snippetContext = 30
+
+// Highlight 生成带高亮的片段
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// 极少数字符转小写后字符数会变，位置对不上，这时退化为区分大小写匹配
+		lower = runes
+	}
+
+	first := -1
+	for _, t := range terms {
+		if i := indexRunes(lower, []rune(strings.ToLower(t))); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = max(0, first-snippetContext)
+		end = min(len(runes), first+snippetContext*2)
+	} else if end > snippetContext*2 {
+		end = snippetContext * 2
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	i := start
+	for i < end {
+		matched := 0
+		for _, t := range terms {
+			tr := []rune(strings.ToLower(t))
+			if n := len(tr); n > matched && i+n <= len(lower) && string(lower[i:i+n]) == string(tr) {
+				matched = n
+			}
+		}
+		if matched > 0 {
+			b.WriteString("<em>")
+			b.WriteString(html.EscapeString(string(runes[i : i+matched])))
+			b.WriteString("</em>")
+			i += matched
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if i < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// indexRunes 返回 sub 在 s 中第一次出现的位置（按字符计），找不到返回 -1。
+func indexRunes(s, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	i := strings.Index(string(s), string(sub))
+	if i < 0 {
+		return -1
+	}
+	return utf8.RuneCountInString(string(s)[:i])
+}
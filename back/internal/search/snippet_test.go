@@ -0,0 +1,37 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a", 100) + "key" + strings.Repeat("b", 100)
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"单个关键词", "hello world", []string{"world"}, "hello <em>world</em>"},
+		{"不区分大小写，保留原文大小写", "Hello World", []string{"hello"}, "<em>Hello</em> World"},
+		{"多个关键词", "hello big world", []string{"world", "hello"}, "<em>hello</em> big <em>world</em>"},
+		{"同一位置优先匹配更长的关键词", "golang go", []string{"go", "golang"}, "<em>golang</em> <em>go</em>"},
+		{"重复出现都高亮", "go go", []string{"go"}, "<em>go</em> <em>go</em>"},
+		{"HTML 转义", `<b>hi</b> & "x"`, []string{"hi"}, "&lt;b&gt;<em>hi</em>&lt;/b&gt; &amp; &#34;x&#34;"},
+		{"关键词本身转义", "a<b", []string{"<"}, "a<em>&lt;</em>b"},
+		{"中文", "今天天气不错，一起去公园吧", []string{"公园"}, "今天天气不错，一起去<em>公园</em>吧"},
+		{"长文本围绕命中位置截取", long, []string{"key"},
+			"…" + strings.Repeat("a", 30) + "<em>key</em>" + strings.Repeat("b", 57) + "…"},
+		{"没有命中时取开头", strings.Repeat("x", 70), []string{"key"}, strings.Repeat("x", 60) + "…"},
+		{"没有关键词", "short & sweet", nil, "short &amp; sweet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.terms); got != tt.want {
+				t.Errorf("Highlight(%q, %q)\n got  %q\n want %q", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
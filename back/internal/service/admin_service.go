@@ -17,6 +17,8 @@ import (
 	"chatapp/back/internal/model"
 	"errors"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 func GetAllUsers() ([]model.UserInfo, error) {
@@ -57,10 +59,15 @@ func AdminDismissGroup(groupId string) error {
 	if err := db.Where("uuid = ?", groupId).First(&group).Error; err != nil {
 		return errors.New("群聊不存在")
 	}
-	group.Status = 2 // 解散
-	group.Members = []byte("[]")
-	group.MemberCnt = 0
-	return db.Save(&group).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if _, err := clearGroupMembers(tx, groupId); err != nil {
+			return err
+		}
+		return tx.Model(&group).Updates(map[string]interface{}{
+			"status":     2, // 解散
+			"member_cnt": 0,
+		}).Error
+	})
 }
 
 // SystemStats 是 /admin/stats 的返回结构，字段含义见各注释。
@@ -7,8 +7,8 @@
 //   contact_apply_service.go 处理"入群申请"（用户申请加入某个群）
 //
 // 群聊审核模式（AddMode = 1）：
-//   群主或管理员在这里审核待加入的申请。
-//   通过后，被申请者的 UUID 会被 append 进群的 members JSON 数组，
+//   拥有 approve_join 权限的成员（群主，以及配置允许的管理员）在这里审核待加入的申请。
+//   通过后，申请者会被写入 group_member 表（见 group_member_service.go），
 //   同时在 user_contact 表创建记录。
 // ============================================================
 package service
@@ -16,11 +16,11 @@ package service
 import (
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/model"
-	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // 新建一条加群申请
@@ -40,8 +40,11 @@ func CreateGroupApply(userId, groupId, message string) error {
 	return db.Create(&apply).Error
 }
 
-// 查询某个群聊的待审核申请
-func GetGroupApplyList(groupId string) ([]model.ContactApply, error) {
+// 查询某个群聊的待审核申请（需要 approve_join 权限）
+func GetGroupApplyList(operatorId, groupId string) ([]model.ContactApply, error) {
+	if _, err := CheckGroupPermission(operatorId, groupId, PermApproveJoin); err != nil {
+		return nil, err
+	}
 	db := config.GetDB()
 	var list []model.ContactApply
 	if err := db.Where("contact_id = ? AND status = 0", groupId).Find(&list).Error; err != nil {
@@ -50,50 +53,40 @@ func GetGroupApplyList(groupId string) ([]model.ContactApply, error) {
 	return list, nil
 }
 
-// 审核加群申请（通过/拒绝）
-func HandleGroupApply(applyUuid string, approve bool) error {
+// 审核加群申请（通过/拒绝），operatorId 需要拥有该群的 approve_join 权限
+func HandleGroupApply(operatorId, applyUuid string, approve bool) error {
 	db := config.GetDB()
 
 	var apply model.ContactApply
-	if err := db.Where("uuid = ?", applyUuid).First(&apply).Error; err != nil {
+	if err := db.Where("uuid = ? AND contact_type = 1", applyUuid).First(&apply).Error; err != nil {
 		return errors.New("申请不存在")
 	}
+	if apply.Status != 0 {
+		return errors.New("申请已处理")
+	}
+	if _, err := CheckGroupPermission(operatorId, apply.ContactId, PermApproveJoin); err != nil {
+		return err
+	}
 
-	// 如果是通过，更新群聊成员
+	// 如果是通过，加入群成员表；成员写入和申请状态更新放在同一事务里
 	if approve {
 		var group model.GroupInfo
 		if err := db.Where("uuid = ?", apply.ContactId).First(&group).Error; err != nil {
 			return errors.New("群聊不存在")
 		}
 
-		// 解析已有成员
-		var members []string
-		if len(group.Members) > 0 {
-			_ = json.Unmarshal(group.Members, &members)
-		}
-
-		// 检查是否已经在群里
-		for _, m := range members {
-			if m == apply.UserId {
-				return errors.New("用户已在群聊中")
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := addGroupMember(tx, group.Uuid, apply.UserId, model.GroupRoleMember, operatorId); err != nil {
+				if errors.Is(err, errAlreadyInGroup) {
+					return errors.New("用户已在群聊中")
+				}
+				return errors.New("更新群聊失败")
 			}
-		}
-
-		// 添加新成员
-		members = append(members, apply.UserId)
-		newMembers, _ := json.Marshal(members)
-
-		group.Members = newMembers
-		group.MemberCnt = len(members)
-
-		if err := db.Save(&group).Error; err != nil {
-			return errors.New("更新群聊失败")
-		}
-
-		apply.Status = 1 // 通过
-	} else {
-		apply.Status = 2 // 拒绝
+			apply.Status = 1 // 通过
+			return tx.Save(&apply).Error
+		})
 	}
 
+	apply.Status = 2 // 拒绝
 	return db.Save(&apply).Error
 }
@@ -16,9 +16,9 @@
 //   双向记录保证双方都能在通讯录里看到对方。
 //
 // GetMyJoinedGroups 的查询技巧：
-//   使用 MySQL 的 JSON_CONTAINS 函数直接在 SQL 层查询 JSON 数组字段，
-//   比"加载所有群 → 循环判断"的应用层过滤更高效。
-//   SQL 示例：WHERE JSON_CONTAINS(members, '"userId123"') AND status = 0
+//   group_member 表在 user_id 上有索引，先按成员表找出群ID再查群信息，
+//   不需要像旧版 JSON_CONTAINS(members, ...) 那样扫描整张 group_info。
+//   SQL 示例：WHERE uuid IN (SELECT group_id FROM group_member WHERE user_id = ?) AND status = 0
 // ============================================================
 package service
 
@@ -27,7 +27,6 @@ import (
 	"chatapp/back/internal/dto/req"
 	"chatapp/back/internal/model"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
@@ -171,6 +170,7 @@ func HandleContactApply(userId string, form *req.HandleContactApplyRequest) (str
 		if err != nil {
 			return "", err
 		}
+		invalidatePresenceAudience(userId, apply.UserId)
 		return apply.UserId, nil
 	} else {
 		apply.Status = 2
@@ -193,6 +193,18 @@ func GetContactList(userId string) ([]ContactDetail, error) {
 	return list, err
 }
 
+// IsContact 判断 targetId 是否是 userId 的正常好友（status=0；已拉黑、已删除的都不算）。
+func IsContact(userId, targetId string) bool {
+	db := config.GetDB()
+	var cnt int64
+	if err := db.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND contact_type = 0 AND status = 0", userId, targetId).
+		Count(&cnt).Error; err != nil {
+		return false
+	}
+	return cnt > 0
+}
+
 func DeleteContact(userId, targetUserId string) error {
 	db := config.GetDB()
 	if err := db.Where("user_id = ? AND contact_id = ?", userId, targetUserId).Delete(&model.UserContact{}).Error; err != nil {
@@ -201,21 +213,30 @@ func DeleteContact(userId, targetUserId string) error {
 	if err := db.Where("user_id = ? AND contact_id = ?", targetUserId, userId).Delete(&model.UserContact{}).Error; err != nil {
 		return err
 	}
+	invalidatePresenceAudience(userId, targetUserId)
 	return nil
 }
 
 func BlackContact(userId, targetUserId string) error {
 	db := config.GetDB()
-	return db.Model(&model.UserContact{}).
+	if err := db.Model(&model.UserContact{}).
 		Where("user_id = ? AND contact_id = ?", userId, targetUserId).
-		Update("status", 1).Error
+		Update("status", 1).Error; err != nil {
+		return err
+	}
+	invalidatePresenceAudience(userId, targetUserId)
+	return nil
 }
 
 func UnBlackContact(userId, targetUserId string) error {
 	db := config.GetDB()
-	return db.Model(&model.UserContact{}).
+	if err := db.Model(&model.UserContact{}).
 		Where("user_id = ? AND contact_id = ?", userId, targetUserId).
-		Update("status", 0).Error
+		Update("status", 0).Error; err != nil {
+		return err
+	}
+	invalidatePresenceAudience(userId, targetUserId)
+	return nil
 }
 
 func RefuseContactApply(userId, applyUuid string) error {
@@ -247,8 +268,9 @@ func BlackApply(userId, applyUuid string) error {
 func GetMyJoinedGroups(userId string) ([]model.GroupInfo, error) {
 	db := config.GetDB()
 	var groups []model.GroupInfo
-	// JSON_CONTAINS 直接在数据库层过滤，避免加载全表
-	err := db.Where("JSON_CONTAINS(members, ?) AND status = 0", fmt.Sprintf(`"%s"`, userId)).
+	// 子查询走 group_member 的 user_id 索引，避免加载全表
+	sub := db.Model(&model.GroupMember{}).Select("group_id").Where("user_id = ?", userId)
+	err := db.Where("uuid IN (?) AND status = 0", sub).
 		Find(&groups).Error
 	return groups, err
 }
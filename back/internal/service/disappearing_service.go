@@ -0,0 +1,121 @@
+// ============================================================
+// 文件：back/internal/service/disappearing_service.go
+// 作用：会话"消息自动删除"计时的查询与修改。
+//
+// 权限：
+//   私聊：双方都必须是好友，任意一方都可以修改
+//   群聊：需要 edit_info 权限（默认群主和管理员）
+//
+// 计时变更后的系统提示消息、到期消息的清理都在 chat/disappearing.go，
+// 这里只维护 disappearing_timer 表。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// DisappearingTTLs 是允许设置的计时（秒）：关闭、1小时、1天、7天
+var DisappearingTTLs = []int64{0, 3600, 86400, 7 * 86400}
+
+// GetDisappearingTimer 查询会话当前的计时，未设置过时返回关闭状态。
+func GetDisappearingTimer(userId, targetId string) (*model.DisappearingTimer, error) {
+	if err := checkConversationAccess(userId, targetId); err != nil {
+		return nil, err
+	}
+
+	var t model.DisappearingTimer
+	err := config.GetDB().
+		Where("conversation_key = ?", conversationKey(userId, targetId)).
+		Limit(1).
+		Find(&t).Error
+	if err != nil {
+		return nil, errors.New("查询失败")
+	}
+	return &t, nil
+}
+
+// SetDisappearingTimer 修改会话的计时，只影响之后发出的消息。
+// 返回 changed=false 表示计时没有变化，调用方不需要再发提示消息。
+func SetDisappearingTimer(userId, targetId string, ttl int64) (bool, error) {
+	if !validDisappearingTTL(ttl) {
+		return false, errors.New("不支持的自动删除时间")
+	}
+	if isGroupId(targetId) {
+		if _, err := CheckGroupPermission(userId, targetId, PermEditInfo); err != nil {
+			return false, err
+		}
+	} else if err := checkConversationAccess(userId, targetId); err != nil {
+		return false, err
+	}
+
+	db := config.GetDB()
+	key := conversationKey(userId, targetId)
+
+	var ttls []int64
+	db.Model(&model.DisappearingTimer{}).Where("conversation_key = ?", key).Limit(1).Pluck("ttl_seconds", &ttls)
+	current := int64(0)
+	if len(ttls) > 0 {
+		current = ttls[0]
+	}
+	if current == ttl {
+		return false, nil
+	}
+
+	row := model.DisappearingTimer{
+		ConversationKey: key,
+		TtlSeconds:      ttl,
+		UpdatedBy:       userId,
+		UpdatedAt:       time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ttl_seconds", "updated_by", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		return false, errors.New("设置失败")
+	}
+	return true, nil
+}
+
+func validDisappearingTTL(ttl int64) bool {
+	for _, v := range DisappearingTTLs {
+		if v == ttl {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConversationAccess 校验 userId 能否操作与 targetId 的会话：群聊要求是成员，私聊要求是好友
+func checkConversationAccess(userId, targetId string) error {
+	if targetId == "" || targetId == userId {
+		return errors.New("参数错误")
+	}
+	if isGroupId(targetId) {
+		if !IsGroupMember(userId, targetId) {
+			return errNotGroupMember
+		}
+		return nil
+	}
+	if !IsContact(userId, targetId) {
+		return errors.New("对方不是你的好友")
+	}
+	return nil
+}
+
+// conversationKey 与 chat 包 buildSessionId 的规则一致：群聊 "G:"+群ID，私聊两个用户ID排序后拼接
+func conversationKey(userId, targetId string) string {
+	if isGroupId(targetId) {
+		return "G:" + targetId
+	}
+	if userId < targetId {
+		return userId + ":" + targetId
+	}
+	return targetId + ":" + userId
+}
@@ -0,0 +1,345 @@
+// ============================================================
+// 文件：back/internal/service/export_service.go
+// 作用：聊天记录导出任务：创建任务、后台执行、查询进度、下载、过期清理。
+//
+// 流程：
+//   1. CreateExportJob 校验权限（群成员 IsGroupMember / 通讯录关系 IsContact），写入 export_job
+//   2. 控制器起一个 goroutine 调 RunExportJob，按 id 升序分批读出消息交给 exportWriter，
+//      进度每推进 exportReportStep 个百分点回调一次（控制器据此推 WebSocket）；
+//      和聊天记录列表一样，自己"仅对自己删除"的消息和清空会话之前的消息不导出（visibleTo）
+//   3. 完成后设置 expires_at，StartExportJanitor 定期删除过期文件
+//
+// 并发控制：
+//   每个用户同时只能有一个进行中的导出：export_job.active_user 唯一索引保证，并发创建时只有一个能插入；
+//   每个实例最多 exportMaxRunning 个任务同时执行，其余排队等待，避免大量导出把数据库读满。
+//
+// 服务重启 / 多实例：
+//   任务在创建它的实例的进程内执行，执行期间（包括排队）每 exportLease/2 续一次租约（lease_until）。
+//   实例退出后租约不再续期，任意实例的 StartExportJanitor 发现租约过期就把任务标记为失败，
+//   用户重新发起即可；别的实例上正在执行的任务不受影响。
+//
+// 文件存储：
+//   导出文件写在本地 exportConfig.exportPath。多实例部署时这个目录必须是所有实例共享的存储
+//   （NFS、共享卷等），否则下载请求落到别的实例上会找不到文件。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	exportBatchSize  = 500
+	exportMaxRunning = 2
+	exportReportStep = 5
+	exportLease      = 2 * time.Minute
+)
+
+// exportSlots 限制同时执行的导出任务数
+var exportSlots = make(chan struct{}, exportMaxRunning)
+
+// CreateExportJob 创建导出任务（只登记，不执行）
+func CreateExportJob(userId, targetId string) (*model.ExportJob, error) {
+	if targetId == "" {
+		return nil, errors.New("参数错误")
+	}
+	if _, ok := exportConversation(userId, targetId); !ok {
+		return nil, errors.New("无权限导出该会话")
+	}
+
+	db := config.GetDB()
+	now := time.Now()
+	lease := now.Add(exportLease)
+	job := model.ExportJob{
+		Uuid:       "E" + strings.ReplaceAll(uuid.NewString(), "-", "")[:19],
+		UserId:     userId,
+		TargetId:   targetId,
+		Status:     model.ExportStatusPending,
+		CreatedAt:  now,
+		ActiveUser: &userId,
+		LeaseUntil: &lease,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		// active_user 唯一索引冲突 = 已经有进行中的任务
+		var active int64
+		db.Model(&model.ExportJob{}).Where("active_user = ?", userId).Count(&active)
+		if active > 0 {
+			return nil, errors.New("已有正在进行的导出任务，请稍后再试")
+		}
+		return nil, errors.New("创建导出任务失败")
+	}
+	return &job, nil
+}
+
+// exportConversation 校验访问权限并返回会话信息
+func exportConversation(userId, targetId string) (exportMeta, bool) {
+	db := config.GetDB()
+	meta := exportMeta{ConversationId: targetId, ExportedBy: userId}
+
+	var group model.GroupInfo
+	if err := db.Where("uuid = ?", targetId).First(&group).Error; err == nil {
+		meta.IsGroup = true
+		meta.ConversationName = group.Name
+		return meta, IsGroupMember(userId, targetId)
+	}
+
+	if !IsContact(userId, targetId) {
+		return meta, false
+	}
+	var u model.UserInfo
+	if err := db.Where("uuid = ?", targetId).First(&u).Error; err == nil {
+		meta.ConversationName = u.Nickname
+	}
+	return meta, true
+}
+
+// RunExportJob 执行导出任务，阻塞直到完成。report 在进度变化和结束时被调用。
+func RunExportJob(jobId string, report func(job *model.ExportJob)) {
+	db := config.GetDB()
+	var job model.ExportJob
+	if err := db.Where("uuid = ?", jobId).First(&job).Error; err != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go keepExportLease(job.Uuid, stop)
+
+	exportSlots <- struct{}{}
+	defer func() { <-exportSlots }()
+
+	job.Status = model.ExportStatusRunning
+	db.Model(&job).Update("status", job.Status)
+	report(&job)
+
+	if err := runExport(&job, report); err != nil {
+		slog.Error("export_failed", "job_id", job.Uuid, "user_id", job.UserId, "err", err)
+		job.Status = model.ExportStatusFailed
+		job.ErrorMsg = "导出失败，请重试"
+		db.Model(&job).Updates(map[string]interface{}{"status": job.Status, "error_msg": job.ErrorMsg, "active_user": nil})
+		report(&job)
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(exportTTL())
+	job.Status = model.ExportStatusDone
+	job.Progress = 100
+	job.FinishedAt = &now
+	job.ExpiresAt = &expires
+	db.Model(&job).Updates(map[string]interface{}{
+		"status":        job.Status,
+		"progress":      job.Progress,
+		"message_count": job.MessageCount,
+		"file_path":     job.FilePath,
+		"finished_at":   now,
+		"expires_at":    expires,
+		"active_user":   nil,
+	})
+	slog.Info("export_done", "job_id", job.Uuid, "user_id", job.UserId, "messages", job.MessageCount)
+	report(&job)
+}
+
+// keepExportLease 在任务排队和执行期间定期续租约，直到 stop 关闭
+func keepExportLease(jobId string, stop <-chan struct{}) {
+	ticker := time.NewTicker(exportLease / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			config.GetDB().Model(&model.ExportJob{}).
+				Where("uuid = ? AND status IN ?", jobId, []int8{model.ExportStatusPending, model.ExportStatusRunning}).
+				Update("lease_until", time.Now().Add(exportLease))
+		}
+	}
+}
+
+func runExport(job *model.ExportJob, report func(job *model.ExportJob)) error {
+	meta, ok := exportConversation(job.UserId, job.TargetId)
+	if !ok {
+		return errors.New("no permission")
+	}
+	meta.ExportedAt = time.Now()
+
+	dir := config.GetConfig().ExportPath
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(dir, "job_"+job.Uuid+"_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	w, err := newExportWriter(tmpDir, meta)
+	if err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+	cond := db.Model(&model.Message{})
+	if meta.IsGroup {
+		cond = cond.Where("receive_id = ?", job.TargetId)
+	} else {
+		cond = cond.Where("((send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?))",
+			job.UserId, job.TargetId, job.TargetId, job.UserId)
+	}
+	cond = visibleTo(cond, job.UserId, job.TargetId)
+	var total int64
+	cond.Session(&gorm.Session{}).Count(&total)
+
+	var lastId int64
+	reported := 0
+	for {
+		var batch []model.Message
+		if err := cond.Session(&gorm.Session{}).
+			Where("id > ?", lastId).Order("id ASC").Limit(exportBatchSize).
+			Find(&batch).Error; err != nil {
+			w.closeFiles()
+			return err
+		}
+		for i := range batch {
+			if err := w.write(&batch[i]); err != nil {
+				w.closeFiles()
+				return err
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+		lastId = batch[len(batch)-1].Id
+		job.MessageCount = w.count
+
+		// 遍历消息占 0~90%，打包占最后 10%
+		if total > 0 {
+			p := int(int64(w.count) * 90 / total)
+			if p-reported >= exportReportStep {
+				reported = p
+				job.Progress = p
+				db.Model(job).Updates(map[string]interface{}{"progress": p, "message_count": job.MessageCount})
+				report(job)
+			}
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+	}
+
+	if err := w.finish(); err != nil {
+		return err
+	}
+	job.Progress = 90
+	report(job)
+
+	dst := filepath.Join(dir, fmt.Sprintf("export_%s.zip", job.Uuid))
+	if err := w.zipTo(dst); err != nil {
+		return err
+	}
+	job.FilePath = dst
+	return nil
+}
+
+// GetExportJob 查询自己的导出任务
+func GetExportJob(userId, jobId string) (*model.ExportJob, error) {
+	var job model.ExportJob
+	if err := config.GetDB().Where("uuid = ? AND user_id = ?", jobId, userId).First(&job).Error; err != nil {
+		return nil, errors.New("导出任务不存在")
+	}
+	return &job, nil
+}
+
+// GetExportFile 返回可下载的导出文件路径和建议的下载文件名
+func GetExportFile(userId, jobId string) (string, string, error) {
+	job, err := GetExportJob(userId, jobId)
+	if err != nil {
+		return "", "", err
+	}
+	switch job.Status {
+	case model.ExportStatusDone:
+	case model.ExportStatusExpired:
+		return "", "", errors.New("导出文件已过期，请重新导出")
+	default:
+		return "", "", errors.New("导出尚未完成")
+	}
+	if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+		return "", "", errors.New("导出文件已过期，请重新导出")
+	}
+	if _, err := os.Stat(job.FilePath); err != nil {
+		return "", "", errors.New("导出文件不存在，请重新导出")
+	}
+	name := fmt.Sprintf("chat_export_%s.zip", job.FinishedAt.Format("20060102_150405"))
+	return job.FilePath, name, nil
+}
+
+func exportTTL() time.Duration {
+	h := config.GetConfig().ExpireHours
+	if h <= 0 {
+		h = 24
+	}
+	return time.Duration(h) * time.Hour
+}
+
+// StartExportJanitor 启动清理任务：租约过期的任务标记为失败，过期的导出文件删除。
+func StartExportJanitor() {
+	go func() {
+		failAbandonedExports()
+		cleanExpiredExports()
+		ticker := time.NewTicker(exportLease)
+		defer ticker.Stop()
+		for range ticker.C {
+			failAbandonedExports()
+			cleanExpiredExports()
+		}
+	}()
+}
+
+// failAbandonedExports 把执行实例已经退出（租约过期）的任务标记为失败。
+// 租约为空的是加租约之前创建的旧任务，同样按中断处理。
+func failAbandonedExports() {
+	res := config.GetDB().Model(&model.ExportJob{}).
+		Where("status IN ? AND (lease_until IS NULL OR lease_until < ?)",
+			[]int8{model.ExportStatusPending, model.ExportStatusRunning}, time.Now()).
+		Updates(map[string]interface{}{
+			"status":      model.ExportStatusFailed,
+			"error_msg":   "服务重启，导出已中断",
+			"active_user": nil,
+		})
+	if res.Error == nil && res.RowsAffected > 0 {
+		slog.Info("export_abandoned", "count", res.RowsAffected)
+	}
+}
+
+func cleanExpiredExports() {
+	db := config.GetDB()
+	var jobs []model.ExportJob
+	if err := db.Where("status = ? AND expires_at < ?", model.ExportStatusDone, time.Now()).
+		Find(&jobs).Error; err != nil {
+		return
+	}
+	for _, job := range jobs {
+		if job.FilePath != "" {
+			if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+				slog.Warn("export_cleanup_failed", "job_id", job.Uuid, "err", err)
+				continue
+			}
+		}
+		db.Model(&job).Update("status", model.ExportStatusExpired)
+	}
+	if len(jobs) > 0 {
+		slog.Info("export_cleanup", "expired", len(jobs))
+	}
+}
@@ -0,0 +1,312 @@
+// ============================================================
+// 文件：back/internal/service/export_writer.go
+// 作用：把一个会话的消息写成 JSON / 纯文本 / HTML 三份记录，再连同附件打包成 zip。
+//
+// 为什么先写临时文件再打包？
+//   zip.Writer 同一时间只能写一个条目，而三份记录需要在"一次遍历消息"的过程中同时写。
+//   所以遍历时分别写进三个临时文件（带缓冲，内存占用固定），遍历结束后再依次拷进 zip。
+//   消息再多也不会整体加载到内存里。
+//
+// zip 内的目录结构：
+//   transcript.json  —— 机器可读，字段和 model.Message 对应
+//   transcript.txt   —— 纯文本，一行一条消息
+//   transcript.html  —— 样式内联的单文件页面，图片和附件用相对路径引用 files/ 目录
+//   files/           —— 消息里引用的附件（/static/files 下仍然存在的才会打包）
+// ============================================================
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+// exportMeta 是导出文件头部的会话信息
+type exportMeta struct {
+	ConversationId   string    `json:"conversationId"`
+	ConversationName string    `json:"conversationName"`
+	IsGroup          bool      `json:"isGroup"`
+	ExportedBy       string    `json:"exportedBy"`
+	ExportedAt       time.Time `json:"exportedAt"`
+}
+
+// exportedFile 是 JSON 记录里的附件信息
+type exportedFile struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Path    string `json:"path,omitempty"` // zip 内的相对路径，附件已过期时为空
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// exportedMessage 是 JSON 记录里的一条消息
+type exportedMessage struct {
+	Id         string        `json:"id"`
+	Type       int8          `json:"type"`
+	SendId     string        `json:"sendId"`
+	SendName   string        `json:"sendName"`
+	Content    string        `json:"content,omitempty"`
+	File       *exportedFile `json:"file,omitempty"`
+	ReplyTo    string        `json:"replyTo,omitempty"`
+	IsRecalled bool          `json:"isRecalled,omitempty"`
+	EditedAt   *time.Time    `json:"editedAt,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+
+	Record *model.ForwardRecord `json:"record,omitempty"` // 合并转发的聊天记录
+}
+
+type exportWriter struct {
+	dir   string
+	files []*os.File
+	json  *bufio.Writer
+	txt   *bufio.Writer
+	html  *bufio.Writer
+	count int
+
+	attachments map[string]string // zip 内路径 -> 本地路径
+}
+
+const (
+	exportJSONName = "transcript.json"
+	exportTXTName  = "transcript.txt"
+	exportHTMLName = "transcript.html"
+)
+
+func newExportWriter(dir string, meta exportMeta) (*exportWriter, error) {
+	w := &exportWriter{dir: dir, attachments: make(map[string]string)}
+	open := func(name string) (*bufio.Writer, error) {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		w.files = append(w.files, f)
+		return bufio.NewWriter(f), nil
+	}
+
+	var err error
+	if w.json, err = open(exportJSONName); err != nil {
+		w.closeFiles()
+		return nil, err
+	}
+	if w.txt, err = open(exportTXTName); err != nil {
+		w.closeFiles()
+		return nil, err
+	}
+	if w.html, err = open(exportHTMLName); err != nil {
+		w.closeFiles()
+		return nil, err
+	}
+
+	metaRaw, _ := json.Marshal(meta)
+	fmt.Fprintf(w.json, "{\"conversation\":%s,\"messages\":[", metaRaw)
+
+	fmt.Fprintf(w.txt, "会话：%s\n导出时间：%s\n\n", meta.ConversationName, meta.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	title := html.EscapeString(meta.ConversationName)
+	fmt.Fprintf(w.html, exportHTMLHead, title, title, meta.ExportedAt.Format("2006-01-02 15:04:05"))
+	return w, nil
+}
+
+// write 追加一条消息到三份记录
+func (w *exportWriter) write(m *model.Message) error {
+	em := exportedMessage{
+		Id:         m.Uuid,
+		Type:       m.Type,
+		SendId:     m.SendId,
+		SendName:   m.SendName,
+		Content:    m.Content,
+		ReplyTo:    m.ReplyTo,
+		IsRecalled: m.IsRecalled == 1,
+		EditedAt:   m.EditedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+	if m.Type == 3 && m.IsRecalled == 0 {
+		em.Record = m.Record
+	}
+	if m.Type == 1 && m.IsRecalled == 0 {
+		em.File = w.attach(m)
+	}
+
+	raw, err := json.Marshal(em)
+	if err != nil {
+		return err
+	}
+	if w.count > 0 {
+		w.json.WriteByte(',')
+	}
+	w.json.WriteString("\n")
+	w.json.Write(raw)
+
+	ts := m.CreatedAt.Format("2006-01-02 15:04:05")
+	text := exportText(m, em.File)
+	fmt.Fprintf(w.txt, "[%s] %s: %s\n", ts, m.SendName, text)
+
+	fmt.Fprintf(w.html, `<div class="msg"><div class="meta"><b>%s</b> <span>%s</span></div><div class="body">%s</div></div>`+"\n",
+		html.EscapeString(m.SendName), ts, exportHTMLBody(m, em.File, text))
+
+	// bufio 的写错误会保留到 Flush 时返回，这里不逐个检查
+	w.count++
+	return nil
+}
+
+// attach 登记消息引用的附件，返回 JSON 里的附件信息
+func (w *exportWriter) attach(m *model.Message) *exportedFile {
+	f := &exportedFile{Name: m.FileName, Type: m.FileType, Size: m.FileSize}
+	if !strings.HasPrefix(m.Url, "/static/files/") {
+		f.Missing = true
+		return f
+	}
+	base := filepath.Base(m.Url)
+	local := filepath.Join(config.GetConfig().StaticFilePath, base)
+	if st, err := os.Stat(local); err != nil || st.IsDir() {
+		f.Missing = true
+		return f
+	}
+	f.Path = "files/" + base
+	w.attachments[f.Path] = local
+	return f
+}
+
+// finish 写入三份记录的结尾并刷盘
+func (w *exportWriter) finish() error {
+	w.json.WriteString("\n]}\n")
+	fmt.Fprintf(w.txt, "\n共 %d 条消息\n", w.count)
+	w.html.WriteString(exportHTMLTail)
+
+	for _, bw := range []*bufio.Writer{w.json, w.txt, w.html} {
+		if err := bw.Flush(); err != nil {
+			w.closeFiles()
+			return err
+		}
+	}
+	return w.closeFiles()
+}
+
+func (w *exportWriter) closeFiles() error {
+	var first error
+	for _, f := range w.files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	w.files = nil
+	return first
+}
+
+// zipTo 把三份记录和附件打包到 dst。先写临时文件，成功后再改名，避免下载到半个文件。
+func (w *exportWriter) zipTo(dst string) error {
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(out)
+	add := func(name, local string) error {
+		src, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, src)
+		return err
+	}
+
+	for _, name := range []string{exportJSONName, exportTXTName, exportHTMLName} {
+		if err = add(name, filepath.Join(w.dir, name)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		for name, local := range w.attachments {
+			if err = add(name, local); err != nil {
+				break
+			}
+		}
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// exportText 是一条消息在纯文本记录里的内容
+func exportText(m *model.Message, f *exportedFile) string {
+	switch {
+	case m.IsRecalled == 1:
+		return "[消息已撤回]"
+	case m.Type == 1 && f != nil:
+		if f.Missing {
+			return "[文件] " + f.Name + "（文件已过期）"
+		}
+		return "[文件] " + f.Name
+	case m.Type == 2:
+		return "[通话]"
+	case m.Type == 3:
+		return "[聊天记录] " + m.Content
+	}
+	return m.Content
+}
+
+// exportHTMLBody 是一条消息在 HTML 记录里的内容（已转义）
+func exportHTMLBody(m *model.Message, f *exportedFile, text string) string {
+	if m.IsRecalled == 0 && f != nil && !f.Missing {
+		src := html.EscapeString(f.Path)
+		if strings.HasPrefix(f.Type, "image") {
+			return fmt.Sprintf(`<img src="%s" alt="%s">`, src, html.EscapeString(f.Name))
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, src, html.EscapeString(text))
+	}
+	body := html.EscapeString(text)
+	if m.EditedAt != nil {
+		body += ` <span class="edited">（已编辑）</span>`
+	}
+	return strings.ReplaceAll(body, "\n", "<br>")
+}
+
+const exportHTMLHead = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body{font-family:-apple-system,"PingFang SC","Microsoft YaHei",sans-serif;max-width:820px;margin:24px auto;padding:0 16px;color:#222;background:#f5f5f5}
+h1{font-size:20px;margin-bottom:4px}
+.sub{color:#888;font-size:13px;margin-bottom:24px}
+.msg{background:#fff;border-radius:8px;padding:10px 14px;margin-bottom:10px}
+.meta{font-size:13px;color:#555;margin-bottom:4px}
+.meta span{color:#aaa;margin-left:6px}
+.body{white-space:normal;word-break:break-word;line-height:1.6}
+.body img{max-width:100%%;border-radius:4px}
+.edited{color:#aaa;font-size:12px}
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<div class="sub">导出时间：%s</div>
+`
+
+const exportHTMLTail = `</body>
+</html>
+`
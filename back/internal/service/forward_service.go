@@ -0,0 +1,178 @@
+// ============================================================
+// 文件：back/internal/service/forward_service.go
+// 作用：消息转发：校验源消息和目标会话，生成要发送的消息内容。
+//
+// 两种方式：
+//   逐条转发：每条源消息原样生成一条新消息（发送者变成转发的人）
+//   合并转发：所有源消息按时间顺序打包成一条 type=3 的"聊天记录"消息，
+//             快照保存在 message.record（见 model/message.go 的 ForwardRecord）
+//
+// 权限：
+//   源消息：调用者必须能看到（私聊收发双方 / 群成员），且没有撤回、没有被自己删除或清空；
+//           阅后即焚的消息不能转发，否则转发出去的副本会变成永久消息
+//   目标会话：私聊要求是好友，群聊要求是成员；禁言等发送限制在 Publish 时再检查一次
+//
+// 文件消息只复用原来的 url，不复制文件。
+// 真正的发送由 controller 调用 chat.ChatKafkaProducer.Publish 完成（service 不能引用 chat 包）。
+// ============================================================
+package service
+
+import (
+	"errors"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+const (
+	maxForwardMessages = 100
+	maxForwardTargets  = 10
+
+	// MsgTypeChatRecord 是合并转发的聊天记录消息类型
+	MsgTypeChatRecord int8 = 3
+)
+
+// ForwardPayload 是要发往每个目标会话的一条消息
+type ForwardPayload struct {
+	Type     int8
+	Content  string
+	Url      string
+	FileName string
+	FileType string
+	FileSize string
+	Record   *model.ForwardRecord
+}
+
+// PrepareForward 校验后生成转发内容。merge=true 时返回一条聊天记录消息，否则每条源消息对应一条。
+func PrepareForward(userId string, msgIds, targetIds []string, merge bool) ([]ForwardPayload, error) {
+	if len(msgIds) == 0 || len(targetIds) == 0 {
+		return nil, errors.New("请选择要转发的消息和会话")
+	}
+	if len(msgIds) > maxForwardMessages {
+		return nil, errors.New("一次最多转发100条消息")
+	}
+	if len(targetIds) > maxForwardTargets {
+		return nil, errors.New("一次最多转发到10个会话")
+	}
+	for _, t := range targetIds {
+		if err := checkConversationAccess(userId, t); err != nil {
+			return nil, errors.New("没有权限发送到所选会话")
+		}
+	}
+
+	msgs, err := loadForwardSources(userId, msgIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if merge {
+		record := &model.ForwardRecord{Title: forwardTitle(msgs), Items: make([]model.ForwardItem, 0, len(msgs))}
+		for i := range msgs {
+			record.Items = append(record.Items, forwardItem(&msgs[i]))
+		}
+		return []ForwardPayload{{Type: MsgTypeChatRecord, Content: record.Title, Record: record}}, nil
+	}
+
+	payloads := make([]ForwardPayload, 0, len(msgs))
+	for _, m := range msgs {
+		payloads = append(payloads, ForwardPayload{
+			Type:     m.Type,
+			Content:  m.Content,
+			Url:      m.Url,
+			FileName: m.FileName,
+			FileType: m.FileType,
+			FileSize: m.FileSize,
+			Record:   m.Record,
+		})
+	}
+	return payloads, nil
+}
+
+// loadForwardSources 查出源消息并按时间排序；有任何一条不可转发时整体失败
+func loadForwardSources(userId string, msgIds []string) ([]model.Message, error) {
+	db := config.GetDB()
+	var msgs []model.Message
+	if err := visibleToAcross(db.Where("uuid IN ?", msgIds), userId).
+		Order("created_at ASC, id ASC").
+		Find(&msgs).Error; err != nil {
+		return nil, errors.New("转发失败")
+	}
+
+	seen := make(map[string]bool, len(msgIds))
+	for _, id := range msgIds {
+		seen[id] = true
+	}
+	if len(msgs) != len(seen) {
+		return nil, errors.New("部分消息不存在或无权查看")
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if !CanAccessMessage(userId, m) {
+			return nil, errors.New("部分消息不存在或无权查看")
+		}
+		if m.IsRecalled == 1 {
+			return nil, errors.New("已撤回的消息不能转发")
+		}
+		if m.ExpireAt != nil {
+			return nil, errors.New("阅后即焚的消息不能转发")
+		}
+		switch m.Type {
+		case 0, 1, MsgTypeChatRecord:
+		default:
+			return nil, errors.New("通话和系统消息不能转发")
+		}
+	}
+	return msgs, nil
+}
+
+func forwardItem(m *model.Message) model.ForwardItem {
+	return model.ForwardItem{
+		MsgId:      m.Uuid,
+		Type:       m.Type,
+		SendId:     m.SendId,
+		SendName:   m.SendName,
+		SendAvatar: m.SendAvatar,
+		Content:    m.Content,
+		Url:        m.Url,
+		FileName:   m.FileName,
+		FileType:   m.FileType,
+		FileSize:   m.FileSize,
+		CreatedAt:  m.CreatedAt.Unix(),
+		Record:     m.Record,
+	}
+}
+
+// forwardTitle 生成聊天记录的标题：同一个群 → "群名的聊天记录"，同一个私聊 → "A和B的聊天记录"
+func forwardTitle(msgs []model.Message) string {
+	first := msgs[0]
+	group := isGroupId(first.ReceiveId)
+	for _, m := range msgs[1:] {
+		same := m.ReceiveId == first.ReceiveId
+		if !group {
+			same = (m.SendId == first.SendId && m.ReceiveId == first.ReceiveId) ||
+				(m.SendId == first.ReceiveId && m.ReceiveId == first.SendId)
+		}
+		if !same {
+			return "聊天记录"
+		}
+	}
+
+	db := config.GetDB()
+	if group {
+		var g model.GroupInfo
+		db.Select("name").Where("uuid = ?", first.ReceiveId).First(&g)
+		return g.Name + "的聊天记录"
+	}
+
+	var users []model.UserInfo
+	db.Select("uuid", "nickname").Where("uuid IN ?", []string{first.SendId, first.ReceiveId}).Find(&users)
+	names := make(map[string]string, len(users))
+	for _, u := range users {
+		names[u.Uuid] = u.Nickname
+	}
+	a, b := names[first.SendId], names[first.ReceiveId]
+	if a == "" || b == "" || first.SendId == first.ReceiveId {
+		return "聊天记录"
+	}
+	return a + "和" + b + "的聊天记录"
+}
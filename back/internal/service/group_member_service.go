@@ -0,0 +1,134 @@
+// ============================================================
+// 文件：back/internal/service/group_member_service.go
+// 作用：群成员表（group_member）的读写封装，group_service / contact_apply_service /
+//       admin_service 里所有涉及"谁在群里"的逻辑都走这里。
+//
+// 为什么要单独封装？
+//   入群、退群、踢人、审核通过、解散，这几处都要同时维护三样东西：
+//   1. group_member 行（成员本身）
+//   2. group_info.member_cnt（冗余的成员计数）
+//   3. user_contact 里 contact_type=1 的记录（"我加入的群"通讯录项）
+//   把这三步收拢到 addGroupMember / removeGroupMember 里，调用方只需要提供事务。
+//   成员变化后群友之间的在线状态受众也跟着变，这里顺带清除缓存（见 presence_audience.go）。
+//
+// 并发安全：
+//   addGroupMember 用 INSERT ... ON DUPLICATE 忽略，依赖 (group_id, user_id) 唯一索引判断"已在群中"，
+//   不再先读后写，两个人同时入群不会互相覆盖。
+//   member_cnt 用 member_cnt + 1 / - 1 原地更新，也不会丢计数。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errAlreadyInGroup = errors.New("已在群聊中")
+
+// addGroupMember 把用户加入群：写 group_member、member_cnt+1、确保 user_contact 存在。
+// 已经是成员时返回 errAlreadyInGroup。
+func addGroupMember(tx *gorm.DB, groupId, userId string, role int8, inviterId string) error {
+	m := model.GroupMember{
+		GroupId:   groupId,
+		UserId:    userId,
+		Role:      role,
+		InviterId: inviterId,
+		JoinedAt:  time.Now(),
+	}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&m)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errAlreadyInGroup
+	}
+
+	if err := tx.Model(&model.GroupInfo{}).Where("uuid = ?", groupId).
+		UpdateColumn("member_cnt", gorm.Expr("member_cnt + 1")).Error; err != nil {
+		return err
+	}
+	invalidateGroupAudience(tx, groupId)
+
+	var cnt int64
+	tx.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND contact_type = 1", userId, groupId).
+		Count(&cnt)
+	if cnt > 0 {
+		return nil
+	}
+	uc := model.UserContact{
+		UserId:      userId,
+		ContactId:   groupId,
+		ContactType: 1,
+		Status:      0,
+		CreatedAt:   time.Now(),
+	}
+	return tx.Create(&uc).Error
+}
+
+// removeGroupMember 把用户移出群：删 group_member、member_cnt-1、删 user_contact。
+// 返回值表示该用户之前是否在群里。
+func removeGroupMember(tx *gorm.DB, groupId, userId string) (bool, error) {
+	res := tx.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&model.GroupMember{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := tx.Model(&model.GroupInfo{}).Where("uuid = ? AND member_cnt > 0", groupId).
+		UpdateColumn("member_cnt", gorm.Expr("member_cnt - 1")).Error; err != nil {
+		return true, err
+	}
+	invalidateGroupAudience(tx, groupId, userId)
+	return true, tx.Where("user_id = ? AND contact_id = ? AND contact_type = 1", userId, groupId).
+		Delete(&model.UserContact{}).Error
+}
+
+// clearGroupMembers 删除群的全部成员（解散时使用），返回删除前的成员ID。
+func clearGroupMembers(tx *gorm.DB, groupId string) ([]string, error) {
+	var ids []string
+	if err := tx.Model(&model.GroupMember{}).Where("group_id = ?", groupId).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("group_id = ?", groupId).Delete(&model.GroupMember{}).Error; err != nil {
+		return nil, err
+	}
+	invalidatePresenceAudience(ids...)
+	return ids, nil
+}
+
+// GetGroupMemberIds 返回群的全部成员ID，按入群时间排序。
+func GetGroupMemberIds(groupId string) ([]string, error) {
+	db := config.GetDB()
+	var ids []string
+	err := db.Model(&model.GroupMember{}).Where("group_id = ?", groupId).
+		Order("joined_at ASC, id ASC").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// GetGroupMembers 返回群的全部成员记录（含角色、入群时间、禁言状态）。
+func GetGroupMembers(groupId string) ([]model.GroupMember, error) {
+	db := config.GetDB()
+	var list []model.GroupMember
+	err := db.Where("group_id = ?", groupId).Order("joined_at ASC, id ASC").Find(&list).Error
+	return list, err
+}
+
+// GetGroupMember 查询某个成员的记录，不在群里时返回 gorm.ErrRecordNotFound。
+func GetGroupMember(groupId, userId string) (*model.GroupMember, error) {
+	db := config.GetDB()
+	var m model.GroupMember
+	if err := db.Where("group_id = ? AND user_id = ?", groupId, userId).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
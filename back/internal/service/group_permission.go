@@ -0,0 +1,230 @@
+// ============================================================
+// 文件：back/internal/service/group_permission.go
+// 作用：群角色（群主 / 管理员 / 成员）与权限矩阵，以及统一的鉴权入口 CheckGroupPermission。
+//
+// 角色保存在 group_member.role（见 model/group_member.go）：
+//   2 = 群主：拥有全部权限，另外独占"设置/取消管理员"、"转让群主"和"解散群"
+//   1 = 管理员：权限由 config.toml 的 groupConfig.adminPermissions 决定
+//   0 = 成员：权限由 groupConfig.memberPermissions 决定（默认没有）
+//
+// 权限列表：
+//   edit_info     修改群名、公告、头像
+//   approve_join  查看和审核入群申请
+//   kick          移除成员
+//   mute          禁言 / 解除禁言
+//   pin           置顶消息
+//
+// 所有需要权限的群操作都先调用 CheckGroupPermission，不再各自比较 OwnerId。
+// 涉及"对别人动手"的操作（踢人、禁言）还要求操作者角色高于目标角色：
+// 管理员不能踢管理员，谁都不能踢群主。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GroupPermission 是一项群管理权限。
+type GroupPermission string
+
+const (
+	PermEditInfo    GroupPermission = "edit_info"
+	PermApproveJoin GroupPermission = "approve_join"
+	PermKick        GroupPermission = "kick"
+	PermMute        GroupPermission = "mute"
+	PermPin         GroupPermission = "pin"
+)
+
+// AllGroupPermissions 是全部权限，按固定顺序排列（群主拥有全部）。
+var AllGroupPermissions = []GroupPermission{PermEditInfo, PermApproveJoin, PermKick, PermMute, PermPin}
+
+// defaultAdminPermissions 在 groupConfig.adminPermissions 未配置时使用。
+var defaultAdminPermissions = []GroupPermission{PermEditInfo, PermApproveJoin, PermKick, PermMute}
+
+var (
+	errNotGroupMember    = errors.New("你不是该群成员")
+	errNoGroupPermission = errors.New("没有权限执行该操作")
+	errTargetRoleTooHigh = errors.New("不能对同级或更高角色的成员执行该操作")
+)
+
+// RolePermissions 返回某个角色拥有的全部权限。
+func RolePermissions(role int8) []GroupPermission {
+	if role == model.GroupRoleOwner {
+		return AllGroupPermissions
+	}
+
+	cfg := config.GetConfig().GroupConfig
+	var names []string
+	switch role {
+	case model.GroupRoleAdmin:
+		if cfg.AdminPermissions == nil {
+			return defaultAdminPermissions
+		}
+		names = cfg.AdminPermissions
+	default:
+		names = cfg.MemberPermissions
+	}
+
+	perms := make([]GroupPermission, 0, len(names))
+	for _, n := range names {
+		perms = append(perms, GroupPermission(n))
+	}
+	return perms
+}
+
+// RoleHasPermission 判断某个角色是否拥有某项权限。
+func RoleHasPermission(role int8, perm GroupPermission) bool {
+	for _, p := range RolePermissions(role) {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckGroupPermission 是群操作的统一鉴权入口：
+// 校验群存在且未解散、userId 是群成员、其角色拥有 perm 权限。
+// 通过时返回操作者的成员记录，方便调用方继续比较角色。
+func CheckGroupPermission(userId, groupId string, perm GroupPermission) (*model.GroupMember, error) {
+	db := config.GetDB()
+	var group model.GroupInfo
+	if err := db.Select("status").Where("uuid = ?", groupId).First(&group).Error; err != nil {
+		return nil, errors.New("群聊不存在")
+	}
+	if group.Status == 2 {
+		return nil, errors.New("群聊已解散")
+	}
+
+	m, err := GetGroupMember(groupId, userId)
+	if err != nil {
+		return nil, errNotGroupMember
+	}
+	if !RoleHasPermission(m.Role, perm) {
+		return nil, errNoGroupPermission
+	}
+	return m, nil
+}
+
+// checkActOnMember 在 CheckGroupPermission 的基础上，再要求操作者角色高于目标成员。
+// 返回操作者和目标的成员记录。
+func checkActOnMember(operatorId, groupId, targetId string, perm GroupPermission) (*model.GroupMember, *model.GroupMember, error) {
+	op, err := CheckGroupPermission(operatorId, groupId, perm)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := GetGroupMember(groupId, targetId)
+	if err != nil {
+		return nil, nil, errors.New("该用户不在群聊中")
+	}
+	if target.Role >= op.Role {
+		return nil, nil, errTargetRoleTooHigh
+	}
+	return op, target, nil
+}
+
+// SetGroupAdmin 设置或取消管理员，只有群主可以操作。返回目标成员的新角色。
+func SetGroupAdmin(ownerId, groupId, targetId string, admin bool) (int8, error) {
+	op, err := GetGroupMember(groupId, ownerId)
+	if err != nil || op.Role != model.GroupRoleOwner {
+		return 0, errors.New("只有群主才能设置管理员")
+	}
+	target, err := GetGroupMember(groupId, targetId)
+	if err != nil {
+		return 0, errors.New("该用户不在群聊中")
+	}
+	if target.Role == model.GroupRoleOwner {
+		return 0, errors.New("不能修改群主的角色")
+	}
+
+	role := model.GroupRoleMember
+	if admin {
+		role = model.GroupRoleAdmin
+	}
+	if target.Role == role {
+		return role, nil
+	}
+	db := config.GetDB()
+	if err := db.Model(&model.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupId, targetId).
+		Update("role", role).Error; err != nil {
+		return 0, errors.New("设置管理员失败")
+	}
+	return role, nil
+}
+
+// TransferGroupOwner 把群主身份转让给 newOwnerId（必须是群成员）。
+// 原群主默认降为普通成员留在群里；leave 为 true 时原群主同时退群。
+// 群行加 FOR UPDATE 锁，防止两次转让并发执行。
+func TransferGroupOwner(ownerId, groupId, newOwnerId string, leave bool) error {
+	if newOwnerId == "" || newOwnerId == ownerId {
+		return errors.New("请选择其他成员作为新群主")
+	}
+
+	db := config.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var group model.GroupInfo
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uuid = ?", groupId).First(&group).Error; err != nil {
+			return errors.New("群聊不存在")
+		}
+		if group.Status == 2 {
+			return errors.New("群聊已解散")
+		}
+		if group.OwnerId != ownerId {
+			return errors.New("只有群主才能转让群聊")
+		}
+
+		var cnt int64
+		tx.Model(&model.GroupMember{}).Where("group_id = ? AND user_id = ?", groupId, newOwnerId).Count(&cnt)
+		if cnt == 0 {
+			return errors.New("新群主必须是群成员")
+		}
+
+		if err := tx.Model(&group).Update("owner_id", newOwnerId).Error; err != nil {
+			return errors.New("转让失败")
+		}
+		if err := tx.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupId, newOwnerId).
+			Update("role", model.GroupRoleOwner).Error; err != nil {
+			return errors.New("转让失败")
+		}
+
+		if leave {
+			if _, err := removeGroupMember(tx, groupId, ownerId); err != nil {
+				return errors.New("转让失败")
+			}
+			return nil
+		}
+		return tx.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupId, ownerId).
+			Update("role", model.GroupRoleMember).Error
+	})
+}
+
+// MuteGroupMember 禁言成员 duration 时长；duration <= 0 表示解除禁言。
+// 返回新的禁言截止时间（解除时为 nil）。
+func MuteGroupMember(operatorId, groupId, targetId string, duration time.Duration) (*time.Time, error) {
+	if _, _, err := checkActOnMember(operatorId, groupId, targetId, PermMute); err != nil {
+		return nil, err
+	}
+
+	var until *time.Time
+	if duration > 0 {
+		t := time.Now().Add(duration)
+		until = &t
+	}
+	db := config.GetDB()
+	if err := db.Model(&model.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupId, targetId).
+		Update("muted_until", until).Error; err != nil {
+		return nil, errors.New("禁言操作失败")
+	}
+	return until, nil
+}
@@ -9,16 +9,14 @@
 //   如果群数量极大，可以扩展位数。
 //
 // 创建群聊（CreateGroup）的事务设计：
-//   db.Transaction 确保两步操作要么都成功，要么都失败：
+//   db.Transaction 确保几步操作要么都成功，要么都失败：
 //   1. 创建 group_info 记录
-//   2. 创建群主的 user_contact 记录（把这个群放进群主的"我加入的群"列表）
+//   2. 把群主写入 group_member（role=2），同时创建群主的 user_contact 记录
 //   如果步骤 2 失败，步骤 1 也会回滚（数据库事务的原子性保证）。
 //
-// 成员列表 JSON 的更新策略：
-//   加人：读出 JSON → 反序列化 → append → 序列化 → 写回
-//   踢人：读出 JSON → 过滤掉目标用户 → 写回
-//   这个操作有并发安全问题（两个操作同时读可能覆盖对方的写），
-//   在生产高并发场景需要加分布式锁，本项目规模下可接受。
+// 成员的增删：
+//   统一通过 group_member_service.go 里的 addGroupMember / removeGroupMember，
+//   一行一个成员，入群是 INSERT、退群是 DELETE，并发操作互不覆盖。
 //
 // IsGroupMember：
 //   被 message_service.go 调用，在查询群消息前验证权限，防止越权读取。
@@ -29,7 +27,6 @@ import (
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/dto/req"
 	"chatapp/back/internal/model"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -57,11 +54,6 @@ func CreateGroup(req *req.CreateGroupRequest) (string, error) {
 
 	// 构造群聊基本信息
 	uuid6 := generateGroupID()
-	members := []string{req.OwnerId}
-	membersJSON, err := json.Marshal(members)
-	if err != nil {
-		return "", fmt.Errorf("成员序列化失败: %w", err)
-	}
 
 	group := model.GroupInfo{
 		Uuid:      uuid6,
@@ -72,14 +64,23 @@ func CreateGroup(req *req.CreateGroupRequest) (string, error) {
 		AddMode:   int8(req.AddMode),
 		Avatar:    req.Avatar,
 		Status:    0,
-		Members:   membersJSON,
 		CreatedAt: time.Now(),
 	}
 
-	err = db.Transaction(func(tx *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&group).Error; err != nil {
 			return fmt.Errorf("群聊创建失败: %w", err)
 		}
+		// 群主是第一个成员，member_cnt 已经在上面算上了
+		owner := model.GroupMember{
+			GroupId:  group.Uuid,
+			UserId:   req.OwnerId,
+			Role:     model.GroupRoleOwner,
+			JoinedAt: group.CreatedAt,
+		}
+		if err := tx.Create(&owner).Error; err != nil {
+			return fmt.Errorf("群聊创建失败: %w", err)
+		}
 		contact := model.UserContact{
 			UserId:      req.OwnerId,
 			ContactId:   group.Uuid,
@@ -125,42 +126,19 @@ func EnterGroup(userId, groupUuid, message string) error {
 	// 判断加群方式
 	if group.AddMode == 0 {
 		// 直接加入
-		var members []string
-		_ = json.Unmarshal(group.Members, &members)
-		for _, m := range members {
-			if m == userId {
-				return errors.New("已在群聊中")
-			}
-		}
-		members = append(members, userId)
-		membersBytes, _ := json.Marshal(members)
-
 		return db.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Model(&group).Updates(map[string]interface{}{
-				"members":    membersBytes,
-				"member_cnt": len(members),
-			}).Error; err != nil {
+			err := addGroupMember(tx, group.Uuid, userId, model.GroupRoleMember, "")
+			if err != nil && !errors.Is(err, errAlreadyInGroup) {
 				return errors.New("加入群聊失败")
 			}
-			// 确保 user_contact 记录存在
-			var cnt int64
-			tx.Model(&model.UserContact{}).
-				Where("user_id = ? AND contact_id = ? AND contact_type = 1", userId, group.Uuid).
-				Count(&cnt)
-			if cnt == 0 {
-				uc := model.UserContact{
-					UserId:      userId,
-					ContactId:   group.Uuid,
-					ContactType: 1,
-					Status:      0,
-					CreatedAt:   time.Now(),
-				}
-				return tx.Create(&uc).Error
-			}
-			return nil
+			return err
 		})
 	}
 
+	if IsGroupMember(userId, group.Uuid) {
+		return errAlreadyInGroup
+	}
+
 	// ✅ 审核模式下暂时不推，保留原逻辑
 	apply := model.ContactApply{
 		Uuid:        "A" + uuid.NewString()[:7],
@@ -187,99 +165,53 @@ func LeaveGroup(userId, groupUuid string) error {
 	}
 
 	if group.OwnerId == userId {
-		return errors.New("群主不能直接退出群聊，请解散群聊")
-	}
-
-	var members []string
-	_ = json.Unmarshal(group.Members, &members)
-
-	newMembers := []string{}
-	for _, m := range members {
-		if m != userId {
-			newMembers = append(newMembers, m)
-		}
+		return errors.New("群主不能直接退出群聊，请先转让群主或解散群聊")
 	}
 
-	newMembersJSON, _ := json.Marshal(newMembers)
-
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&group).Updates(map[string]interface{}{
-			"members":    newMembersJSON,
-			"member_cnt": len(newMembers),
-		}).Error; err != nil {
+		found, err := removeGroupMember(tx, groupUuid, userId)
+		if err != nil {
 			return errors.New("退出群聊失败")
 		}
-		return tx.Where("user_id = ? AND contact_id = ? AND contact_type = 1", userId, groupUuid).
-			Delete(&model.UserContact{}).Error
+		if !found {
+			return errors.New("你不在该群聊中")
+		}
+		return nil
 	})
 }
 
 func GetGroupMemberList(groupUuid string) ([]string, error) {
 	db := config.GetDB()
 
-	var group model.GroupInfo
-	if err := db.Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
+	var cnt int64
+	if db.Model(&model.GroupInfo{}).Where("uuid = ?", groupUuid).Count(&cnt); cnt == 0 {
 		return nil, errors.New("群聊不存在")
 	}
 
-	var members []string
-	if len(group.Members) > 0 {
-		if err := json.Unmarshal(group.Members, &members); err != nil {
-			return nil, errors.New("解析成员列表失败")
-		}
+	members, err := GetGroupMemberIds(groupUuid)
+	if err != nil {
+		return nil, errors.New("查询成员列表失败")
 	}
-
 	return members, nil
 }
 
-// 移除群成员（群主操作）
-func RemoveGroupMember(ownerId, groupUuid, targetUserId string) error {
+// 移除群成员（需要 kick 权限，且只能移除角色比自己低的成员）
+func RemoveGroupMember(operatorId, groupUuid, targetUserId string) error {
 	db := config.GetDB()
 
-	var group model.GroupInfo
-	if err := db.Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
-		return errors.New("群聊不存在")
-	}
-
-	// 只允许群主踢人
-	if group.OwnerId != ownerId {
-		return errors.New("只有群主才能移除成员")
-	}
-
-	// 解析群成员
-	var members []string
-	if len(group.Members) > 0 {
-		if err := json.Unmarshal(group.Members, &members); err != nil {
-			return errors.New("解析成员失败")
-		}
-	}
-
-	// 检查目标用户是否在群里
-	found := false
-	newMembers := make([]string, 0, len(members))
-	for _, m := range members {
-		if m == targetUserId {
-			found = true
-			continue // 跳过目标用户
-		}
-		newMembers = append(newMembers, m)
-	}
-	if !found {
-		return errors.New("该用户不在群聊中")
+	if _, _, err := checkActOnMember(operatorId, groupUuid, targetUserId, PermKick); err != nil {
+		return err
 	}
 
-	// 更新群组信息
-	newMembersJSON, _ := json.Marshal(newMembers)
-
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&group).Updates(map[string]interface{}{
-			"members":    newMembersJSON,
-			"member_cnt": len(newMembers),
-		}).Error; err != nil {
+		found, err := removeGroupMember(tx, groupUuid, targetUserId)
+		if err != nil {
 			return errors.New("更新群聊失败")
 		}
-		return tx.Where("user_id = ? AND contact_id = ? AND contact_type = 1", targetUserId, groupUuid).
-			Delete(&model.UserContact{}).Error
+		if !found {
+			return errors.New("该用户不在群聊中")
+		}
+		return nil
 	})
 }
 
@@ -297,16 +229,18 @@ func DismissGroup(ownerId, groupUuid string) ([]string, error) {
 		return nil, errors.New("只有群主才能解散群聊")
 	}
 
-	// ✅ 解出成员列表，供 WS 广播使用
+	// ✅ 清空前取出成员列表，供 WS 广播使用
 	var members []string
-	_ = json.Unmarshal(group.Members, &members)
-
 	err := db.Transaction(func(tx *gorm.DB) error {
-		// 标记解散 & 清空成员
-		group.Status = 2 // 2 = 解散
-		group.Members = []byte("[]")
-		group.MemberCnt = 0
-		if err := tx.Save(&group).Error; err != nil {
+		var err error
+		if members, err = clearGroupMembers(tx, groupUuid); err != nil {
+			return err
+		}
+		// 标记解散 & 成员数归零
+		if err := tx.Model(&group).Updates(map[string]interface{}{
+			"status":     2, // 2 = 解散
+			"member_cnt": 0,
+		}).Error; err != nil {
 			return err
 		}
 		// 删除所有成员的 user_contact 记录
@@ -333,57 +267,39 @@ func GetGroupInfo(groupUuid string) (*model.GroupInfo, error) {
 // 用于在查询群消息、群详情、群成员列表前做权限校验。
 func IsGroupMember(userId, groupUuid string) bool {
 	db := config.GetDB()
-	var group model.GroupInfo
-	if err := db.Select("members").Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
-		return false
-	}
-	var members []string
-	if err := json.Unmarshal(group.Members, &members); err != nil {
+	var cnt int64
+	if err := db.Model(&model.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupUuid, userId).
+		Count(&cnt).Error; err != nil {
 		return false
 	}
-	for _, m := range members {
-		if m == userId {
-			return true
-		}
-	}
-	return false
+	return cnt > 0
 }
 
-// 更新公告
+// 更新公告（需要 edit_info 权限）
 func UpdateGroupNotice(userId, groupUuid, notice string) error {
-	db := config.GetDB()
-	var group model.GroupInfo
-	if err := db.Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
-		return errors.New("群聊不存在")
-	}
-	if group.OwnerId != userId {
-		return errors.New("没有权限修改公告")
-	}
-	return db.Model(&group).Update("notice", notice).Error
+	return updateGroupInfoField(userId, groupUuid, "notice", notice, "没有权限修改公告")
 }
 
-// 更新群名
+// 更新群名（需要 edit_info 权限）
 func UpdateGroupName(userId, groupUuid, newName string) error {
-	db := config.GetDB()
-	var group model.GroupInfo
-	if err := db.Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
-		return errors.New("群聊不存在")
-	}
-	if group.OwnerId != userId {
-		return errors.New("没有权限修改群名称")
-	}
-	return db.Model(&group).Update("name", newName).Error
+	return updateGroupInfoField(userId, groupUuid, "name", newName, "没有权限修改群名称")
 }
 
-// UpdateGroupAvatar 更新群头像（群主权限）
+// UpdateGroupAvatar 更新群头像（需要 edit_info 权限）
 func UpdateGroupAvatar(userId, groupUuid, avatar string) error {
-	db := config.GetDB()
-	var group model.GroupInfo
-	if err := db.Where("uuid = ?", groupUuid).First(&group).Error; err != nil {
-		return errors.New("群聊不存在")
-	}
-	if group.OwnerId != userId {
-		return errors.New("没有权限修改群头像")
+	return updateGroupInfoField(userId, groupUuid, "avatar", avatar, "没有权限修改群头像")
+}
+
+// updateGroupInfoField 是三个"修改群资料"接口的公共部分：鉴权后更新单个字段。
+// 没有权限时返回 denyMsg，保持原来各接口的提示文案。
+func updateGroupInfoField(userId, groupUuid, column, value, denyMsg string) error {
+	if _, err := CheckGroupPermission(userId, groupUuid, PermEditInfo); err != nil {
+		if errors.Is(err, errNoGroupPermission) || errors.Is(err, errNotGroupMember) {
+			return errors.New(denyMsg)
+		}
+		return err
 	}
-	return db.Model(&group).Update("avatar", avatar).Error
+	db := config.GetDB()
+	return db.Model(&model.GroupInfo{}).Where("uuid = ?", groupUuid).Update(column, value).Error
 }
@@ -0,0 +1,96 @@
+// ============================================================
+// 文件：back/internal/service/mention_service.go
+// 作用："@我" 收件箱：列出未读的 @ 提醒、按群统计未读数、标记已读。
+//
+// 数据来源：
+//   message_mention 表由消息持久化消费者写入（见 chat/mention.go），
+//   @所有人 已经展开成每个成员一行，这里只需要按 user_id 查询。
+//
+// 已撤回的消息不再出现在列表和计数里（JOIN message 过滤 is_recalled）。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+// MentionItem 是"@我"列表中的一项
+type MentionItem struct {
+	MsgId     string    `json:"msgId"`
+	GroupId   string    `json:"groupId"`
+	GroupName string    `json:"groupName"`
+	SendId    string    `json:"sendId"`
+	SendName  string    `json:"sendName"`
+	Type      int8      `json:"type"`
+	Content   string    `json:"content"`
+	IsAll     bool      `json:"isAll"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MentionCount 是某个群里未读 @ 的数量
+type MentionCount struct {
+	GroupId string `json:"groupId"`
+	Count   int64  `json:"count"`
+}
+
+// GetUnreadMentions 返回用户的未读 @ 提醒，groupId 为空时返回全部群。
+func GetUnreadMentions(userId, groupId string, limit int, beforeTime int64) ([]MentionItem, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	db := config.GetDB()
+	q := db.Table("message_mention AS mm").
+		Select("mm.msg_uuid AS msg_id, mm.group_id, g.name AS group_name, mm.send_id, m.send_name, "+
+			"m.type, m.content, mm.is_all = 1 AS is_all, mm.created_at").
+		Joins("JOIN message AS m ON m.uuid = mm.msg_uuid").
+		Joins("LEFT JOIN group_info AS g ON g.uuid = mm.group_id").
+		Where("mm.user_id = ? AND mm.read_at IS NULL AND m.is_recalled = 0", userId)
+	if groupId != "" {
+		q = q.Where("mm.group_id = ?", groupId)
+	}
+	if beforeTime > 0 {
+		q = q.Where("UNIX_TIMESTAMP(mm.created_at) < ?", beforeTime)
+	}
+
+	var list []MentionItem
+	if err := q.Order("mm.created_at DESC").Limit(limit).Scan(&list).Error; err != nil {
+		return nil, errors.New("获取@提醒失败")
+	}
+	return list, nil
+}
+
+// CountUnreadMentions 按群统计未读 @ 数量
+func CountUnreadMentions(userId string) ([]MentionCount, error) {
+	db := config.GetDB()
+	var counts []MentionCount
+	err := db.Table("message_mention AS mm").
+		Select("mm.group_id, COUNT(*) AS count").
+		Joins("JOIN message AS m ON m.uuid = mm.msg_uuid").
+		Where("mm.user_id = ? AND mm.read_at IS NULL AND m.is_recalled = 0", userId).
+		Group("mm.group_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, errors.New("获取@提醒失败")
+	}
+	return counts, nil
+}
+
+// MarkMentionsRead 标记 @ 提醒为已读：指定 msgIds 时只标这些，否则标记整个群（groupId 为空则全部）。
+func MarkMentionsRead(userId, groupId string, msgIds []string) error {
+	db := config.GetDB()
+	q := db.Model(&model.MessageMention{}).Where("user_id = ? AND read_at IS NULL", userId)
+	if len(msgIds) > 0 {
+		q = q.Where("msg_uuid IN ?", msgIds)
+	} else if groupId != "" {
+		q = q.Where("group_id = ?", groupId)
+	}
+	if err := q.Update("read_at", time.Now()).Error; err != nil {
+		return errors.New("标记失败")
+	}
+	return nil
+}
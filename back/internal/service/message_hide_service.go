@@ -0,0 +1,118 @@
+// ============================================================
+// 文件：back/internal/service/message_hide_service.go
+// 作用："仅对自己删除"：隐藏单条消息、清空整个会话，以及列表查询时的过滤条件。
+//
+// 数据保存在 message_user_state / conversation_clear 两张表（见 model/message_user_state.go），
+// message 表不做任何修改，其他参与者的历史记录不受影响。
+//
+// 过滤方式（visibleTo）：
+//   · NOT EXISTS message_user_state —— 排除自己隐藏的单条消息
+//   · id > conversation_clear.cleared_msg_id —— 排除清空之前的消息
+//   两个条件都走唯一索引，不会拖慢分页查询。
+//   消息来自多个会话时（如转发）用 visibleToAcross，按每条消息自己的会话判断。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxHideBatch 限制一次隐藏的消息条数
+const maxHideBatch = 100
+
+// DeleteMessagesForMe 隐藏若干条消息，只对自己生效。没有访问权限的消息直接忽略。
+func DeleteMessagesForMe(userId string, msgIds []string) error {
+	if len(msgIds) == 0 {
+		return errors.New("请选择要删除的消息")
+	}
+	if len(msgIds) > maxHideBatch {
+		return errors.New("一次最多删除100条消息")
+	}
+
+	db := config.GetDB()
+	var msgs []model.Message
+	if err := db.Where("uuid IN ?", msgIds).Find(&msgs).Error; err != nil {
+		return errors.New("删除失败")
+	}
+
+	now := time.Now()
+	rows := make([]model.MessageUserState, 0, len(msgs))
+	for i := range msgs {
+		if CanAccessMessage(userId, &msgs[i]) {
+			rows = append(rows, model.MessageUserState{UserId: userId, MsgUuid: msgs[i].Uuid, CreatedAt: now})
+		}
+	}
+	if len(rows) == 0 {
+		return errors.New("消息不存在")
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return errors.New("删除失败")
+	}
+	return nil
+}
+
+// ClearConversation 清空自己在某个会话（私聊对方或群）里的聊天记录，只对自己生效。
+// 删除好友时也走这里：对方的历史记录保持不变。
+func ClearConversation(userId, targetId string) error {
+	db := config.GetDB()
+
+	group := isGroupId(targetId)
+	q := db.Model(&model.Message{})
+	if group {
+		q = q.Where("receive_id = ?", targetId)
+	} else {
+		q = q.Where("((send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?))",
+			userId, targetId, targetId, userId)
+	}
+	var maxId int64
+	if err := q.Select("COALESCE(MAX(id), 0)").Scan(&maxId).Error; err != nil {
+		return err
+	}
+
+	row := model.ConversationClear{
+		UserId:       userId,
+		TargetId:     targetId,
+		ClearedMsgId: maxId,
+		UpdatedAt:    time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cleared_msg_id", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		return err
+	}
+	// 清空之前的未读消息不再算未读
+	dropUnreadCache(userId, targetId, group)
+	return nil
+}
+
+// visibleTo 给消息查询加上"对 userId 可见"的条件，targetId 为私聊对方或群 uuid。
+// 查询里的 message 表不能有别名。
+func visibleTo(q *gorm.DB, userId, targetId string) *gorm.DB {
+	return q.
+		Where("NOT EXISTS (SELECT 1 FROM message_user_state AS mus WHERE mus.user_id = ? AND mus.msg_uuid = message.uuid)", userId).
+		Where("message.id > COALESCE((SELECT cc.cleared_msg_id FROM conversation_clear AS cc WHERE cc.user_id = ? AND cc.target_id = ?), 0)", userId, targetId)
+}
+
+// visibleToAcross 和 visibleTo 相同，但不限定会话：清空记录按每条消息所在的会话查
+// （私聊是对方，群聊是群）。查询里的 message 表不能有别名。
+func visibleToAcross(q *gorm.DB, userId string) *gorm.DB {
+	return q.
+		Where("NOT EXISTS (SELECT 1 FROM message_user_state AS mus WHERE mus.user_id = ? AND mus.msg_uuid = message.uuid)", userId).
+		Where("message.id > COALESCE((SELECT cc.cleared_msg_id FROM conversation_clear AS cc WHERE cc.user_id = ? "+
+			"AND cc.target_id = CASE WHEN message.receive_id = ? THEN message.send_id ELSE message.receive_id END), 0)", userId, userId)
+}
+
+// isGroupId 判断 id 是否是群 uuid
+func isGroupId(id string) bool {
+	var cnt int64
+	config.GetDB().Model(&model.GroupInfo{}).Where("uuid = ?", id).Count(&cnt)
+	return cnt > 0
+}
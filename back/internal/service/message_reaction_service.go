@@ -0,0 +1,151 @@
+// ============================================================
+// 文件：back/internal/service/message_reaction_service.go
+// 作用：消息表情回应：添加 / 取消，以及历史消息列表里的聚合计数。
+//
+// 权限：
+//   只有能看到这条消息的人才能回应 —— 私聊的收发双方，或者群成员（IsGroupMember）。
+//
+// 聚合方式：
+//   列表接口一次查出本页全部消息的回应，按 (msg_uuid, emoji) GROUP BY，
+//   同时用 SUM(user_id = 当前用户) 算出"我有没有点过"，前端据此高亮。
+//   表情按第一次出现的先后排序，保证刷新后顺序稳定。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+const maxEmojiRunes = 8 // 带肤色/ZWJ 组合的表情可能由多个码点组成
+
+// ReactionSummary 是某条消息上某个表情的聚合结果。
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"` // 当前用户是否点过（仅列表接口返回）
+}
+
+// MessageView 是历史消息列表返回给前端的结构：消息本身 + 表情回应 + 引用快照。
+// 嵌入 model.Message，JSON 字段平铺，和原来的返回格式兼容。
+type MessageView struct {
+	model.Message
+	Reactions []ReactionSummary  `json:"reactions,omitempty"`
+	Quote     *resp.MessageQuote `json:"quote,omitempty"` // 回复消息才有，见 message_thread_service.go
+}
+
+// AddReaction 给消息添加一个表情回应，重复添加视为成功。返回被回应的消息。
+func AddReaction(userId, msgId, emoji string) (*model.Message, error) {
+	msg, emoji, err := prepareReaction(userId, msgId, emoji)
+	if err != nil {
+		return nil, err
+	}
+	db := config.GetDB()
+	r := model.MessageReaction{MsgUuid: msgId, UserId: userId, Emoji: emoji, CreatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&r).Error; err != nil {
+		return nil, errors.New("添加表情失败")
+	}
+	return msg, nil
+}
+
+// RemoveReaction 取消自己的表情回应。返回被回应的消息。
+func RemoveReaction(userId, msgId, emoji string) (*model.Message, error) {
+	msg, emoji, err := prepareReaction(userId, msgId, emoji)
+	if err != nil {
+		return nil, err
+	}
+	db := config.GetDB()
+	if err := db.Where("msg_uuid = ? AND user_id = ? AND emoji = ?", msgId, userId, emoji).
+		Delete(&model.MessageReaction{}).Error; err != nil {
+		return nil, errors.New("取消表情失败")
+	}
+	return msg, nil
+}
+
+// prepareReaction 校验表情格式和访问权限，返回消息和规范化后的表情。
+func prepareReaction(userId, msgId, emoji string) (*model.Message, string, error) {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiRunes || len(emoji) > 32 {
+		return nil, "", errors.New("表情格式不正确")
+	}
+
+	db := config.GetDB()
+	var msg model.Message
+	if err := db.Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+		return nil, "", errors.New("消息不存在")
+	}
+	if msg.IsRecalled == 1 {
+		return nil, "", errors.New("消息已撤回")
+	}
+	if !CanAccessMessage(userId, &msg) {
+		return nil, "", errors.New("无权限操作该消息")
+	}
+	return &msg, emoji, nil
+}
+
+// GetReactionSummary 返回一条消息的表情聚合（不含 Reacted），用于实时推送。
+func GetReactionSummary(msgId string) []ReactionSummary {
+	return loadReactions("", []string{msgId})[msgId]
+}
+
+// loadReactions 批量查询消息的表情聚合，key 为消息 uuid。
+func loadReactions(userId string, msgIds []string) map[string][]ReactionSummary {
+	result := make(map[string][]ReactionSummary)
+	if len(msgIds) == 0 {
+		return result
+	}
+
+	var rows []struct {
+		MsgUuid string
+		Emoji   string
+		Cnt     int64
+		Mine    int64
+	}
+	db := config.GetDB()
+	err := db.Model(&model.MessageReaction{}).
+		Select("msg_uuid, emoji, COUNT(*) AS cnt, SUM(user_id = ?) AS mine", userId).
+		Where("msg_uuid IN ?", msgIds).
+		Group("msg_uuid, emoji").
+		Order("MIN(id) ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return result
+	}
+
+	for _, r := range rows {
+		result[r.MsgUuid] = append(result[r.MsgUuid], ReactionSummary{
+			Emoji:   r.Emoji,
+			Count:   r.Cnt,
+			Reacted: r.Mine > 0,
+		})
+	}
+	return result
+}
+
+// toMessageViews 把消息列表包装成 MessageView，并填充表情回应和引用快照。
+func toMessageViews(userId string, list []model.Message) []MessageView {
+	ids := make([]string, 0, len(list))
+	var replyIds []string
+	for _, m := range list {
+		ids = append(ids, m.Uuid)
+		if m.ReplyTo != "" {
+			replyIds = append(replyIds, m.ReplyTo)
+		}
+	}
+	reactions := loadReactions(userId, ids)
+	quotes := LoadMessageQuotes(replyIds)
+
+	views := make([]MessageView, 0, len(list))
+	for _, m := range list {
+		views = append(views, MessageView{Message: m, Reactions: reactions[m.Uuid], Quote: quotes[m.ReplyTo]})
+	}
+	return views
+}
@@ -1,6 +1,6 @@
 // ============================================================
 // 文件：back/internal/service/message_service.go
-// 作用：消息相关的业务逻辑：历史消息查询、消息撤回、标记已读、清空会话。
+// 作用：消息相关的业务逻辑：历史消息查询、消息撤回、标记已读。
 //
 // GetMessageList（获取私聊历史）：
 //   先查数据库（不用 Redis 缓存）的理由：
@@ -8,6 +8,8 @@
 //   - 撤回状态、已读状态需要实时准确，缓存可能有延迟
 //   - 分页使用 beforeTime（时间戳游标分页，比 OFFSET 分页更稳定）：
 //     每次加载"比上次最旧一条消息还早的消息"，滚动加载历史不会因为新消息插入而错位
+//   返回的每条消息附带表情回应的聚合结果（MessageView，见 message_reaction_service.go）
+//   自己"仅对自己删除"的消息和清空之前的记录不会返回（见 message_hide_service.go）
 //
 // RecallMessage（撤回消息）：
 //   两个业务规则的实现：
@@ -16,6 +18,12 @@
 //   撤回不是真正删除，而是把 is_recalled = 1、content 清空，
 //   前端看到 is_recalled = 1 就显示"此消息已撤回"。
 //
+// EditMessage（编辑消息）：
+//   规则：只能编辑自己发的、未撤回的文本消息，且在 messageConfig.editWindowMinutes 时间窗口内。
+//   旧内容先写入 message_edit 表，再更新 message.content 和 edited_at，两步在同一事务里。
+//   事务里先对消息行加 FOR UPDATE 锁再检查，并发编辑（或编辑与撤回）依次执行，
+//   历史版本不会漏记，也不会把已撤回的消息改回来。
+//
 // MarkMessagesRead（标记已读）：
 //   批量更新：WHERE receive_id=我 AND send_id=对方 AND read_at IS NULL
 //   用"批量更新"而不是"逐条更新"，减少数据库操作次数。
@@ -26,15 +34,21 @@ package service
 import (
 	"chatapp/back/internal/config"
 	"chatapp/back/internal/model"
+	"chatapp/back/internal/search"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetMessageList 获取两个人之间的历史消息。
 // 它按 created_at 倒序查数据库，再由前端在展示前 reverse，
 // 这样“加载更多历史消息”时更容易基于最老一条消息的时间戳做分页。
-func GetMessageList(userId, targetId string, limit int, beforeTime int64) ([]model.Message, error) {
+func GetMessageList(userId, targetId string, limit int, beforeTime int64) ([]MessageView, error) {
 	// 这里直接查 DB，而不是先查 Redis，
 	// 因为历史消息列表通常需要准确的分页、撤回状态和已读状态。
 	db := config.GetDB()
@@ -48,16 +62,19 @@ func GetMessageList(userId, targetId string, limit int, beforeTime int64) ([]mod
 		"(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)",
 		userId, targetId, targetId, userId,
 	)
+	q = visibleTo(q, userId, targetId)
 	if beforeTime > 0 {
 		q = q.Where("UNIX_TIMESTAMP(created_at) < ?", beforeTime)
 	}
-	err := q.Order("created_at DESC").Limit(limit).Find(&list).Error
-	return list, err
+	if err := q.Order("created_at DESC").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return toMessageViews(userId, list), nil
 }
 
 // GetGroupMessageList 获取群聊历史消息，分页逻辑与私聊保持一致。
 // 调用前会校验 userId 是否是该群成员，防止越权读取不属于自己的群消息。
-func GetGroupMessageList(userId, groupId string, limit int, beforeTime int64) ([]model.Message, error) {
+func GetGroupMessageList(userId, groupId string, limit int, beforeTime int64) ([]MessageView, error) {
 	if !IsGroupMember(userId, groupId) {
 		return nil, errors.New("无权限访问该群消息")
 	}
@@ -70,11 +87,14 @@ func GetGroupMessageList(userId, groupId string, limit int, beforeTime int64) ([
 	}
 
 	q := db.Where("receive_id = ?", groupId)
+	q = visibleTo(q, userId, groupId)
 	if beforeTime > 0 {
 		q = q.Where("UNIX_TIMESTAMP(created_at) < ?", beforeTime)
 	}
-	err := q.Order("created_at DESC").Limit(limit).Find(&list).Error
-	return list, err
+	if err := q.Order("created_at DESC").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return toMessageViews(userId, list), nil
 }
 
 // SaveMessage 作为最薄的一层持久化包装，主要为了给其它调用方提供统一入口。
@@ -96,10 +116,108 @@ func RecallMessage(senderId, msgId string) error {
 	if time.Since(msg.CreatedAt) > 10*time.Minute {
 		return errors.New("超过10分钟，无法撤回")
 	}
-	return db.Model(&msg).Updates(map[string]interface{}{
+	if err := db.Model(&msg).Updates(map[string]interface{}{
 		"is_recalled": 1,
 		"content":     "",
-	}).Error
+	}).Error; err != nil {
+		return err
+	}
+	search.Remove(msg.Uuid)
+	// 离线通知里存着消息摘要，撤回后不能再出现在离线邮件里
+	db.Where("msg_id = ?", msg.Uuid).Delete(&model.OfflineNotice{})
+	return nil
+}
+
+// EditMessage 修改自己发出的文本消息，返回修改后的消息。
+func EditMessage(senderId, msgId, content string) (*model.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+
+	window := time.Duration(config.GetConfig().MessageConfig.EditWindowMinutes) * time.Minute
+	if window <= 0 {
+		return nil, errors.New("消息编辑功能未开启")
+	}
+
+	db := config.GetDB()
+	var msg model.Message
+	now := time.Now()
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+			return errors.New("消息不存在")
+		}
+		if msg.SendId != senderId {
+			return errors.New("只能编辑自己的消息")
+		}
+		if msg.IsRecalled == 1 {
+			return errors.New("消息已撤回，无法编辑")
+		}
+		if msg.Type != 0 {
+			return errors.New("只能编辑文本消息")
+		}
+		if time.Since(msg.CreatedAt) > window {
+			return fmt.Errorf("超过%d分钟，无法编辑", int(window.Minutes()))
+		}
+		if msg.Content == content {
+			return nil
+		}
+
+		if err := tx.Create(&model.MessageEdit{
+			MsgUuid:  msg.Uuid,
+			Content:  msg.Content,
+			EditorId: senderId,
+			EditedAt: now,
+		}).Error; err != nil {
+			return errors.New("编辑失败")
+		}
+		if err := tx.Model(&msg).Updates(map[string]interface{}{
+			"content":   content,
+			"edited_at": now,
+		}).Error; err != nil {
+			return errors.New("编辑失败")
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return &msg, nil
+	}
+
+	msg.Content = content
+	msg.EditedAt = &now
+	search.IndexMessage(&msg)
+	return &msg, nil
+}
+
+// GetMessageEditHistory 返回消息的历史版本（不含当前内容），按时间从早到晚。
+// 只有会话参与者可以查看。
+func GetMessageEditHistory(userId, msgId string) ([]model.MessageEdit, error) {
+	db := config.GetDB()
+	var msg model.Message
+	if err := db.Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+		return nil, errors.New("消息不存在")
+	}
+	if !CanAccessMessage(userId, &msg) {
+		return nil, errors.New("无权限查看该消息")
+	}
+
+	var list []model.MessageEdit
+	err := db.Where("msg_uuid = ?", msgId).Order("edited_at ASC, id ASC").Find(&list).Error
+	return list, err
+}
+
+// CanAccessMessage 判断用户能否看到某条消息：私聊的收发双方，或群聊的成员。
+func CanAccessMessage(userId string, msg *model.Message) bool {
+	if msg.SendId == userId || msg.ReceiveId == userId {
+		return true
+	}
+	return IsGroupMember(userId, msg.ReceiveId)
 }
 
 // MarkMessagesRead 把某个会话里“对方发给我、我还没读”的消息统一标为已读。
@@ -120,15 +238,3 @@ func GetUnreadCount(ctx context.Context, receiverId, senderId string) (int64, er
 		Count(&count).Error
 	return count, err
 }
-
-// ClearConversation 在删除好友时把双方私聊消息一并清除。
-// 这里做的是物理删除，所以之后历史记录无法恢复。
-func ClearConversation(userId, targetId string) error {
-	db := config.GetDB()
-	return db.
-		Where(
-			"(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)",
-			userId, targetId, targetId, userId,
-		).
-		Delete(&model.Message{}).Error
-}
@@ -0,0 +1,98 @@
+// ============================================================
+// 文件：back/internal/service/message_thread_service.go
+// 作用：回复与话题（thread）：按根消息列出全部回复，以及列表接口里的引用快照。
+//
+// 数据来源：
+//   回复消息在发送时由 chat/reply.go 写入 reply_to（直接引用）和 root_id（话题根），
+//   这里只负责读。
+//
+// GetMessageThread：
+//   传入的 msgId 可以是根消息，也可以是话题里的任意一条回复（自动找到根），
+//   返回根消息 + 按 beforeTime 游标分页的回复列表（倒序，与历史消息列表一致）+ 回复总数。
+//   自己"仅对自己删除"的回复不会出现在列表和总数里。
+//   权限与普通消息一致：能看到根消息的人才能看话题（CanAccessMessage）。
+// ============================================================
+package service
+
+import (
+	"errors"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// MessageThread 是话题接口的返回结构
+type MessageThread struct {
+	Root    MessageView   `json:"root"`
+	Replies []MessageView `json:"replies"`
+	Total   int64         `json:"total"` // 回复总数
+}
+
+// GetMessageThread 列出某个话题下的回复
+func GetMessageThread(userId, msgId string, limit int, beforeTime int64) (*MessageThread, error) {
+	db := config.GetDB()
+
+	var msg model.Message
+	if err := db.Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+		return nil, errors.New("消息不存在")
+	}
+	root := msg
+	if msg.RootId != "" {
+		if err := db.Where("uuid = ?", msg.RootId).First(&root).Error; err != nil {
+			return nil, errors.New("话题不存在")
+		}
+	}
+	if !CanAccessMessage(userId, &root) {
+		return nil, errors.New("无权限查看该话题")
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	// 私聊的会话对象是对方，群聊是群本身
+	targetId := root.ReceiveId
+	if targetId == userId {
+		targetId = root.SendId
+	}
+	base := visibleTo(db.Model(&model.Message{}).Where("root_id = ?", root.Uuid), userId, targetId).
+		Session(&gorm.Session{})
+
+	var total int64
+	base.Count(&total)
+
+	var replies []model.Message
+	q := base
+	if beforeTime > 0 {
+		q = q.Where("UNIX_TIMESTAMP(created_at) < ?", beforeTime)
+	}
+	if err := q.Order("created_at DESC").Limit(limit).Find(&replies).Error; err != nil {
+		return nil, errors.New("获取话题失败")
+	}
+
+	return &MessageThread{
+		Root:    toMessageViews(userId, []model.Message{root})[0],
+		Replies: toMessageViews(userId, replies),
+		Total:   total,
+	}, nil
+}
+
+// LoadMessageQuotes 批量生成引用快照，key 为被引用消息的 uuid；查询失败时返回空 map。
+// 列表接口和断线补发（chat/sync.go）共用。
+func LoadMessageQuotes(ids []string) map[string]*resp.MessageQuote {
+	result := make(map[string]*resp.MessageQuote)
+	if len(ids) == 0 {
+		return result
+	}
+	var parents []model.Message
+	if err := config.GetDB().Where("uuid IN ?", ids).Find(&parents).Error; err != nil {
+		return result
+	}
+	for i := range parents {
+		result[parents[i].Uuid] = resp.NewMessageQuote(&parents[i])
+	}
+	return result
+}
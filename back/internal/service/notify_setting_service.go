@@ -0,0 +1,99 @@
+// ============================================================
+// 文件：back/internal/service/notify_setting_service.go
+// 作用：通知设置：是否接收离线邮件摘要、免打扰时段及其时区。
+//
+// 数据在 notify_setting 表（见 model/notify_setting.go），没有记录时按默认值（接收、不设免打扰）。
+// 摘要的记录和发送在 chat 包（见 chat/offline_notice.go、chat/offline_digest.go），这里只负责读写设置。
+// 配置 [notifyConfig] emailDigest 关闭时，设置照样保存，只是不会发邮件（Enabled=false 告诉前端）。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/req"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// NotifySettingView 是返回给前端的通知设置
+type NotifySettingView struct {
+	Enabled     bool   `json:"enabled"` // 服务端是否开启了离线邮件摘要
+	EmailDigest bool   `json:"emailDigest"`
+	QuietStart  string `json:"quietStart"`
+	QuietEnd    string `json:"quietEnd"`
+	TimeZone    string `json:"timeZone"`
+}
+
+// GetNotifySetting 查询用户的通知设置
+func GetNotifySetting(userId string) *NotifySettingView {
+	st := loadNotifySetting(userId)
+	return newNotifySettingView(&st)
+}
+
+// UpdateNotifySetting 修改通知设置，只修改 form 里传了的字段
+func UpdateNotifySetting(userId string, form *req.UpdateNotifySettingRequest) (*NotifySettingView, error) {
+	st := loadNotifySetting(userId)
+	if form.EmailDigest != nil {
+		st.EmailDigest = boolToInt8(*form.EmailDigest)
+	}
+	if form.QuietStart != nil {
+		st.QuietStart = strings.TrimSpace(*form.QuietStart)
+	}
+	if form.QuietEnd != nil {
+		st.QuietEnd = strings.TrimSpace(*form.QuietEnd)
+	}
+	if form.TimeZone != nil {
+		st.TimeZone = strings.TrimSpace(*form.TimeZone)
+	}
+
+	if (st.QuietStart == "") != (st.QuietEnd == "") {
+		return nil, errors.New("免打扰开始和结束时间需要同时设置")
+	}
+	for _, t := range []string{st.QuietStart, st.QuietEnd} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil {
+			return nil, errors.New("时间格式应为 HH:MM")
+		}
+	}
+	if st.TimeZone != "" {
+		if _, err := time.LoadLocation(st.TimeZone); err != nil || len(st.TimeZone) > 64 {
+			return nil, errors.New("无法识别的时区")
+		}
+	}
+
+	st.UpdatedAt = time.Now()
+	if err := config.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email_digest", "quiet_start", "quiet_end", "time_zone", "updated_at"}),
+	}).Create(&st).Error; err != nil {
+		return nil, errors.New("设置失败")
+	}
+	return newNotifySettingView(&st), nil
+}
+
+// loadNotifySetting 查询用户的设置，没有记录时返回默认值
+func loadNotifySetting(userId string) model.NotifySetting {
+	var st model.NotifySetting
+	config.GetDB().Where("user_id = ?", userId).Limit(1).Find(&st)
+	if st.UserId == "" {
+		st = model.NotifySetting{UserId: userId, EmailDigest: 1}
+	}
+	return st
+}
+
+func newNotifySettingView(st *model.NotifySetting) *NotifySettingView {
+	return &NotifySettingView{
+		Enabled:     config.GetConfig().NotifyConfig.EmailDigest,
+		EmailDigest: st.EmailDigest == 1,
+		QuietStart:  st.QuietStart,
+		QuietEnd:    st.QuietEnd,
+		TimeZone:    st.TimeZone,
+	}
+}
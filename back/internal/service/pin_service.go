@@ -0,0 +1,151 @@
+// ============================================================
+// 文件：back/internal/service/pin_service.go
+// 作用：会话置顶消息：置顶、取消置顶、查询置顶列表。
+//
+// 权限：
+//   私聊：收发双方都可以置顶 / 取消
+//   群聊：需要 pin 权限（见 group_permission.go，默认只有群主）
+//
+// 每个会话最多 messageConfig.maxPinned 条，超过时需要先取消旧的。
+// 撤回或已过期删除的消息不会出现在置顶列表里（列表按 message 表联查）。
+// 实时推送（msg_pin / msg_unpin）由 controller 完成。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+const defaultMaxPinned = 10
+
+// PinnedMessageView 是置顶列表里的一项：被置顶的消息 + 谁在什么时候置顶的
+type PinnedMessageView struct {
+	Message  model.Message `json:"message"`
+	PinnedBy string        `json:"pinnedBy"`
+	PinnedAt time.Time     `json:"pinnedAt"`
+}
+
+// PinMessage 置顶一条消息，已经置顶过时视为成功。返回被置顶的消息。
+func PinMessage(userId, msgId string) (*model.Message, error) {
+	msg, key, err := preparePin(userId, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if msg.IsRecalled == 1 {
+		return nil, errors.New("消息已撤回")
+	}
+	if msg.Type == 99 {
+		return nil, errors.New("系统消息不能置顶")
+	}
+
+	db := config.GetDB()
+	var exists int64
+	db.Model(&model.PinnedMessage{}).Where("conversation_key = ? AND msg_uuid = ?", key, msg.Uuid).Count(&exists)
+	if exists > 0 {
+		return msg, nil
+	}
+	var cnt int64
+	db.Model(&model.PinnedMessage{}).Where("conversation_key = ?", key).Count(&cnt)
+	if cnt >= int64(maxPinned()) {
+		return nil, errors.New("置顶消息已达上限，请先取消其他置顶")
+	}
+
+	row := model.PinnedMessage{
+		ConversationKey: key,
+		MsgUuid:         msg.Uuid,
+		PinnedBy:        userId,
+		CreatedAt:       time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return nil, errors.New("置顶失败")
+	}
+	return msg, nil
+}
+
+// UnpinMessage 取消置顶，消息本来就没有置顶时视为成功。返回被取消置顶的消息。
+func UnpinMessage(userId, msgId string) (*model.Message, error) {
+	msg, key, err := preparePin(userId, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.GetDB().
+		Where("conversation_key = ? AND msg_uuid = ?", key, msg.Uuid).
+		Delete(&model.PinnedMessage{}).Error; err != nil {
+		return nil, errors.New("取消置顶失败")
+	}
+	return msg, nil
+}
+
+// GetPinnedMessages 查询会话的置顶消息，最新置顶的在前。targetId 是私聊对方或群的 uuid。
+func GetPinnedMessages(userId, targetId string) ([]PinnedMessageView, error) {
+	if err := checkConversationAccess(userId, targetId); err != nil {
+		return nil, err
+	}
+
+	db := config.GetDB()
+	var pins []model.PinnedMessage
+	if err := db.Where("conversation_key = ?", conversationKey(userId, targetId)).
+		Order("created_at DESC").
+		Find(&pins).Error; err != nil {
+		return nil, errors.New("查询失败")
+	}
+	if len(pins) == 0 {
+		return []PinnedMessageView{}, nil
+	}
+
+	ids := make([]string, 0, len(pins))
+	for _, p := range pins {
+		ids = append(ids, p.MsgUuid)
+	}
+	var msgs []model.Message
+	if err := db.Where("uuid IN ? AND is_recalled = 0", ids).Find(&msgs).Error; err != nil {
+		return nil, errors.New("查询失败")
+	}
+	byId := make(map[string]model.Message, len(msgs))
+	for _, m := range msgs {
+		byId[m.Uuid] = m
+	}
+
+	list := make([]PinnedMessageView, 0, len(pins))
+	for _, p := range pins {
+		if m, ok := byId[p.MsgUuid]; ok {
+			list = append(list, PinnedMessageView{Message: m, PinnedBy: p.PinnedBy, PinnedAt: p.CreatedAt})
+		}
+	}
+	return list, nil
+}
+
+// preparePin 是置顶 / 取消置顶的公共校验：消息存在、有权限，返回消息和所在会话的标识
+func preparePin(userId, msgId string) (*model.Message, string, error) {
+	if msgId == "" {
+		return nil, "", errors.New("参数错误")
+	}
+	var msg model.Message
+	if err := config.GetDB().Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+		return nil, "", errors.New("消息不存在")
+	}
+
+	if isGroupId(msg.ReceiveId) {
+		if _, err := CheckGroupPermission(userId, msg.ReceiveId, PermPin); err != nil {
+			return nil, "", err
+		}
+		return &msg, "G:" + msg.ReceiveId, nil
+	}
+	if msg.SendId != userId && msg.ReceiveId != userId {
+		return nil, "", errors.New("消息不存在")
+	}
+	return &msg, conversationKey(msg.SendId, msg.ReceiveId), nil
+}
+
+func maxPinned() int {
+	if n := config.GetConfig().MessageConfig.MaxPinned; n > 0 {
+		return n
+	}
+	return defaultMaxPinned
+}
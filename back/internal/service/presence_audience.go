@@ -0,0 +1,48 @@
+// ============================================================
+// 文件：back/internal/service/presence_audience.go
+// 作用：好友关系、群成员变化时，清除"在线状态受众"缓存。
+//
+// chat/presence_audience.go 把每个用户的受众（互为好友的人 + 群友）
+// 缓存在 Redis SET chat:audience:{userId} 里。service 不能引用 chat 包，
+// 这里按同样的 key 格式直接删除，下次上下线时由 chat 包重新计算。
+//
+// 受众关系是对称的，所以一次变化要同时清除双方：
+//   加 / 删好友、拉黑 / 取消拉黑 → 两个用户
+//   入群 / 退群 / 踢人           → 这个用户 + 群里的全部成员
+//   解散群                       → 解散前的全部成员
+// 群成员的清除在事务里进行，提交前被别的连接重新缓存的旧数据最多保留一个 TTL。
+// ============================================================
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const audienceKeyPrefix = "chat:audience:"
+
+// invalidatePresenceAudience 删除这些用户的受众缓存
+func invalidatePresenceAudience(userIds ...string) {
+	if len(userIds) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(userIds))
+	for _, id := range userIds {
+		keys = append(keys, audienceKeyPrefix+id)
+	}
+	if err := config.GetRedis().Del(context.Background(), keys...).Err(); err != nil {
+		slog.Warn("presence_audience_invalidate_failed", "count", len(keys), "err", err)
+	}
+}
+
+// invalidateGroupAudience 删除群里全部成员以及 extra 用户的受众缓存
+func invalidateGroupAudience(tx *gorm.DB, groupId string, extra ...string) {
+	var ids []string
+	tx.Model(&model.GroupMember{}).Where("group_id = ?", groupId).Pluck("user_id", &ids)
+	invalidatePresenceAudience(append(ids, extra...)...)
+}
@@ -0,0 +1,369 @@
+// ============================================================
+// 文件：back/internal/service/read_cursor_service.go
+// 作用：已读游标：标记会话已读、统计未读数、查询群消息的已读成员。
+//
+// 已读状态保存在 read_cursor 表（见 model/read_cursor.go），私聊和群聊统一处理：
+//   未读数 = 游标之后别人发来的消息数（不含撤回、系统消息、自己删除的消息和清空会话之前的消息）
+//   私聊额外要求 read_at 为空，兼容旧的 /message/markRead；标记已读时也会同步写 read_at。
+//   群聊没有游标时，从入群时间开始算，不会把入群前的历史都算成未读。
+//   标记已读同时清除会话的"手动标记为未读"（见 session_pref_service.go）。
+//
+// 未读数缓存在 Redis HASH "chat:unread:{userId}"（field = 会话标识）：
+//   chat 包的缓存消费者在新消息到来时给已有的 field 加一（见 chat/unread.go），
+//   这里在 field 缺失或标记已读后从 MySQL 重新计算并覆盖，写入时刷新 TTL。
+//   清空会话（ClearConversation）删除对应的 field，下次读取时重新计算。
+//
+// 群消息的已读成员 = 游标 last_read_id >= 这条消息 id 的当前成员（不含发送者）。
+// 实时推送（read_sync / msg_read / group_read）由 controller 完成。
+// ============================================================
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const unreadCacheTTL = 7 * 24 * time.Hour
+
+// ReadState 是用户在一个会话里的已读状态
+type ReadState struct {
+	TargetId      string    `json:"targetId"`
+	LastReadMsgId string    `json:"lastReadMsgId"`
+	LastReadSeq   int64     `json:"lastReadSeq"`
+	Unread        int64     `json:"unread"`
+	ReadAt        time.Time `json:"readAt"`
+}
+
+// MessageReader 是已读成员列表里的一项
+type MessageReader struct {
+	UserId   string    `json:"userId"`
+	Nickname string    `json:"nickname"`
+	Avatar   string    `json:"avatar"`
+	ReadAt   time.Time `json:"readAt"`
+}
+
+// MessageReaders 是一条消息的已读情况：Total 个接收者里有 ReadCount 个已读
+type MessageReaders struct {
+	MsgId     string          `json:"msgId"`
+	ReadCount int             `json:"readCount"`
+	Total     int             `json:"total"`
+	Readers   []MessageReader `json:"readers"`
+}
+
+// MarkConversationRead 把会话标记为已读到 msgId（为空时为最新一条）。
+// 返回最新的已读状态，以及这次有消息被读到的发送者（用于推送已读回执）。
+func MarkConversationRead(userId, targetId, msgId string) (*ReadState, []string, error) {
+	if targetId == "" {
+		return nil, nil, errors.New("参数错误")
+	}
+	if err := checkConversationAccess(userId, targetId); err != nil {
+		return nil, nil, err
+	}
+	group := isGroupId(targetId)
+	key := sessionKey(userId, targetId, group)
+	db := config.GetDB()
+
+	var msg *model.Message
+	if msgId != "" {
+		var m model.Message
+		if err := db.Where("uuid = ?", msgId).First(&m).Error; err != nil || !inConversation(&m, userId, targetId, group) {
+			return nil, nil, errors.New("消息不存在")
+		}
+		msg = &m
+	} else {
+		var direct, groups []string
+		if group {
+			groups = []string{targetId}
+		} else {
+			direct = []string{targetId}
+		}
+		last, err := lastMessages(userId, direct, groups)
+		if err != nil {
+			return nil, nil, errors.New("标记已读失败")
+		}
+		msg = last[targetId]
+	}
+
+	var old model.ReadCursor
+	db.Where("user_id = ? AND conversation_key = ?", userId, key).Limit(1).Find(&old)
+
+	var senders []string
+	if msg != nil && msg.Id > old.LastReadId {
+		if err := advanceReadCursor(db, userId, key, msg); err != nil {
+			slog.Error("read_cursor_save_failed", "user_id", userId, "conversation", key, "err", err)
+			return nil, nil, errors.New("标记已读失败")
+		}
+		if !group {
+			db.Model(&model.Message{}).
+				Where("send_id = ? AND receive_id = ? AND id <= ? AND read_at IS NULL", targetId, userId, msg.Id).
+				Update("read_at", time.Now())
+		}
+		q := db.Model(&model.Message{}).Where("id > ? AND id <= ? AND is_recalled = 0", old.LastReadId, msg.Id)
+		if group {
+			q = q.Where("receive_id = ? AND send_id <> ?", targetId, userId)
+		} else {
+			q = q.Where("send_id = ? AND receive_id = ?", targetId, userId)
+		}
+		q.Distinct().Pluck("send_id", &senders)
+	}
+
+	clearMarkedUnread(userId, targetId)
+
+	var cur model.ReadCursor
+	db.Where("user_id = ? AND conversation_key = ?", userId, key).Limit(1).Find(&cur)
+	unread := countUnread(userId, targetId, group, cur.LastReadId, joinedAt(userId, targetId, group))
+	cacheUnread(userId, map[string]int64{key: unread})
+
+	return &ReadState{
+		TargetId:      targetId,
+		LastReadMsgId: cur.LastReadMsgId,
+		LastReadSeq:   cur.LastReadSeq,
+		Unread:        unread,
+		ReadAt:        cur.UpdatedAt,
+	}, senders, nil
+}
+
+// advanceReadCursor 把游标移到 msg；游标已经在 msg 之后时不动
+func advanceReadCursor(db *gorm.DB, userId, key string, msg *model.Message) error {
+	var seqs []int64
+	db.Model(&model.MessageSeq{}).Where("user_id = ? AND msg_uuid = ?", userId, msg.Uuid).Pluck("seq", &seqs)
+	var seq int64
+	if len(seqs) > 0 {
+		seq = seqs[0]
+	}
+	now := time.Now()
+
+	res := db.Model(&model.ReadCursor{}).
+		Where("user_id = ? AND conversation_key = ? AND last_read_id < ?", userId, key, msg.Id).
+		Updates(map[string]interface{}{
+			"last_read_id":     msg.Id,
+			"last_read_msg_id": msg.Uuid,
+			"last_read_seq":    seq,
+			"updated_at":       now,
+		})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	// 还没有游标：新建；并发时已被别人建好则忽略
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ReadCursor{
+		UserId:          userId,
+		ConversationKey: key,
+		LastReadId:      msg.Id,
+		LastReadMsgId:   msg.Uuid,
+		LastReadSeq:     seq,
+		UpdatedAt:       now,
+	}).Error
+}
+
+// GetUnreadCounts 批量查询未读数，targets 的 key 是对方用户或群的 uuid，value 表示是否是群。
+// 优先读 Redis，缺失的从 MySQL 计算后写回。
+func GetUnreadCounts(userId string, targets map[string]bool) map[string]int64 {
+	counts := make(map[string]int64, len(targets))
+	if len(targets) == 0 {
+		return counts
+	}
+
+	ids := make([]string, 0, len(targets))
+	fields := make([]string, 0, len(targets))
+	for t, group := range targets {
+		ids = append(ids, t)
+		fields = append(fields, sessionKey(userId, t, group))
+	}
+
+	values, err := config.GetRedis().HMGet(context.Background(), unreadKey(userId), fields...).Result()
+	if err != nil {
+		values = make([]interface{}, len(fields))
+	}
+
+	var missing []string
+	for i, t := range ids {
+		if s, ok := values[i].(string); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				counts[t] = max(n, 0)
+				continue
+			}
+		}
+		missing = append(missing, t)
+	}
+	if len(missing) == 0 {
+		return counts
+	}
+
+	db := config.GetDB()
+	var cursors []model.ReadCursor
+	db.Where("user_id = ?", userId).Find(&cursors)
+	lastRead := make(map[string]int64, len(cursors))
+	for _, c := range cursors {
+		lastRead[c.ConversationKey] = c.LastReadId
+	}
+	var members []model.GroupMember
+	db.Select("group_id", "joined_at").Where("user_id = ?", userId).Find(&members)
+	joined := make(map[string]time.Time, len(members))
+	for _, m := range members {
+		joined[m.GroupId] = m.JoinedAt
+	}
+
+	toCache := make(map[string]int64, len(missing))
+	for _, t := range missing {
+		group := targets[t]
+		key := sessionKey(userId, t, group)
+		var since *time.Time
+		if at, ok := joined[t]; ok && group {
+			since = &at
+		}
+		n := countUnread(userId, t, group, lastRead[key], since)
+		counts[t] = n
+		toCache[key] = n
+	}
+	cacheUnread(userId, toCache)
+	return counts
+}
+
+// GetMessageReaders 查询自己发出的一条消息被谁读过
+func GetMessageReaders(userId, msgId string) (*MessageReaders, error) {
+	db := config.GetDB()
+	var msg model.Message
+	if err := db.Where("uuid = ?", msgId).First(&msg).Error; err != nil {
+		return nil, errors.New("消息不存在")
+	}
+	if msg.SendId != userId {
+		return nil, errors.New("只能查看自己发送的消息的已读情况")
+	}
+
+	group := isGroupId(msg.ReceiveId)
+	var receivers []string
+	if group {
+		db.Model(&model.GroupMember{}).
+			Where("group_id = ? AND user_id <> ?", msg.ReceiveId, userId).
+			Pluck("user_id", &receivers)
+	} else if msg.ReceiveId != userId {
+		receivers = []string{msg.ReceiveId}
+	}
+
+	result := &MessageReaders{MsgId: msg.Uuid, Total: len(receivers), Readers: []MessageReader{}}
+	if len(receivers) == 0 {
+		return result, nil
+	}
+
+	var cursors []model.ReadCursor
+	if err := db.Where("conversation_key = ? AND last_read_id >= ? AND user_id IN ?",
+		sessionKey(userId, msg.ReceiveId, group), msg.Id, receivers).
+		Order("updated_at ASC").
+		Find(&cursors).Error; err != nil {
+		return nil, errors.New("查询失败")
+	}
+	readAt := make(map[string]time.Time, len(cursors))
+	order := make([]string, 0, len(cursors))
+	for _, c := range cursors {
+		readAt[c.UserId] = c.UpdatedAt
+		order = append(order, c.UserId)
+	}
+	// 旧接口 /message/markRead 只写 read_at，不写游标
+	if !group && msg.ReadAt != nil && len(order) == 0 {
+		readAt[msg.ReceiveId] = *msg.ReadAt
+		order = append(order, msg.ReceiveId)
+	}
+	if len(order) == 0 {
+		return result, nil
+	}
+
+	var users []model.UserInfo
+	db.Select("uuid", "nickname", "avatar").Where("uuid IN ?", order).Find(&users)
+	profiles := make(map[string]model.UserInfo, len(users))
+	for _, u := range users {
+		profiles[u.Uuid] = u
+	}
+	for _, uid := range order {
+		p := profiles[uid]
+		result.Readers = append(result.Readers, MessageReader{
+			UserId:   uid,
+			Nickname: p.Nickname,
+			Avatar:   p.Avatar,
+			ReadAt:   readAt[uid],
+		})
+	}
+	result.ReadCount = len(result.Readers)
+	return result, nil
+}
+
+// countUnread 从 MySQL 统计游标 afterId 之后别人发来的消息数。
+// since 是入群时间，群聊还没有游标时只统计入群之后的消息。
+func countUnread(userId, targetId string, group bool, afterId int64, since *time.Time) int64 {
+	q := config.GetDB().Model(&model.Message{}).
+		Where("id > ? AND is_recalled = 0 AND type <> 99", afterId)
+	q = visibleTo(q, userId, targetId)
+	if group {
+		q = q.Where("receive_id = ? AND send_id <> ?", targetId, userId)
+		if afterId == 0 && since != nil {
+			q = q.Where("created_at >= ?", *since)
+		}
+	} else {
+		q = q.Where("send_id = ? AND receive_id = ? AND read_at IS NULL", targetId, userId)
+	}
+	var n int64
+	if err := q.Count(&n).Error; err != nil {
+		slog.Warn("unread_count_failed", "user_id", userId, "target_id", targetId, "err", err)
+	}
+	return n
+}
+
+// cacheUnread 把未读数写入 Redis（field = 会话标识），并刷新 TTL
+func cacheUnread(userId string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+	values := make(map[string]interface{}, len(counts))
+	for k, v := range counts {
+		values[k] = v
+	}
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := unreadKey(userId)
+	pipe := rdb.Pipeline()
+	pipe.HSet(ctx, key, values)
+	pipe.Expire(ctx, key, unreadCacheTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("unread_cache_failed", "user_id", userId, "err", err)
+	}
+}
+
+// dropUnreadCache 删除一个会话的未读数缓存，下次读取时从 MySQL 重新计算
+func dropUnreadCache(userId, targetId string, group bool) {
+	if err := config.GetRedis().HDel(context.Background(), unreadKey(userId), sessionKey(userId, targetId, group)).Err(); err != nil {
+		slog.Warn("unread_cache_drop_failed", "user_id", userId, "target_id", targetId, "err", err)
+	}
+}
+
+// joinedAt 返回群聊的入群时间（私聊或查不到时为 nil）
+func joinedAt(userId, targetId string, group bool) *time.Time {
+	if !group {
+		return nil
+	}
+	m, err := GetGroupMember(targetId, userId)
+	if err != nil {
+		return nil
+	}
+	return &m.JoinedAt
+}
+
+// inConversation 判断消息是否属于 userId 和 targetId 的会话
+func inConversation(m *model.Message, userId, targetId string, group bool) bool {
+	if group {
+		return m.ReceiveId == targetId
+	}
+	return (m.SendId == userId && m.ReceiveId == targetId) || (m.SendId == targetId && m.ReceiveId == userId)
+}
+
+func unreadKey(userId string) string {
+	return fmt.Sprintf("chat:unread:%s", userId)
+}
@@ -0,0 +1,196 @@
+// ============================================================
+// 文件：back/internal/service/scheduled_message_service.go
+// 作用：定时消息的增删改查：创建、列表、修改、取消。
+//
+// 真正的发送由 chat/scheduler.go 里的调度器完成，这里只维护 scheduled_message 表。
+//
+// 修改和取消都用条件 UPDATE（WHERE status = 待发送）：
+//   调度器抢占后状态变成"发送中"，这时修改/取消会失败并提示"已在发送"，
+//   不会出现"用户以为取消了，消息却发出去了"的情况。
+// ============================================================
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	scheduleMinDelay   = 10 * time.Second
+	scheduleMaxDelay   = 30 * 24 * time.Hour
+	scheduleMaxPending = 100
+)
+
+// ScheduledMessageParams 是创建 / 修改定时消息的参数
+type ScheduledMessageParams struct {
+	ReceiveId string
+	Type      int8
+	Content   string
+	Url       string
+	FileName  string
+	FileType  string
+	FileSize  string
+	ReplyTo   string
+	Mentions  []string
+	SendAt    int64 // Unix 时间戳
+}
+
+// CreateScheduledMessage 创建一条定时消息
+func CreateScheduledMessage(userId string, p ScheduledMessageParams) (*model.ScheduledMessage, error) {
+	if p.ReceiveId == "" {
+		return nil, errors.New("接收方不能为空")
+	}
+	if err := validateScheduled(p.Type, p.Content, p.Url); err != nil {
+		return nil, err
+	}
+	sendAt, err := validateSendAt(p.SendAt)
+	if err != nil {
+		return nil, err
+	}
+	if isGroupId(p.ReceiveId) {
+		if !IsGroupMember(userId, p.ReceiveId) {
+			return nil, errors.New("你不在该群聊中")
+		}
+	} else if !IsContact(userId, p.ReceiveId) {
+		return nil, errors.New("对方不是你的好友")
+	}
+
+	db := config.GetDB()
+	var pending int64
+	db.Model(&model.ScheduledMessage{}).
+		Where("send_id = ? AND status = ?", userId, model.ScheduledStatusPending).
+		Count(&pending)
+	if pending >= scheduleMaxPending {
+		return nil, errors.New("待发送的定时消息过多")
+	}
+
+	now := time.Now()
+	sm := model.ScheduledMessage{
+		Uuid:      "S" + newScheduleId(),
+		MsgId:     "M" + newScheduleId(),
+		SendId:    userId,
+		ReceiveId: p.ReceiveId,
+		Type:      p.Type,
+		Content:   p.Content,
+		Url:       p.Url,
+		FileName:  p.FileName,
+		FileType:  p.FileType,
+		FileSize:  p.FileSize,
+		ReplyTo:   p.ReplyTo,
+		Mentions:  p.Mentions,
+		SendAt:    sendAt,
+		Status:    model.ScheduledStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := db.Create(&sm).Error; err != nil {
+		return nil, errors.New("创建定时消息失败")
+	}
+	return &sm, nil
+}
+
+// ListScheduledMessages 列出自己的定时消息，默认只列出待发送的
+func ListScheduledMessages(userId string, all bool) ([]model.ScheduledMessage, error) {
+	db := config.GetDB()
+	q := db.Where("send_id = ?", userId)
+	if !all {
+		q = q.Where("status = ?", model.ScheduledStatusPending)
+	}
+	var list []model.ScheduledMessage
+	if err := q.Order("send_at ASC").Limit(200).Find(&list).Error; err != nil {
+		return nil, errors.New("获取定时消息失败")
+	}
+	return list, nil
+}
+
+// UpdateScheduledMessage 修改待发送定时消息的内容和发送时间（接收方不可修改）
+func UpdateScheduledMessage(userId, id string, p ScheduledMessageParams) (*model.ScheduledMessage, error) {
+	db := config.GetDB()
+	var sm model.ScheduledMessage
+	if err := db.Where("uuid = ? AND send_id = ?", id, userId).First(&sm).Error; err != nil {
+		return nil, errors.New("定时消息不存在")
+	}
+	if err := validateScheduled(sm.Type, p.Content, sm.Url); err != nil {
+		return nil, err
+	}
+	sendAt, err := validateSendAt(p.SendAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// map 更新不经过 serializer，mentions 手动编码成 JSON
+	mentions, _ := json.Marshal(p.Mentions)
+	res := db.Model(&model.ScheduledMessage{}).
+		Where("id = ? AND status = ?", sm.Id, model.ScheduledStatusPending).
+		Updates(map[string]interface{}{
+			"content":    p.Content,
+			"mentions":   string(mentions),
+			"send_at":    sendAt,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return nil, errors.New("修改失败")
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("定时消息已在发送或已结束，无法修改")
+	}
+	db.Where("id = ?", sm.Id).First(&sm)
+	return &sm, nil
+}
+
+// CancelScheduledMessage 取消待发送的定时消息
+func CancelScheduledMessage(userId, id string) error {
+	res := config.GetDB().Model(&model.ScheduledMessage{}).
+		Where("uuid = ? AND send_id = ? AND status = ?", id, userId, model.ScheduledStatusPending).
+		Updates(map[string]interface{}{
+			"status":     model.ScheduledStatusCancelled,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return errors.New("取消失败")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("定时消息不存在或已在发送")
+	}
+	return nil
+}
+
+func validateScheduled(msgType int8, content, url string) error {
+	switch msgType {
+	case 0:
+		if strings.TrimSpace(content) == "" {
+			return errors.New("消息内容不能为空")
+		}
+	case 1:
+		if url == "" {
+			return errors.New("文件地址不能为空")
+		}
+	default:
+		return errors.New("只支持定时发送文本和文件消息")
+	}
+	return nil
+}
+
+func validateSendAt(ts int64) (time.Time, error) {
+	sendAt := time.Unix(ts, 0)
+	d := time.Until(sendAt)
+	if d < scheduleMinDelay {
+		return time.Time{}, errors.New("发送时间至少要在10秒之后")
+	}
+	if d > scheduleMaxDelay {
+		return time.Time{}, errors.New("发送时间不能超过30天")
+	}
+	return sendAt, nil
+}
+
+// newScheduleId 生成 19 位随机串，加 1 位前缀正好 20 位
+func newScheduleId() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")[:19]
+}
@@ -0,0 +1,78 @@
+// ============================================================
+// 文件：back/internal/service/search_service.go
+// 作用：跨会话搜索消息：组装检索条件，交给 search 包的 SearchIndex 执行。
+//
+// 访问范围：
+//   调用者参与的全部私聊 + 当前所在的全部群（group_member 表）。
+//   退群之后就搜不到这个群的消息了，和"退群后不能再看群消息"保持一致。
+//
+// 过滤条件：发送者、会话（对方用户或群）、时间范围、消息类型，都是可选的。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+	"chatapp/back/internal/search"
+)
+
+// SearchMessageParams 是搜索接口的参数
+type SearchMessageParams struct {
+	Keyword        string
+	SenderId       string
+	ConversationId string
+	Type           *int8
+	StartTime      int64 // Unix 时间戳，0 表示不限
+	EndTime        int64 // Unix 时间戳，0 表示不限
+	Limit          int
+	Offset         int
+}
+
+// SearchMessages 在调用者能访问的所有会话里搜索消息
+func SearchMessages(userId string, p SearchMessageParams) (*search.Result, error) {
+	keyword := strings.TrimSpace(p.Keyword)
+	if keyword == "" {
+		return nil, errors.New("请输入搜索关键词")
+	}
+	if utf8.RuneCountInString(keyword) > 50 {
+		return nil, errors.New("关键词过长")
+	}
+	if search.Default == nil {
+		return nil, errors.New("搜索功能未开启")
+	}
+
+	var groupIds []string
+	if err := config.GetDB().Model(&model.GroupMember{}).
+		Where("user_id = ?", userId).
+		Pluck("group_id", &groupIds).Error; err != nil {
+		return nil, errors.New("搜索失败")
+	}
+
+	q := search.Query{
+		Keyword:        keyword,
+		UserId:         userId,
+		GroupIds:       groupIds,
+		SenderId:       p.SenderId,
+		ConversationId: p.ConversationId,
+		Type:           p.Type,
+		Limit:          p.Limit,
+		Offset:         p.Offset,
+	}
+	if p.StartTime > 0 {
+		q.StartTime = time.Unix(p.StartTime, 0)
+	}
+	if p.EndTime > 0 {
+		q.EndTime = time.Unix(p.EndTime, 0)
+	}
+
+	res, err := search.Default.Search(q)
+	if err != nil {
+		return nil, errors.New("搜索失败")
+	}
+	return res, nil
+}
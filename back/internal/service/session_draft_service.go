@@ -0,0 +1,30 @@
+// ============================================================
+// 文件：back/internal/service/session_draft_service.go
+// 作用：读取会话草稿，供会话列表使用。
+//
+// 草稿的保存、清除和多端同步走 WebSocket（见 chat/draft.go），这里只读 session_draft 表。
+// ============================================================
+package service
+
+import (
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/model"
+)
+
+// SessionDraftView 是会话列表里的草稿
+type SessionDraftView struct {
+	Content   string `json:"content"`
+	ReplyTo   string `json:"replyTo,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// GetSessionDrafts 返回用户全部会话的草稿，key 是对方用户或群的 uuid
+func GetSessionDrafts(userId string) map[string]SessionDraftView {
+	var drafts []model.SessionDraft
+	config.GetDB().Where("user_id = ?", userId).Find(&drafts)
+	views := make(map[string]SessionDraftView, len(drafts))
+	for _, d := range drafts {
+		views[d.TargetId] = SessionDraftView{Content: d.Content, ReplyTo: d.ReplyTo, UpdatedAt: d.UpdatedAt.Unix()}
+	}
+	return views
+}
@@ -0,0 +1,536 @@
+// ============================================================
+// 文件：back/internal/service/session_list_service.go
+// 作用：统一的会话列表：按最近活跃时间排序，每项带最后一条消息预览、未读数和置顶 / 免打扰标记。
+//
+// 数据来源（Redis 优先，MySQL 兜底）：
+//   chat 包的缓存消费者在每条新消息到来时维护两份缓存（见 chat/cache_logic.go）：
+//     chat:session:list:{userId}   ZSET，member = 会话标识（"G:群ID" 或排序后的 "A:B"），score = 最后消息时间
+//     chat:session:msgs:{会话标识} LIST，最近的消息在最前
+//   列表的顺序和时间取自 ZSET，预览取自 LIST 的第一条。
+//   缓存里的消息可能之后被撤回（撤回不改缓存），所以预览的消息再按 uuid 批量查一次撤回状态；
+//   被自己删除（message_user_state）的、在清空会话（conversation_clear）之前的、LIST 已经没有的，
+//   改从 MySQL 查这个会话里自己能看到的最后一条（条件见 message_hide_service.go 的 visibleToAcross）。
+//
+// 缓存重建：
+//   chat:session:built:{userId} 标记"这个用户的 ZSET 已经从 MySQL 完整重建过"。
+//   标记不存在（Redis 重启、首次使用、过期）时，从 MySQL 查出全部会话的最后一条消息，
+//   按时间写回 ZSET（只保留最近 sessionCacheSize 个，与 chat 包一致），再按正常流程读取。
+//   只重建 ZSET，不往 LIST 里写：LIST 里的消息是完整的 KafkaMessage，这里拼不出来，新消息到来时自然补上。
+//
+// 列表 = ZSET 里的最近会话 + 自己打开过（session 表里有记录）的会话 + 置顶的、有草稿的会话。
+// 草稿（见 session_draft_service.go）放在 draft 字段，不影响排序。
+// 在 session 表里删除过的会话，只有在删除之后有新消息时才会重新出现。
+//
+// 未读数：按已读游标统计，私聊、群聊都有（见 read_cursor_service.go）。
+// 置顶 / 免打扰 / 归档 / 标记未读来自会话偏好（见 session_pref_service.go）：
+// 置顶的会话一定出现在列表里，排在最前（按 pinOrder），其余按最近活跃排序。
+// ============================================================
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/resp"
+	"chatapp/back/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionCacheSize = 200 // 和 chat 包的 maxSessionCount 保持一致
+	sessionBuiltTTL  = 24 * time.Hour
+)
+
+// SessionListItem 是会话列表里的一项
+type SessionListItem struct {
+	SessionId    string             `json:"sessionId"` // session 表的 uuid；只收到过消息、没有打开过时为空
+	TargetId     string             `json:"targetId"`  // 对方用户或群的 uuid
+	IsGroup      bool               `json:"isGroup"`
+	Name         string             `json:"name"`
+	Avatar       string             `json:"avatar"`
+	LastMessage  *resp.MessageQuote `json:"lastMessage,omitempty"`
+	LastActiveAt int64              `json:"lastActiveAt"` // 最后一条消息的时间，没有消息时是会话创建时间
+	UnreadCount  int64              `json:"unreadCount"`
+	Pinned       bool               `json:"pinned"`
+	PinOrder     int                `json:"pinOrder,omitempty"`
+	Muted        bool               `json:"muted"`
+	MuteUntil    int64              `json:"muteUntil,omitempty"` // 免打扰截止时间，0 表示一直免打扰
+	Archived     bool               `json:"archived"`
+	MarkedUnread bool               `json:"markedUnread"`
+	Draft        *SessionDraftView  `json:"draft,omitempty"`
+}
+
+// cachedPreview 是从 chat:session:msgs 里解析出来的消息，只取预览需要的字段
+type cachedPreview struct {
+	MsgId     string `json:"msgId"`
+	Type      int8   `json:"type"`
+	SendId    string `json:"sendId"`
+	SendName  string `json:"sendName"`
+	ReceiveId string `json:"receiveId"`
+	Content   string `json:"content"`
+	FileName  string `json:"fileName"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// GetSessionList 返回用户的会话列表：置顶的在前，其余最近活跃的在前。
+func GetSessionList(userId string) ([]SessionListItem, error) {
+	db := config.GetDB()
+
+	var sessions []model.Session
+	if err := db.Unscoped().Where("send_id = ?", userId).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	var myGroups []string
+	if err := db.Model(&model.GroupMember{}).Where("user_id = ?", userId).Pluck("group_id", &myGroups).Error; err != nil {
+		return nil, err
+	}
+	groupSet := make(map[string]bool, len(myGroups))
+	for _, g := range myGroups {
+		groupSet[g] = true
+	}
+
+	if !sessionCacheBuilt(userId) {
+		if err := rebuildSessionCache(userId, myGroups, groupSet); err != nil {
+			slog.Warn("session_cache_rebuild_failed", "user_id", userId, "err", err)
+		}
+	}
+
+	items := make(map[string]*SessionListItem)
+	var order []string
+	add := func(target string, at int64) *SessionListItem {
+		if it, ok := items[target]; ok {
+			return it
+		}
+		it := &SessionListItem{TargetId: target, IsGroup: groupSet[target], LastActiveAt: at}
+		items[target] = it
+		order = append(order, target)
+		return it
+	}
+
+	// ① Redis：最近活跃的会话和它们的最后一条消息
+	entries, _ := config.GetRedis().ZRevRangeWithScores(context.Background(), sessionListKey(userId), 0, -1).Result()
+	cached := make([]string, 0, len(entries))
+	for _, z := range entries {
+		key, _ := z.Member.(string)
+		target, isGroup := sessionTarget(userId, key)
+		if target == "" || (isGroup && !groupSet[target]) {
+			continue
+		}
+		add(target, int64(z.Score))
+		cached = append(cached, target)
+	}
+	needDB := fillCachedPreviews(userId, cached, items)
+
+	// ② session 表里打开过的、置顶的、有草稿的，但不在 ZSET 里的会话（已经退出的群除外）
+	prefs := GetSessionPrefs(userId)
+	drafts := GetSessionDrafts(userId)
+	deletedAt := make(map[string]int64)
+	var extra []string
+	extraAt := make(map[string]int64)
+	for _, s := range sessions {
+		if s.DeletedAt.Valid {
+			deletedAt[s.ReceiveId] = s.DeletedAt.Time.Unix()
+			continue
+		}
+		if _, ok := items[s.ReceiveId]; !ok {
+			extra = append(extra, s.ReceiveId)
+			extraAt[s.ReceiveId] = s.CreatedAt.Unix()
+		}
+	}
+	keep := func(t string, at int64) {
+		delete(deletedAt, t) // 置顶、有草稿的会话即使删除过也保留
+		if _, ok := items[t]; ok {
+			return
+		}
+		if _, ok := extraAt[t]; !ok {
+			extra = append(extra, t)
+			extraAt[t] = at
+		}
+	}
+	for t, p := range prefs {
+		if p.Pinned {
+			keep(t, 0)
+		}
+	}
+	for t, d := range drafts {
+		keep(t, d.UpdatedAt)
+	}
+	var unknown []string
+	for _, t := range extra {
+		if !groupSet[t] {
+			unknown = append(unknown, t)
+		}
+	}
+	var leftGroups []string
+	if len(unknown) > 0 {
+		db.Model(&model.GroupInfo{}).Where("uuid IN ?", unknown).Pluck("uuid", &leftGroups)
+	}
+	left := make(map[string]bool, len(leftGroups))
+	for _, g := range leftGroups {
+		left[g] = true
+	}
+	for _, t := range extra {
+		if !left[t] {
+			add(t, extraAt[t])
+			needDB = append(needDB, t)
+		}
+	}
+
+	// ③ MySQL 兜底：缓存里拿不到预览的会话
+	if len(needDB) > 0 {
+		var direct, groups []string
+		for _, t := range needDB {
+			if groupSet[t] {
+				groups = append(groups, t)
+			} else {
+				direct = append(direct, t)
+			}
+		}
+		last, err := lastMessages(userId, direct, groups)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range needDB {
+			if m, ok := last[t]; ok {
+				it := items[t]
+				it.LastMessage = resp.NewMessageQuote(m)
+				if at := m.CreatedAt.Unix(); at > it.LastActiveAt {
+					it.LastActiveAt = at
+				}
+			}
+		}
+	}
+
+	list := make([]SessionListItem, 0, len(order))
+	for _, t := range order {
+		it := items[t]
+		if d, ok := deletedAt[t]; ok && it.LastActiveAt <= d {
+			continue
+		}
+		list = append(list, *it)
+	}
+	fillSessionProfiles(list, sessions)
+	fillUnreadCounts(userId, list)
+	for i := range list {
+		it := &list[i]
+		if d, ok := drafts[it.TargetId]; ok {
+			it.Draft = &d
+		}
+		p, ok := prefs[it.TargetId]
+		if !ok {
+			continue
+		}
+		it.Pinned, it.Muted, it.MuteUntil = p.Pinned, p.Muted, p.MuteUntil
+		it.Archived, it.MarkedUnread = p.Archived, p.MarkedUnread
+		if p.Pinned {
+			it.PinOrder = p.PinOrder
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Pinned && a.PinOrder != b.PinOrder {
+			return a.PinOrder < b.PinOrder
+		}
+		return a.LastActiveAt > b.LastActiveAt
+	})
+	return list, nil
+}
+
+// fillCachedPreviews 从 chat:session:msgs 读取会话的最后一条消息，返回需要改查 MySQL 的会话
+func fillCachedPreviews(userId string, targets []string, items map[string]*SessionListItem) []string {
+	if len(targets) == 0 {
+		return nil
+	}
+	rdb := config.GetRedis()
+	ctx := context.Background()
+
+	pipe := rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(targets))
+	for i, t := range targets {
+		cmds[i] = pipe.LIndex(ctx, sessionMsgsKey(userId, t, items[t].IsGroup), 0)
+	}
+	_, _ = pipe.Exec(ctx)
+
+	var needDB []string
+	previews := make(map[string]cachedPreview, len(targets))
+	msgIds := make([]string, 0, len(targets))
+	for i, t := range targets {
+		var p cachedPreview
+		raw, err := cmds[i].Result()
+		if err != nil || json.Unmarshal([]byte(raw), &p) != nil || p.MsgId == "" {
+			needDB = append(needDB, t)
+			continue
+		}
+		previews[t] = p
+		msgIds = append(msgIds, p.MsgId)
+	}
+	if len(msgIds) == 0 {
+		return needDB
+	}
+
+	// 缓存不会随撤回、删除更新，用数据库校正
+	db := config.GetDB()
+	var states []model.Message
+	db.Select("id", "uuid", "is_recalled").Where("uuid IN ?", msgIds).Find(&states)
+	recalled := make(map[string]bool, len(states))
+	msgRowId := make(map[string]int64, len(states))
+	for _, s := range states {
+		recalled[s.Uuid] = s.IsRecalled == 1
+		msgRowId[s.Uuid] = s.Id
+	}
+	var clears []model.ConversationClear
+	db.Select("target_id", "cleared_msg_id").
+		Where("user_id = ? AND target_id IN ?", userId, targets).
+		Find(&clears)
+	clearedAt := make(map[string]int64, len(clears))
+	for _, c := range clears {
+		clearedAt[c.TargetId] = c.ClearedMsgId
+	}
+	var hidden []string
+	db.Model(&model.MessageUserState{}).
+		Where("user_id = ? AND msg_uuid IN ?", userId, msgIds).
+		Pluck("msg_uuid", &hidden)
+	hiddenSet := make(map[string]bool, len(hidden))
+	for _, id := range hidden {
+		hiddenSet[id] = true
+	}
+
+	for _, t := range targets {
+		p, ok := previews[t]
+		if !ok {
+			continue
+		}
+		// 还没写库的新消息查不到 id，一定在清空之后
+		if id, ok := msgRowId[p.MsgId]; hiddenSet[p.MsgId] || (ok && id <= clearedAt[t]) {
+			needDB = append(needDB, t)
+			continue
+		}
+		m := model.Message{
+			Uuid:      p.MsgId,
+			Type:      p.Type,
+			SendId:    p.SendId,
+			SendName:  p.SendName,
+			ReceiveId: p.ReceiveId,
+			Content:   p.Content,
+			FileName:  p.FileName,
+		}
+		if recalled[p.MsgId] {
+			m.IsRecalled = 1
+		}
+		items[t].LastMessage = resp.NewMessageQuote(&m)
+	}
+	return needDB
+}
+
+// lastMessages 从 MySQL 查每个会话里自己能看到的最后一条消息，key 是对方用户或群的 uuid
+func lastMessages(userId string, direct, groups []string) (map[string]*model.Message, error) {
+	db := config.GetDB()
+
+	var ids []int64
+	if len(direct) > 0 {
+		var part []int64
+		q := db.Model(&model.Message{}).
+			Where(db.Where("send_id = ? AND receive_id IN ?", userId, direct).
+				Or("receive_id = ? AND send_id IN ?", userId, direct))
+		if err := visibleToAcross(q, userId).
+			Group("LEAST(send_id, receive_id), GREATEST(send_id, receive_id)").
+			Pluck("MAX(id)", &part).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, part...)
+	}
+	if len(groups) > 0 {
+		var part []int64
+		q := db.Model(&model.Message{}).
+			Where("receive_id IN ?", groups)
+		if err := visibleToAcross(q, userId).
+			Group("receive_id").
+			Pluck("MAX(id)", &part).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, part...)
+	}
+
+	result := make(map[string]*model.Message, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var msgs []model.Message
+	if err := db.Where("id IN ?", ids).Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		target := m.ReceiveId
+		if m.ReceiveId == userId {
+			target = m.SendId
+		}
+		result[target] = m
+	}
+	return result, nil
+}
+
+// rebuildSessionCache 从 MySQL 重建用户的会话 ZSET，并写入重建标记
+func rebuildSessionCache(userId string, myGroups []string, groupSet map[string]bool) error {
+	db := config.GetDB()
+
+	// 私聊对象：发过或收到过消息的用户（排除群）
+	var direct []string
+	if err := db.Model(&model.Message{}).
+		Where("send_id = ? AND receive_id NOT IN (?)", userId, db.Model(&model.GroupInfo{}).Select("uuid")).
+		Distinct().Pluck("receive_id", &direct).Error; err != nil {
+		return err
+	}
+	var senders []string
+	if err := db.Model(&model.Message{}).
+		Where("receive_id = ?", userId).
+		Distinct().Pluck("send_id", &senders).Error; err != nil {
+		return err
+	}
+	direct = append(direct, senders...)
+
+	last, err := lastMessages(userId, direct, myGroups)
+	if err != nil {
+		return err
+	}
+
+	rdb := config.GetRedis()
+	ctx := context.Background()
+	key := sessionListKey(userId)
+	pipe := rdb.Pipeline()
+	if len(last) > 0 {
+		members := make([]redis.Z, 0, len(last))
+		for target, m := range last {
+			members = append(members, redis.Z{Score: float64(m.CreatedAt.Unix()), Member: sessionKey(userId, target, groupSet[target])})
+		}
+		// GT：不会把缓存消费者刚写入的更新时间改小
+		pipe.ZAddGT(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -sessionCacheSize-1)
+	}
+	pipe.Set(ctx, sessionBuiltKey(userId), 1, sessionBuiltTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// fillSessionProfiles 填充会话的名称和头像：用户 / 群的最新资料优先，其次是 session 表里保存的
+func fillSessionProfiles(list []SessionListItem, sessions []model.Session) {
+	var userIds, groupIds []string
+	for _, it := range list {
+		if it.IsGroup {
+			groupIds = append(groupIds, it.TargetId)
+		} else {
+			userIds = append(userIds, it.TargetId)
+		}
+	}
+
+	db := config.GetDB()
+	names := make(map[string][2]string, len(list))
+	if len(userIds) > 0 {
+		var users []model.UserInfo
+		db.Select("uuid", "nickname", "avatar").Where("uuid IN ?", userIds).Find(&users)
+		for _, u := range users {
+			names[u.Uuid] = [2]string{u.Nickname, u.Avatar}
+		}
+	}
+	if len(groupIds) > 0 {
+		var groups []model.GroupInfo
+		db.Select("uuid", "name", "avatar").Where("uuid IN ?", groupIds).Find(&groups)
+		for _, g := range groups {
+			names[g.Uuid] = [2]string{g.Name, g.Avatar}
+		}
+	}
+	bySession := make(map[string]model.Session, len(sessions))
+	for _, s := range sessions {
+		if !s.DeletedAt.Valid {
+			bySession[s.ReceiveId] = s
+		}
+	}
+
+	for i := range list {
+		it := &list[i]
+		s := bySession[it.TargetId]
+		it.SessionId = s.Uuid
+		n := names[it.TargetId]
+		it.Name = firstNonEmpty(n[0], s.ReceiveName)
+		it.Avatar = firstNonEmpty(n[1], s.Avatar, "default_avatar.png")
+	}
+}
+
+// fillUnreadCounts 批量填充未读数（见 read_cursor_service.go）
+func fillUnreadCounts(userId string, list []SessionListItem) {
+	targets := make(map[string]bool, len(list))
+	for _, it := range list {
+		targets[it.TargetId] = it.IsGroup
+	}
+	counts := GetUnreadCounts(userId, targets)
+	for i := range list {
+		list[i].UnreadCount = counts[list[i].TargetId]
+	}
+}
+
+// ============== 缓存 key（与 chat 包保持一致） ==============
+
+func sessionListKey(userId string) string {
+	return fmt.Sprintf("chat:session:list:%s", userId)
+}
+
+func sessionBuiltKey(userId string) string {
+	return fmt.Sprintf("chat:session:built:%s", userId)
+}
+
+func sessionMsgsKey(userId, target string, isGroup bool) string {
+	return fmt.Sprintf("chat:session:msgs:%s", sessionKey(userId, target, isGroup))
+}
+
+// sessionKey 生成会话标识，规则同 chat 包的 buildSessionId
+func sessionKey(userId, target string, isGroup bool) string {
+	if isGroup {
+		return "G:" + target
+	}
+	if userId < target {
+		return userId + ":" + target
+	}
+	return target + ":" + userId
+}
+
+// sessionTarget 是 sessionKey 的反向：从会话标识里取出对方用户或群的 uuid
+func sessionTarget(userId, key string) (string, bool) {
+	if strings.HasPrefix(key, "G:") {
+		return strings.TrimPrefix(key, "G:"), true
+	}
+	a, b, ok := strings.Cut(key, ":")
+	switch {
+	case !ok:
+		return "", false
+	case a == userId:
+		return b, false
+	case b == userId:
+		return a, false
+	}
+	return "", false
+}
+
+func sessionCacheBuilt(userId string) bool {
+	n, err := config.GetRedis().Exists(context.Background(), sessionBuiltKey(userId)).Result()
+	return err == nil && n > 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,222 @@
+// ============================================================
+// 文件：back/internal/service/session_pref_service.go
+// 作用：会话偏好：置顶（含拖动排序）、免打扰（可设截止时间）、归档、标记为未读。
+//
+// 数据在 session_pref 表（见 model/session_pref.go），只影响自己。
+// 会话列表（session_list_service.go）读取这里的设置：置顶的排在最前，其余按最近活跃排序。
+//
+// 免打扰在服务端生效：chat 包推 mention 事件前会跳过开了免打扰的人（见 chat/session_pref.go），
+// 消息本身照常推送，前端据 muted 标记不弹通知、不响铃。
+// 其它"提醒类"推送也应复用 chat/session_pref.go 里的判断。
+//
+// 多端同步（session_pref / session_reorder 事件）由 controller 完成。
+// ============================================================
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chatapp/back/internal/config"
+	"chatapp/back/internal/dto/req"
+	"chatapp/back/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxPinnedSessions = 20
+
+var (
+	errTooManyPinnedSessions = errors.New("置顶会话已达上限")
+	errMuteUntilPassed       = errors.New("免打扰截止时间必须晚于当前时间")
+)
+
+// SessionPrefView 是返回给前端的会话偏好
+type SessionPrefView struct {
+	TargetId     string `json:"targetId"`
+	Pinned       bool   `json:"pinned"`
+	PinOrder     int    `json:"pinOrder"`
+	Muted        bool   `json:"muted"`
+	MuteUntil    int64  `json:"muteUntil,omitempty"` // Unix秒，0 表示一直免打扰
+	Archived     bool   `json:"archived"`
+	MarkedUnread bool   `json:"markedUnread"`
+}
+
+// UpdateSessionPref 修改会话偏好，只修改 form 里传了的字段。
+func UpdateSessionPref(userId string, form *req.UpdateSessionPrefRequest) (*SessionPrefView, error) {
+	if err := checkPrefTarget(userId, form.TargetId); err != nil {
+		return nil, err
+	}
+
+	db := config.GetDB()
+	var pref model.SessionPref
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SessionPref{
+			UserId:    userId,
+			TargetId:  form.TargetId,
+			UpdatedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND target_id = ?", userId, form.TargetId).
+			First(&pref).Error; err != nil {
+			return err
+		}
+
+		if form.Pinned != nil {
+			if *form.Pinned && pref.Pinned == 0 {
+				var stats struct {
+					Cnt      int64
+					MinOrder int
+				}
+				tx.Model(&model.SessionPref{}).
+					Select("COUNT(*) AS cnt, COALESCE(MIN(pin_order), 0) AS min_order").
+					Where("user_id = ? AND pinned = 1", userId).
+					Scan(&stats)
+				if stats.Cnt >= maxPinnedSessions {
+					return errTooManyPinnedSessions
+				}
+				// 新置顶的排在最前面
+				pref.Pinned, pref.PinOrder = 1, stats.MinOrder-1
+			} else if !*form.Pinned {
+				pref.Pinned, pref.PinOrder = 0, 0
+			}
+		}
+		if form.Muted != nil {
+			pref.Muted, pref.MuteUntil = 0, nil
+			if *form.Muted {
+				pref.Muted = 1
+				if form.MuteUntil > 0 {
+					until := time.Unix(form.MuteUntil, 0)
+					if !until.After(time.Now()) {
+						return errMuteUntilPassed
+					}
+					pref.MuteUntil = &until
+				}
+			}
+		}
+		if form.Archived != nil {
+			pref.Archived = boolToInt8(*form.Archived)
+		}
+		if form.MarkedUnread != nil {
+			pref.MarkedUnread = boolToInt8(*form.MarkedUnread)
+		}
+		pref.UpdatedAt = time.Now()
+		return tx.Model(&pref).Updates(map[string]interface{}{
+			"pinned":        pref.Pinned,
+			"pin_order":     pref.PinOrder,
+			"muted":         pref.Muted,
+			"mute_until":    pref.MuteUntil,
+			"archived":      pref.Archived,
+			"marked_unread": pref.MarkedUnread,
+			"updated_at":    pref.UpdatedAt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errTooManyPinnedSessions) || errors.Is(err, errMuteUntilPassed) {
+			return nil, err
+		}
+		return nil, errors.New("设置失败")
+	}
+
+	view := newSessionPrefView(&pref, time.Now())
+	return &view, nil
+}
+
+// ReorderPinnedSessions 按 targetIds 的顺序重排置顶会话，targetIds 必须正好是全部置顶会话。
+func ReorderPinnedSessions(userId string, targetIds []string) error {
+	db := config.GetDB()
+	var pinned []string
+	if err := db.Model(&model.SessionPref{}).
+		Where("user_id = ? AND pinned = 1", userId).
+		Pluck("target_id", &pinned).Error; err != nil {
+		return errors.New("排序失败")
+	}
+
+	set := make(map[string]bool, len(pinned))
+	for _, t := range pinned {
+		set[t] = true
+	}
+	if len(targetIds) != len(pinned) {
+		return errors.New("置顶会话列表已变化，请刷新后重试")
+	}
+	for _, t := range targetIds {
+		if !set[t] {
+			return errors.New("置顶会话列表已变化，请刷新后重试")
+		}
+		delete(set, t)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i, t := range targetIds {
+			if err := tx.Model(&model.SessionPref{}).
+				Where("user_id = ? AND target_id = ?", userId, t).
+				Updates(map[string]interface{}{"pin_order": i, "updated_at": time.Now()}).Error; err != nil {
+				return errors.New("排序失败")
+			}
+		}
+		return nil
+	})
+}
+
+// GetSessionPrefs 返回用户全部会话的偏好，key 是对方用户或群的 uuid
+func GetSessionPrefs(userId string) map[string]SessionPrefView {
+	var prefs []model.SessionPref
+	config.GetDB().Where("user_id = ?", userId).Find(&prefs)
+	now := time.Now()
+	views := make(map[string]SessionPrefView, len(prefs))
+	for i := range prefs {
+		views[prefs[i].TargetId] = newSessionPrefView(&prefs[i], now)
+	}
+	return views
+}
+
+// clearMarkedUnread 标记已读时清除"手动标记为未读"
+func clearMarkedUnread(userId, targetId string) {
+	config.GetDB().Model(&model.SessionPref{}).
+		Where("user_id = ? AND target_id = ? AND marked_unread = 1", userId, targetId).
+		Updates(map[string]interface{}{"marked_unread": 0, "updated_at": time.Now()})
+}
+
+// checkPrefTarget 群聊要求是成员；私聊只要求对方存在（陌生人发来的会话也可以设置）
+func checkPrefTarget(userId, targetId string) error {
+	if targetId == "" || targetId == userId {
+		return errors.New("参数错误")
+	}
+	if isGroupId(targetId) {
+		if !IsGroupMember(userId, targetId) {
+			return errors.New("不是群成员")
+		}
+		return nil
+	}
+	var cnt int64
+	config.GetDB().Model(&model.UserInfo{}).Where("uuid = ?", targetId).Count(&cnt)
+	if cnt == 0 {
+		return errors.New("会话不存在")
+	}
+	return nil
+}
+
+func newSessionPrefView(p *model.SessionPref, now time.Time) SessionPrefView {
+	v := SessionPrefView{
+		TargetId:     p.TargetId,
+		Pinned:       p.Pinned == 1,
+		PinOrder:     p.PinOrder,
+		Muted:        p.Muted == 1 && (p.MuteUntil == nil || p.MuteUntil.After(now)),
+		Archived:     p.Archived == 1,
+		MarkedUnread: p.MarkedUnread == 1,
+	}
+	if v.Muted && p.MuteUntil != nil {
+		v.MuteUntil = p.MuteUntil.Unix()
+	}
+	return v
+}
+
+func boolToInt8(b bool) int8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -15,7 +15,8 @@
 // GetUserSessionList：
 //   只查 send_id = userId 的会话（每条会话记录的"拥有者"是 send_id）。
 //   如果想查"我参与的所有会话"，只需查自己作为 send_id 的记录。
-//   排序（最近的会话在前）在前端根据最新消息时间排序，或通过 Redis ZSET 实现。
+//   只返回原始的 session 记录；需要排序、最后一条消息预览和未读数时用
+//   GetSessionList（/session/list，见 session_list_service.go）。
 // ============================================================
 package service
 
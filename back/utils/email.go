@@ -1,6 +1,6 @@
 // ============================================================
 // 文件：back/utils/email.go
-// 作用：通过 SMTP 协议发送邮件，用于验证码登录功能。
+// 作用：通过 SMTP 协议发送邮件，用于验证码登录和离线消息摘要。
 //
 // 邮件发送流程：
 //   1. 从配置读取 SMTP 服务器信息（地址、端口、发件人账号密码）
@@ -10,10 +10,12 @@
 //   5. 写入邮件正文（包括 From/To/Subject/Content-Type 头部）
 //   6. 发送并断开连接
 //
-// TLS vs STARTTLS：
-//   这里用 tls.Dial（直接 TLS 加密连接，端口通常 465）
-//   区别于 STARTTLS（先建普通连接再升级为加密，端口通常 587）
+// TLS vs STARTTLS（配置项 security）：
+//   tls（默认）：tls.Dial 直接 TLS 加密连接，端口通常 465
+//   starttls：先建普通连接再升级为加密，端口通常 587
+//   none：全程明文，只用于本地 SMTP 测试服务（MailHog、smtp4dev 等），不要用于生产
 //   Gmail 的 465 端口用的是直接 TLS，所以配置里 smtp_port = 465。
+//   password 为空时跳过认证（本地测试服务通常不需要）。
 //
 // InsecureSkipVerify = false：
 //   不跳过证书验证，正式生产环境里应该保持 false，确保服务器真实可信。
@@ -25,7 +27,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"math/rand"
+	"mime"
+	"net"
 	"net/smtp"
+	"strconv"
 	"time"
 )
 
@@ -41,12 +46,19 @@ func SendEmail(to, subject, body string) error {
 
 	msg := "From: " + c.Username + "\r\n" +
 		"To: " + to + "\r\n" +
-		"Subject: " + subject + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject) + "\r\n" +
 		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
 		body + "\r\n"
 
+	addr := net.JoinHostPort(c.SmtpHost, strconv.Itoa(c.SmtpPort))
 	tlsConfig := &tls.Config{InsecureSkipVerify: false, ServerName: c.SmtpHost}
-	conn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", c.SmtpHost, c.SmtpPort), tlsConfig)
+	var conn net.Conn
+	var err error
+	if c.Security == "starttls" || c.Security == "none" {
+		conn, err = net.DialTimeout("tcp", addr, 10*time.Second)
+	} else {
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	}
 	if err != nil {
 		return err
 	}
@@ -57,8 +69,15 @@ func SendEmail(to, subject, body string) error {
 	}
 	defer client.Close()
 
-	if err = client.Auth(auth); err != nil {
-		return err
+	if c.Security == "starttls" {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.Password != "" {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
 	}
 	if err = client.Mail(c.Username); err != nil {
 		return err